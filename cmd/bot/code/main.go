@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/bot/code/script"
+	"github.com/Clayal10/enders_game/pkg/bot"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+var (
+	address  = flag.String("addr", "localhost:5069", "LURK server to connect to")
+	useTLS   = flag.Bool("tls", false, "connect over TLS")
+	insecure = flag.Bool("tls-insecure", false, "accept any TLS certificate, such as a self signed one")
+	timeout  = flag.Duration("timeout", 5*time.Second, "how long each action waits for the server")
+	verbose  = flag.Bool("v", false, "print every message from the server")
+	forever  = flag.Bool("forever", false, "reconnect and run the script again whenever it finishes")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <script | ->\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	steps, err := readScript(flag.Arg(0))
+	fatalOnErr(err)
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, syscall.SIGINT)
+	for {
+		if err = run(steps); err != nil {
+			log.Println(err)
+			if !*forever {
+				os.Exit(1)
+			}
+		}
+		if !*forever {
+			return
+		}
+		select {
+		case <-interrupted:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func readScript(name string) ([]*script.Step, error) {
+	if name == "-" {
+		return script.Parse(os.Stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return script.Parse(f)
+}
+
+func run(steps []*script.Step) error {
+	opts := &bot.Options{Timeout: *timeout}
+	if *useTLS {
+		opts.TLS = &tls.Config{
+			InsecureSkipVerify: *insecure, //nolint:gosec // Opt in for development servers.
+			MinVersion:         tls.VersionTLS12,
+		}
+	}
+	if *verbose {
+		opts.OnMessage = func(lm lurk.LurkMessage) {
+			log.Printf("<- %v", lm)
+		}
+	}
+
+	b, err := bot.Connect(*address, opts)
+	if err != nil {
+		return err
+	}
+	// The script may have left already, so there's nothing to learn from Close.
+	defer func() { _ = b.Close() }()
+	return script.Run(b, steps, os.Stdout)
+}
+
+func fatalOnErr(err error) {
+	if err != nil {
+		log.Fatalf("%v: could not run the script", err.Error())
+	}
+}
@@ -0,0 +1,262 @@
+// Package script runs bot actions from a text file, one command per line:
+//
+//	# Comments and blank lines are skipped.
+//	character Ender 50 25 25 "The third"
+//	start
+//	move 3
+//	fight
+//	pvp "Petra Arkanian"
+//	loot Bean
+//	say Bean "Hello there"
+//	wait room 1
+//	wait message Bean
+//	wait type CHARACTER
+//	sleep 500ms
+//	try move 14
+//	leave
+//
+// Arguments with spaces are double quoted. try runs a command and carries on if the server
+// rejects it.
+package script
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/bot"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrArguments      = errors.New("wrong arguments")
+)
+
+// Step is one command from a script.
+type Step struct {
+	Line    int
+	Command string
+	Args    []string
+	// Try lets the script continue when the server rejects the command.
+	Try bool
+}
+
+func (s *Step) String() string {
+	parts := []string{s.Command}
+	for _, arg := range s.Args {
+		if strings.ContainsAny(arg, " \t\"") || arg == "" {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	if s.Try {
+		parts = append([]string{"try"}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// argCounts is the minimum and maximum number of arguments of each command.
+var argCounts = map[string][2]int{
+	"character": {4, 5},
+	"start":     {0, 0},
+	"move":      {1, 1},
+	"fight":     {0, 0},
+	"pvp":       {1, 1},
+	"loot":      {1, 1},
+	"say":       {2, 2},
+	"wait":      {1, 2},
+	"sleep":     {1, 1},
+	"leave":     {0, 0},
+}
+
+// Parse reads a whole script, checking every command before any of them run.
+func Parse(r io.Reader) ([]*Step, error) {
+	var steps []*Step
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields, err := split(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		step := &Step{Line: line, Command: fields[0], Args: fields[1:]}
+		if step.Command == "try" && len(step.Args) != 0 {
+			step.Try = true
+			step.Command, step.Args = step.Args[0], step.Args[1:]
+		}
+		if err = step.check(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, scanner.Err()
+}
+
+func (s *Step) check() error {
+	counts, ok := argCounts[s.Command]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, s.Command)
+	}
+	if len(s.Args) < counts[0] || len(s.Args) > counts[1] {
+		return fmt.Errorf("%w: %s takes %d to %d", ErrArguments, s.Command, counts[0], counts[1])
+	}
+	// Run is the only place that needs the values, but bad ones should be found up front.
+	_, err := s.perform(nil)
+	return err
+}
+
+// Run performs every step in order, writing each one to out as it goes.
+func Run(b *bot.Bot, steps []*Step, out io.Writer) error {
+	for _, step := range steps {
+		_, _ = fmt.Fprintf(out, "%d: %v\n", step.Line, step)
+		action, err := step.perform(b)
+		if err == nil {
+			err = action()
+		}
+		if err != nil && step.Try && errors.Is(err, bot.ErrRejected) {
+			_, _ = fmt.Fprintf(out, "%d: %v\n", step.Line, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", step.Line, err)
+		}
+	}
+	return nil
+}
+
+// perform turns the step into a call on b. With a nil bot only the arguments are checked.
+func (s *Step) perform(b *bot.Bot) (func() error, error) {
+	switch s.Command {
+	case "character":
+		stats := make([]uint16, 3)
+		for i := range stats {
+			stat, err := parseUint16(s.Args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			stats[i] = stat
+		}
+		c := &lurk.Character{Name: s.Args[0], Attack: stats[0], Defense: stats[1], Regen: stats[2]}
+		if len(s.Args) == 5 {
+			c.PlayerDesc = s.Args[4]
+		}
+		return func() error { return b.CreateCharacter(c) }, nil
+	case "start":
+		return func() error { return b.Start() }, nil
+	case "move":
+		room, err := parseUint16(s.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func() error { return b.Move(room) }, nil
+	case "fight":
+		return func() error { return b.Fight() }, nil
+	case "pvp":
+		return func() error { return b.PVP(s.Args[0]) }, nil
+	case "loot":
+		return func() error { return b.Loot(s.Args[0]) }, nil
+	case "say":
+		return func() error { return b.Say(s.Args[0], s.Args[1]) }, nil
+	case "wait":
+		match, err := s.waitMatch()
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			_, err := b.WaitFor(match)
+			return err
+		}, nil
+	case "sleep":
+		d, err := time.ParseDuration(s.Args[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrArguments, err)
+		}
+		return func() error {
+			time.Sleep(d)
+			return nil
+		}, nil
+	case "leave":
+		return func() error { return b.Leave() }, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, s.Command)
+}
+
+func (s *Step) waitMatch() (func(lurk.LurkMessage) bool, error) {
+	arg := func() (string, error) {
+		if len(s.Args) != 2 {
+			return "", fmt.Errorf("%w: wait %s needs a value", ErrArguments, s.Args[0])
+		}
+		return s.Args[1], nil
+	}
+	switch s.Args[0] {
+	case "room":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		room, err := parseUint16(value)
+		if err != nil {
+			return nil, err
+		}
+		return bot.InRoom(room), nil
+	case "message":
+		if len(s.Args) == 1 {
+			return bot.IsType(lurk.TypeMessage), nil
+		}
+		return bot.MessageFrom(s.Args[1]), nil
+	case "type":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		var mt lurk.MessageType
+		if err := mt.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrArguments, err)
+		}
+		return bot.IsType(mt), nil
+	}
+	return nil, fmt.Errorf("%w: can't wait for %s", ErrArguments, s.Args[0])
+}
+
+func parseUint16(value string) (uint16, error) {
+	n, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a number from 0 to 65535", ErrArguments, value)
+	}
+	return uint16(n), nil
+}
+
+// split breaks a line into fields on whitespace, keeping double quoted strings together.
+func split(line string) ([]string, error) {
+	var fields []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return fields, nil
+		}
+		if line[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrArguments)
+			}
+			field, _ := strconv.Unquote(quoted)
+			fields = append(fields, field)
+			line = line[len(quoted):]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+}
@@ -0,0 +1,97 @@
+package script_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/bot/code/script"
+	"github.com/Clayal10/enders_game/cmd/server/code/server"
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/bot"
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+func TestParse(t *testing.T) {
+	a := assert.New(t)
+
+	steps, err := script.Parse(strings.NewReader(`
+# A comment.
+character "Petra Arkanian" 50 25 25 "Best shot in the school"
+  start
+try move 14
+wait type character
+`))
+	a.NoError(err)
+	a.True(len(steps) == 4)
+	a.True(steps[0].Line == 3)
+	a.True(strings.Join(steps[0].Args, "|") == "Petra Arkanian|50|25|25|Best shot in the school")
+	a.True(steps[2].Try && steps[2].Command == "move")
+	a.True(steps[0].String() == `character "Petra Arkanian" 50 25 25 "Best shot in the school"`)
+
+	tests := []struct {
+		script string
+		err    error
+	}{
+		{"dance", script.ErrUnknownCommand},
+		{"move", script.ErrArguments},
+		{"move north", script.ErrArguments},
+		{"character Bean 1 2", script.ErrArguments},
+		{"character Bean 1 2 70000", script.ErrArguments},
+		{"say Bean \"unterminated", script.ErrArguments},
+		{"wait room", script.ErrArguments},
+		{"wait type SHOUT", script.ErrArguments},
+		{"sleep soon", script.ErrArguments},
+	}
+	for _, test := range tests {
+		_, err := script.Parse(strings.NewReader("start\n" + test.script))
+		a.True(errors.Is(err, test.err))
+		a.True(strings.HasPrefix(err.Error(), "line 2:"))
+	}
+}
+
+func TestRun(t *testing.T) {
+	a := assert.New(t)
+
+	port := cross.GetFreePort()
+	cfs, err := server.New(&server.Config{Port: port})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+
+	steps, err := script.Parse(strings.NewReader(`
+character Ender 50 25 25
+start
+move 3
+fight
+try move 14
+move 1
+wait type CHARACTER
+sleep 1ms
+leave
+`))
+	a.NoError(err)
+
+	b, err := bot.Connect(fmt.Sprintf("localhost:%v", port), &bot.Options{Timeout: time.Second})
+	a.NoError(err)
+	var out bytes.Buffer
+	a.NoError(script.Run(b, steps, &out))
+	a.True(strings.Contains(out.String(), "6: try move 14"))
+	a.True(strings.Contains(out.String(), bot.ErrRejected.Error()))
+
+	// Without try, a rejection ends the script.
+	steps, err = script.Parse(strings.NewReader("character Alai 50 25 25\nstart\nmove 14\nleave"))
+	a.NoError(err)
+	b, err = bot.Connect(fmt.Sprintf("localhost:%v", port), &bot.Options{Timeout: time.Second})
+	a.NoError(err)
+	defer func() { _ = b.Close() }()
+	err = script.Run(b, steps, &out)
+	a.True(errors.Is(err, bot.ErrRejected))
+	a.True(strings.HasPrefix(err.Error(), "line 3:"))
+}
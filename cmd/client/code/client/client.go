@@ -7,14 +7,12 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"os"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/Clayal10/enders_game/pkg/cross"
-	"github.com/Clayal10/enders_game/pkg/data"
 	"github.com/Clayal10/enders_game/pkg/lurk"
 )
 
@@ -28,75 +26,128 @@ type Client struct {
 	ctx context.Context
 	cf  context.CancelFunc
 
+	cfg *Config
+
 	mu   sync.Mutex
-	conn net.Conn
-	q    *data.Queue[lurk.LurkMessage]
+	conn *lurk.Conn
+	// started is the character the player started with, sent again after a reconnect.
+	started *lurk.Character
+	// rev counts changes to State. changed is closed and replaced on every change.
+	rev     uint64
+	changed chan struct{}
+	// served is the rev last returned by the update endpoint.
+	served uint64
 }
 
-func newClient(conn net.Conn, id int64) *Client {
+func newClient(conn *lurk.Conn, id int64) *Client {
 	return &Client{
-		conn:  conn,
-		id:    id,
-		q:     data.NewQueue[lurk.LurkMessage](100),
-		State: newClientState(id),
+		conn:    conn,
+		id:      id,
+		State:   newClientState(id),
+		changed: make(chan struct{}),
 	}
 }
 
-// This function enqueues messages into the message queue to be returned
-// as HTTP responses to the UI.
+// readFromServer applies each message to the state as it arrives, waking anyone waiting on
+// the update and event endpoints. If the server goes away it reconnects.
 func (c *Client) readFromServer() {
-	var ba []byte
-	var lurkMessage lurk.LurkMessage
-	var err error
 	for {
-		select {
-		case <-c.ctx.Done():
+		err := c.readUntilError()
+		if c.ctx.Err() != nil {
+			return
+		}
+		log.Printf("ID:%v %s: Disconnected from the server", c.id, err.Error())
+		if !c.reconnect(err) {
 			return
-		default:
 		}
-		if ba, _, err = lurk.ReadSingleMessage(c.conn); err != nil {
-			log.Printf("Error in reading message from server: %s", err.Error())
+	}
+}
+
+func (c *Client) readUntilError() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	for {
+		ba, err := conn.ReceiveFrame()
+		if err != nil {
 			if errors.Is(err, cross.ErrInvalidMessageType) {
+				log.Printf("Error in reading message from server: %s", err.Error())
 				continue
 			}
-			log.Printf("%s: Disconnecting from the server", err.Error())
-			break
+			return err
 		}
 
-		if lurkMessage, err = lurk.Unmarshal(ba); err != nil {
+		lurkMessage, err := lurk.Unmarshal(ba)
+		if err != nil {
 			log.Printf("Error in decoding message from server: %v", err.Error())
 			continue
 		}
-		c.q.Enqueue(lurkMessage)
+		c.updateClientState([]lurk.LurkMessage{lurkMessage})
 	}
 }
 
+// pollTime is how long the update endpoint waits for a change, and how often the event
+// stream sends a keep-alive.
 var pollTime = 5 * time.Second
 
-func (c *Client) dequeueAll() (lms []lurk.LurkMessage) {
-	start := time.Now()
-	for time.Since(start) < pollTime {
-		lm, err := c.q.Dequeue()
-		if err != nil {
-			if len(lms) != 0 {
-				break
-			}
-			time.Sleep(2 * time.Millisecond) // In hopes of combining messages in a single http response.
-			continue
+// batchDelay is how long a change has to settle before it is sent on. Messages mostly come in
+// bursts, like a ROOM followed by its CONNECTIONs, which are better sent together.
+const batchDelay = 2 * time.Millisecond
+
+// waitChange waits until the state has moved past rev and settled, returning the new rev. It
+// returns rev unchanged if the wait timed out or done was closed first.
+func (c *Client) waitChange(rev uint64, timeout time.Duration, done <-chan struct{}) uint64 {
+	c.mu.Lock()
+	current, changed := c.rev, c.changed
+	c.mu.Unlock()
+	if current == rev {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-changed:
+		case <-timer.C:
+			return rev
+		case <-done:
+			return rev
+		}
+	}
+	for settled := false; !settled; {
+		c.mu.Lock()
+		changed = c.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(batchDelay):
+			settled = true
+		case <-done:
+			settled = true
 		}
-		lms = append(lms, lm)
 	}
-	return
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rev
+}
+
+// waitUpdate waits for a change the update endpoint hasn't returned yet.
+func (c *Client) waitUpdate(done <-chan struct{}) bool {
+	c.mu.Lock()
+	served := c.served
+	c.mu.Unlock()
+	rev := c.waitChange(served, pollTime, done)
+	if rev == served {
+		return false
+	}
+	c.mu.Lock()
+	c.served = max(c.served, rev)
+	c.mu.Unlock()
+	return true
 }
 
-func readAllMessagesInBuffer(conn net.Conn) (messages []lurk.LurkMessage, _ error) {
+func readAllMessagesInBuffer(conn *lurk.Conn) (messages []lurk.LurkMessage, _ error) {
+	conn.SetReadTimeout(100 * time.Millisecond)
+	defer conn.SetReadTimeout(0)
 	for {
-		// errors will get taken care of in the message read.
-		_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
-		defer func() {
-			_ = conn.SetReadDeadline(time.Time{})
-		}()
-		ba, _, err := lurk.ReadSingleMessage(conn)
+		lmsg, err := conn.Receive()
 		if err != nil {
 			if errors.Is(err, os.ErrDeadlineExceeded) {
 				return messages, nil
@@ -104,11 +155,6 @@ func readAllMessagesInBuffer(conn net.Conn) (messages []lurk.LurkMessage, _ erro
 			return nil, err
 		}
 
-		lmsg, err := lurk.Unmarshal(ba)
-		if err != nil {
-			return nil, err
-		}
-
 		messages = append(messages, lmsg)
 	}
 }
@@ -5,28 +5,32 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Clayal10/enders_game/pkg/assert"
 	"github.com/Clayal10/enders_game/pkg/cross"
 	"github.com/Clayal10/enders_game/pkg/lurk"
 )
 
-func TestQueueReading(t *testing.T) {
+func TestWaitChange(t *testing.T) {
 	a := assert.New(t)
 
 	c := newClient(nil, 0)
+	a.True(c.waitChange(0, time.Millisecond, nil) == 0)
 
-	c.q.Enqueue(&lurk.Fight{}, &lurk.Leave{}, &lurk.Loot{TargetName: "test"})
+	go c.updateClientState([]lurk.LurkMessage{&lurk.Message{Text: "one"}})
+	rev := c.waitChange(0, time.Second, nil)
+	a.True(rev == 1)
+	a.True(c.waitChange(0, time.Second, nil) == 1)
 
-	messages := c.dequeueAll()
-	a.True(len(messages) == 3)
-	_, ok := messages[0].(*lurk.Fight)
-	a.True(ok)
-	_, ok = messages[1].(*lurk.Leave)
-	a.True(ok)
-	loot, ok := messages[2].(*lurk.Loot)
-	a.True(ok)
-	a.True(loot.TargetName == "test")
+	done := make(chan struct{})
+	close(done)
+	a.True(c.waitChange(rev, time.Second, done) == rev)
+
+	a.True(c.waitUpdate(nil))
+	pollTime = time.Millisecond
+	defer func() { pollTime = 5 * time.Second }()
+	a.True(!c.waitUpdate(nil))
 }
 
 func TestDefaultCharacter(t *testing.T) {
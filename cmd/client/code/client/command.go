@@ -0,0 +1,278 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command, try help")
+	ErrMissingArgs    = errors.New("missing arguments")
+	ErrNoMatch        = errors.New("nothing matches")
+	ErrAmbiguous      = errors.New("more than one match, type more of the name")
+	ErrNotStarted     = errors.New("start a character first")
+)
+
+// Completions is what the interpreter knows about the game, used to expand shortened room and
+// character names.
+type Completions struct {
+	// Self is the player's character once it has started, messages are sent from it.
+	Self        *lurk.Character
+	Connections []*lurk.Connection
+	// Characters are the ones in the same room.
+	Characters []*lurk.Character
+}
+
+// Command is an interpreted line of input.
+type Command struct {
+	// Message should be sent to the server. It is nil for commands answered locally.
+	Message lurk.LurkMessage
+	// Reply is text for the player, such as their stats or the help.
+	Reply string
+}
+
+type verb struct {
+	name    string
+	aliases []string
+	usage   string
+	run     func(args string, known *Completions) (*Command, error)
+}
+
+var verbs []*verb
+
+func init() {
+	verbs = []*verb{
+		{"go", []string{"g", "m", "move"}, "go <room number or name>", interpretGo},
+		{"fight", []string{"f"}, "fight", interpretFight},
+		{"pvp", []string{"p"}, "pvp <player>", interpretPVP},
+		{"loot", []string{"l"}, "loot <character>", interpretLoot},
+		{"tell", []string{"t", "msg"}, "tell <player> <text>", interpretTell},
+		{"say", []string{"'"}, "say <text to the room>", interpretChannel(lurk.RoomChannel)},
+		{"shout", []string{"yell"}, "shout <text to everyone>", interpretChannel(lurk.GlobalChannel)},
+		{"stats", []string{"s"}, "stats", interpretStats},
+		{"help", []string{"h", "?"}, "help", interpretHelp},
+		{"leave", []string{"q", "quit"}, "leave", interpretLeave},
+	}
+}
+
+// Interpret turns a line like "go barracks" or "loot Bean" into a command. Room and character
+// names, and the command itself, can be shortened to any prefix that only matches one thing.
+func Interpret(line string, known *Completions) (*Command, error) {
+	line = strings.TrimSpace(line)
+	word, args, _ := strings.Cut(line, " ")
+	if word == "" {
+		return nil, ErrUnknownCommand
+	}
+	v, err := findVerb(strings.ToLower(word))
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := v.run(strings.TrimSpace(args), known)
+	if errors.Is(err, ErrMissingArgs) {
+		return nil, fmt.Errorf("%w: %s", err, v.usage)
+	}
+	return cmd, err
+}
+
+func findVerb(word string) (*verb, error) {
+	var found []*verb
+	for _, v := range verbs {
+		if v.name == word {
+			return v, nil
+		}
+		for _, alias := range v.aliases {
+			if alias == word {
+				return v, nil
+			}
+		}
+		if strings.HasPrefix(v.name, word) {
+			found = append(found, v)
+		}
+	}
+	if len(found) != 1 {
+		return nil, ErrUnknownCommand
+	}
+	return found[0], nil
+}
+
+func interpretGo(args string, known *Completions) (*Command, error) {
+	if args == "" {
+		return nil, ErrMissingArgs
+	}
+	if n, err := strconv.ParseUint(args, 10, 16); err == nil {
+		return &Command{Message: &lurk.ChangeRoom{Type: lurk.TypeChangeRoom, RoomNumber: uint16(n)}}, nil
+	}
+	names := make([]string, 0, len(known.Connections))
+	for _, c := range known.Connections {
+		names = append(names, c.RoomName)
+	}
+	name, err := complete(args, names)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no exit called %q", err, args)
+	}
+	for _, c := range known.Connections {
+		if c.RoomName == name {
+			return &Command{Message: &lurk.ChangeRoom{Type: lurk.TypeChangeRoom, RoomNumber: c.RoomNumber}}, nil
+		}
+	}
+	return nil, ErrNoMatch
+}
+
+func interpretFight(string, *Completions) (*Command, error) {
+	return &Command{Message: &lurk.Fight{Type: lurk.TypeFight}}, nil
+}
+
+func interpretPVP(args string, known *Completions) (*Command, error) {
+	target, err := completeCharacter(args, known)
+	if err != nil {
+		return nil, err
+	}
+	return &Command{Message: &lurk.PVPFight{Type: lurk.TypePVPFight, TargetName: target}}, nil
+}
+
+func interpretLoot(args string, known *Completions) (*Command, error) {
+	target, err := completeCharacter(args, known)
+	if err != nil {
+		return nil, err
+	}
+	return &Command{Message: &lurk.Loot{Type: lurk.TypeLoot, TargetName: target}}, nil
+}
+
+// interpretTell takes the longest run of words that names a character in the room as the
+// recipient. Otherwise the first word is the recipient, shortened or not, since messages can go
+// to players anywhere on the server.
+func interpretTell(args string, known *Completions) (*Command, error) {
+	words := strings.Fields(args)
+	if len(words) < 2 {
+		return nil, ErrMissingArgs
+	}
+	recipient, text := "", ""
+	for i := len(words) - 1; i > 0 && recipient == ""; i-- {
+		candidate := strings.Join(words[:i], " ")
+		for _, c := range known.Characters {
+			if strings.EqualFold(c.Name, candidate) {
+				recipient, text = c.Name, strings.Join(words[i:], " ")
+				break
+			}
+		}
+	}
+	if recipient == "" {
+		var err error
+		if recipient, err = completeCharacter(words[0], known); err != nil {
+			return nil, err
+		}
+		text = strings.Join(words[1:], " ")
+	}
+
+	sender, err := senderOf(known)
+	if err != nil {
+		return nil, err
+	}
+	return &Command{Message: &lurk.Message{
+		Type:      lurk.TypeMessage,
+		Recipient: recipient,
+		Sender:    sender,
+		Text:      text,
+	}}, nil
+}
+
+// interpretChannel sends the whole line to a chat channel instead of a player.
+func interpretChannel(channel string) func(string, *Completions) (*Command, error) {
+	return func(args string, known *Completions) (*Command, error) {
+		if args == "" {
+			return nil, ErrMissingArgs
+		}
+		sender, err := senderOf(known)
+		if err != nil {
+			return nil, err
+		}
+		return &Command{Message: &lurk.Message{
+			Type:      lurk.TypeMessage,
+			Recipient: channel,
+			Sender:    sender,
+			Text:      args,
+		}}, nil
+	}
+}
+
+// senderOf is who messages come from. The server rejects messages without a sender, so none
+// are sent before the character has started.
+func senderOf(known *Completions) (string, error) {
+	if known.Self == nil {
+		return "", ErrNotStarted
+	}
+	return known.Self.Name, nil
+}
+
+func interpretStats(_ string, known *Completions) (*Command, error) {
+	c := known.Self
+	if c == nil {
+		return &Command{Reply: "You don't have a character yet."}, nil
+	}
+	state := "alive"
+	if !c.Flags[lurk.Alive] {
+		state = "dead"
+	}
+	return &Command{Reply: fmt.Sprintf("%s (%s) in room %d\nAttack: %d\nDefense: %d\nRegen: %d\nHealth: %d\nGold: %d",
+		c.Name, state, c.RoomNum, c.Attack, c.Defense, c.Regen, c.Health, c.Gold)}, nil
+}
+
+func interpretHelp(string, *Completions) (*Command, error) {
+	lines := []string{"Commands, which can be shortened:"}
+	for _, v := range verbs {
+		lines = append(lines, fmt.Sprintf("  %-24s also %s", v.usage, strings.Join(v.aliases, ", ")))
+	}
+	lines = append(lines, "Room and character names can be shortened too.")
+	return &Command{Reply: strings.Join(lines, "\n")}, nil
+}
+
+func interpretLeave(string, *Completions) (*Command, error) {
+	return &Command{Message: &lurk.Leave{Type: lurk.TypeLeave}}, nil
+}
+
+// completeCharacter expands a name from the characters in the room. Names that match nobody
+// are passed on as typed, the server has the final say.
+func completeCharacter(args string, known *Completions) (string, error) {
+	if args == "" {
+		return "", ErrMissingArgs
+	}
+	names := make([]string, 0, len(known.Characters))
+	for _, c := range known.Characters {
+		names = append(names, c.Name)
+	}
+	name, err := complete(args, names)
+	if errors.Is(err, ErrNoMatch) {
+		return args, nil
+	}
+	return name, err
+}
+
+// complete finds the one name starting with prefix, ignoring case and a leading "The".
+func complete(prefix string, names []string) (string, error) {
+	var found []string
+	for _, name := range names {
+		if strings.EqualFold(name, prefix) {
+			return name, nil
+		}
+		if hasPrefixFold(name, prefix) || hasPrefixFold(strings.TrimPrefix(name, "The "), prefix) {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", ErrNoMatch
+	case 1:
+		return found[0], nil
+	}
+	sort.Strings(found)
+	return "", fmt.Errorf("%w: %s", ErrAmbiguous, strings.Join(found, ", "))
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func TestInterpret(t *testing.T) {
+	a := assert.New(t)
+	known := &Completions{
+		Self: &lurk.Character{Name: "Ender", Attack: 50, Health: 100, Gold: 7, Flags: map[string]bool{lurk.Alive: true}},
+		Connections: []*lurk.Connection{
+			{RoomNumber: 1, RoomName: "Battle School"},
+			{RoomNumber: 2, RoomName: "The Barracks"},
+			{RoomNumber: 4, RoomName: "The Battle Room"},
+		},
+		Characters: []*lurk.Character{
+			{Name: "Bean"},
+			{Name: "Bonito de Madrid"},
+			{Name: "Petra Arkanian"},
+		},
+	}
+
+	interpret := func(line string) lurk.LurkMessage {
+		cmd, err := Interpret(line, known)
+		a.NoError(err)
+		return cmd.Message
+	}
+
+	t.Run("TestGo", func(_ *testing.T) {
+		a.True(interpret("go 3").(*lurk.ChangeRoom).RoomNumber == 3)
+		a.True(interpret("go barracks").(*lurk.ChangeRoom).RoomNumber == 2)
+		a.True(interpret("m BATTLE S").(*lurk.ChangeRoom).RoomNumber == 1)
+		a.True(interpret("go the battle r").(*lurk.ChangeRoom).RoomNumber == 4)
+
+		_, err := Interpret("go battle", known)
+		a.True(errors.Is(err, ErrAmbiguous))
+		_, err = Interpret("go eros", known)
+		a.True(errors.Is(err, ErrNoMatch))
+		_, err = Interpret("go", known)
+		a.True(errors.Is(err, ErrMissingArgs))
+	})
+	t.Run("TestTargets", func(_ *testing.T) {
+		_, ok := interpret("fight").(*lurk.Fight)
+		a.True(ok)
+		a.True(interpret("loot Bean").(*lurk.Loot).TargetName == "Bean")
+		a.True(interpret("pvp Petra").(*lurk.PVPFight).TargetName == "Petra Arkanian")
+		// Nobody in the room, so it's sent as typed.
+		a.True(interpret("pvp Alai").(*lurk.PVPFight).TargetName == "Alai")
+
+		_, err := Interpret("loot B", known)
+		a.True(errors.Is(err, ErrAmbiguous))
+		a.True(strings.Contains(err.Error(), "Bean, Bonito de Madrid"))
+		_, err = Interpret("loot", known)
+		a.True(errors.Is(err, ErrMissingArgs))
+	})
+	t.Run("TestTell", func(_ *testing.T) {
+		msg := interpret("tell Bean hello there").(*lurk.Message)
+		a.True(msg.Recipient == "Bean" && msg.Text == "hello there" && msg.Sender == "Ender")
+		msg = interpret("tell bonito de madrid  we meet again").(*lurk.Message)
+		a.True(msg.Recipient == "Bonito de Madrid" && msg.Text == "we meet again")
+		msg = interpret("t pet nice shot").(*lurk.Message)
+		a.True(msg.Recipient == "Petra Arkanian")
+		msg = interpret("tell Valentine hi").(*lurk.Message)
+		a.True(msg.Recipient == "Valentine")
+
+		_, err := Interpret("tell Bean", known)
+		a.True(errors.Is(err, ErrMissingArgs))
+	})
+	t.Run("TestChannels", func(_ *testing.T) {
+		msg := interpret("say Bean is  here").(*lurk.Message)
+		a.True(msg.Recipient == lurk.RoomChannel && msg.Text == "Bean is  here" && msg.Sender == "Ender")
+		msg = interpret("sh the enemy's gate is down").(*lurk.Message)
+		a.True(msg.Recipient == lurk.GlobalChannel && msg.Text == "the enemy's gate is down")
+
+		_, err := Interpret("say", known)
+		a.True(errors.Is(err, ErrMissingArgs))
+	})
+	t.Run("TestLocal", func(_ *testing.T) {
+		cmd, err := Interpret("stats", known)
+		a.NoError(err)
+		a.True(cmd.Message == nil)
+		a.True(strings.Contains(cmd.Reply, "Attack: 50"))
+		a.True(strings.Contains(cmd.Reply, "Gold: 7"))
+
+		cmd, err = Interpret("he", known)
+		a.NoError(err)
+		a.True(strings.Contains(cmd.Reply, "go <room number or name>"))
+
+		_, ok := interpret("quit").(*lurk.Leave)
+		a.True(ok)
+
+		for _, line := range []string{"", "dance", "l0ot"} {
+			_, err = Interpret(line, known)
+			a.True(errors.Is(err, ErrUnknownCommand))
+		}
+	})
+	t.Run("TestNotStarted", func(_ *testing.T) {
+		unstarted := &Completions{Characters: known.Characters}
+		_, err := Interpret("tell Bean hello", unstarted)
+		a.True(errors.Is(err, ErrNotStarted))
+		_, err = Interpret("say hello", unstarted)
+		a.True(errors.Is(err, ErrNotStarted))
+	})
+}
@@ -12,75 +12,121 @@ import (
 )
 
 // Endpoints used for post methods will not write back any data to the UI.
-// The response data will be used with the update endpoint.
+// The response data will be used with the update and events endpoints. The Manager routes
+// requests for a client's ID to these methods.
 
 const startEP = "/lurk-client/start/"
 
-func (c *Client) registerStartEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", startEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		char, err := c.getOrMakeCharacter(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		c.character = char
-		c.character.Flags[lurk.Alive] = true
-
-		if _, err = c.conn.Write(lurk.Marshal(c.character)); err != nil {
-			log.Printf("%s: could not write Character to server", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		_, err = c.conn.Write(lurk.Marshal(&lurk.Start{}))
-		if err != nil {
-			log.Printf("%s: could not write start to server", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-	})
+func (c *Client) serveStart(w http.ResponseWriter, r *http.Request) {
+	char, err := c.getOrMakeCharacter(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	char.Flags[lurk.Alive] = true
+	c.mu.Lock()
+	c.character = char
+	c.mu.Unlock()
+
+	if err = c.send(char); err != nil {
+		log.Printf("%s: could not write Character to server", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = c.send(&lurk.Start{})
+	if err != nil {
+		log.Printf("%s: could not write start to server", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	c.mu.Lock()
+	c.started = char
+	c.mu.Unlock()
 }
 
 const updateEP = "/lurk-client/update/"
 
-func (c *Client) registerUpdateEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", updateEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		messages := c.dequeueAll()
-		if len(messages) == 0 {
-			w.WriteHeader(http.StatusNoContent)
+func (c *Client) serveUpdate(w http.ResponseWriter, r *http.Request) {
+	if !c.waitUpdate(r.Context().Done()) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	jsonString, err := c.StateJSON(r.URL.Query().Get("view"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, err = w.Write(jsonString)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+const eventsEP = "/lurk-client/events/"
+
+// The events endpoint is a Server-Sent Events stream. Each event is a stateDelta, pushed as
+// soon as the server's messages are applied. The event ID is the newest log entry's seq, so a
+// browser reconnecting with Last-Event-ID gets the entries it missed.
+func (c *Client) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var seq uint64
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		var err error
+		if seq, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-
-		c.updateClientState(messages)
-
-		jsonString, err := json.Marshal(c.State)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	done := r.Context().Done()
+	var sent uint64
+	for first := true; ; first = false {
+		rev := c.waitChange(sent, pollTime, done)
+		select {
+		case <-done:
+			return
+		case <-c.ctx.Done():
 			return
+		default:
+		}
+		var err error
+		if rev == sent && !first {
+			// A comment keeps proxies from closing a quiet stream.
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		} else {
+			var ba []byte
+			if ba, seq, sent, err = c.deltaJSON(seq); err != nil {
+				return
+			}
+			_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", seq, ba)
 		}
-		_, err = w.Write(jsonString)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-	})
+		flusher.Flush()
+	}
 }
 
 const terminateEP = "/lurk-client/terminate/"
 
-// This endpoint shall be called when the page is closed.
-func (c *Client) registerTerminateEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", terminateEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		_, err := c.conn.Write(lurk.Marshal(&lurk.Leave{}))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-		log.Printf("ID:%v terminated from client", c.id)
-		c.cf()
-	})
+// This endpoint shall be called when the page is closed. Ending the session is enough, the
+// manager closes the client once this request is done and close sends the LEAVE.
+func (c *Client) serveTerminate(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	log.Printf("ID:%v terminated from client", c.id)
+	c.cf()
 }
 
 const changeRoomEP = "/lurk-client/change-room/"
@@ -89,47 +135,42 @@ type jsonChangeRoom struct {
 	RoomNumber string `json:"roomNumber"`
 }
 
-func (c *Client) registerChangeRoomEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", changeRoomEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		ba, err := io.ReadAll(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		ch := &jsonChangeRoom{}
-		if err := json.Unmarshal(ba, ch); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		roomNum, err := strconv.ParseInt(ch.RoomNumber, 10, 16)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		if _, err = c.conn.Write(lurk.Marshal(&lurk.ChangeRoom{
-			RoomNumber: uint16(roomNum),
-		})); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		w.WriteHeader(http.StatusOK)
-	})
-
+func (c *Client) serveChangeRoom(w http.ResponseWriter, r *http.Request) {
+	ba, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ch := &jsonChangeRoom{}
+	if err := json.Unmarshal(ba, ch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	roomNum, err := strconv.ParseInt(ch.RoomNumber, 10, 16)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err = c.send(&lurk.ChangeRoom{
+		RoomNumber: uint16(roomNum),
+	}); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
 }
 
 const fightEP = "/lurk-client/fight/"
 
-func (c *Client) registerFightEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", fightEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		if _, err := c.conn.Write(lurk.Marshal(&lurk.Fight{})); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-	})
+func (c *Client) serveFight(w http.ResponseWriter, r *http.Request) {
+	if err := c.send(&lurk.Fight{}); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 const lootEP = "/lurk-client/loot/"
@@ -138,28 +179,26 @@ type jsonLoot struct {
 	TargetName string `json:"target"`
 }
 
-func (c *Client) registerLootEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", lootEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		ba, err := io.ReadAll(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		loot := &jsonLoot{}
-		if err = json.Unmarshal(ba, loot); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		if _, err := c.conn.Write(lurk.Marshal(&lurk.Loot{
-			TargetName: loot.TargetName,
-		})); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-	})
+func (c *Client) serveLoot(w http.ResponseWriter, r *http.Request) {
+	ba, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	loot := &jsonLoot{}
+	if err = json.Unmarshal(ba, loot); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := c.send(&lurk.Loot{
+		TargetName: loot.TargetName,
+	}); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 const pvpFightEP = "/lurk-client/pvp/"
@@ -168,28 +207,26 @@ type jsonPVP struct {
 	TargetName string `json:"target"`
 }
 
-func (c *Client) registerPvpEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", pvpFightEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		ba, err := io.ReadAll(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		pvp := &jsonPVP{}
-		if err = json.Unmarshal(ba, pvp); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		if _, err := c.conn.Write(lurk.Marshal(&lurk.PVPFight{
-			TargetName: pvp.TargetName,
-		})); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-	})
+func (c *Client) servePVP(w http.ResponseWriter, r *http.Request) {
+	ba, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	pvp := &jsonPVP{}
+	if err = json.Unmarshal(ba, pvp); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := c.send(&lurk.PVPFight{
+		TargetName: pvp.TargetName,
+	}); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 const messageEP = "/lurk-client/message/"
@@ -199,29 +236,92 @@ type jsonMessage struct {
 	Text      string `json:"text"`
 }
 
-func (c *Client) registerMessageEP() {
-	http.HandleFunc(fmt.Sprintf("%s%d/", messageEP, c.id), func(w http.ResponseWriter, r *http.Request) {
-		ba, err := io.ReadAll(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+func (c *Client) serveMessage(w http.ResponseWriter, r *http.Request) {
+	ba, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	msg := &jsonMessage{}
+	if err = json.Unmarshal(ba, msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Messages come from the started character, there is none before START.
+	c.mu.Lock()
+	self := c.started
+	c.mu.Unlock()
+	if self == nil {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	if err := c.send(&lurk.Message{
+		Recipient: msg.Recipient,
+		Sender:    self.Name,
+		Text:      msg.Text,
+	}); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
 
-		msg := &jsonMessage{}
-		if err = json.Unmarshal(ba, msg); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+const commandEP = "/lurk-client/command/"
+
+type jsonCommand struct {
+	Line string `json:"line"`
+}
+
+type jsonCommandReply struct {
+	Reply string `json:"reply,omitempty"`
+	Error string `json:"error,omitempty"`
+}
 
-		if _, err := c.conn.Write(lurk.Marshal(&lurk.Message{
-			Recipient: msg.Recipient,
-			Sender:    c.character.Name,
-			Text:      msg.Text,
-		})); err != nil {
+// The command endpoint takes a typed line such as "go barracks". Messages for the server are
+// sent on, anything answered locally is written back as the reply.
+func (c *Client) serveCommand(w http.ResponseWriter, r *http.Request) {
+	ba, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	line := &jsonCommand{}
+	if err = json.Unmarshal(ba, line); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reply := &jsonCommandReply{}
+	status := http.StatusOK
+	cmd, err := Interpret(line.Line, c.completions())
+	switch {
+	case err != nil:
+		reply.Error = err.Error()
+		status = http.StatusBadRequest
+	case cmd.Message != nil:
+		if err = c.send(cmd.Message); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-
-		w.WriteHeader(http.StatusOK)
-	})
+		if cmd.Message.GetType() == lurk.TypeLeave {
+			log.Printf("ID:%v left by command", c.id)
+			c.cf()
+		}
+	default:
+		reply.Reply = cmd.Reply
+	}
+
+	jsonData, err := json.Marshal(reply)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(jsonData)
 }
@@ -1,10 +1,14 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,28 +34,31 @@ func TestHittingEndpoints(t *testing.T) {
 		}
 	}()
 
-	clientPort := cross.GetFreePort()
-	//nolint:errcheck // In a test.
-	go http.ListenAndServe(fmt.Sprintf("0.0.0.0:%v", clientPort), nil)
+	m := NewManager(time.Minute)
+	defer m.Close()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
 
 	client, err := New(&Config{
 		Port: fmt.Sprint(serverPort),
 	})
 	a.NoError(err)
-	client.Start()
+	m.Add(client)
 
-	_ = startClientConnection(a, serverConfig, &lurk.Character{
+	// Kept open, or the server would tell the client it left partway through.
+	guy := startClientConnection(a, serverConfig, &lurk.Character{
 		Name:       "Message Guy",
 		Attack:     10,
 		Defense:    20,
 		Regen:      30,
 		PlayerDesc: "A bot who is going to receive a message",
 	})
+	defer cross.LogOnErr(guy.Close)
 
 	tests := createGameActions(fmt.Sprint(client.id))
 	for _, test := range tests {
 		t.Run(test.name, func(_ *testing.T) {
-			resp, err := http.Post(fmt.Sprintf("http://localhost:%v%s", clientPort, test.endpoint), "application/json", bytes.NewBuffer(test.payload))
+			resp, err := http.Post(srv.URL+test.endpoint, "application/json", bytes.NewBuffer(test.payload))
 			a.NoError(err)
 			a.True(resp.StatusCode == test.expected)
 			_ = resp.Body.Close()
@@ -75,6 +82,12 @@ func createGameActions(id string) []gameAction {
 		"tester", "huh", "25", "25", "", "test",
 	})
 	return []gameAction{
+		{
+			"message before start",
+			messageEP + id,
+			http.StatusConflict,
+			[]byte(`{"recipient": "Message Guy", "text": "Too soon"}`),
+		},
 		{
 			"happy start",
 			startEP + id,
@@ -157,6 +170,24 @@ func createGameActions(id string) []gameAction {
 			[]byte(`"Test!"
 			}`),
 		},
+		{
+			"command happy",
+			commandEP + id,
+			http.StatusOK,
+			[]byte(`{"line": "tell Message Guy hello again"}`),
+		},
+		{
+			"command stats",
+			commandEP + id,
+			http.StatusOK,
+			[]byte(`{"line": "stats"}`),
+		},
+		{
+			"command unknown",
+			commandEP + id,
+			http.StatusBadRequest,
+			[]byte(`{"line": "dance"}`),
+		},
 		{
 			"update happy",
 			updateEP + id,
@@ -171,3 +202,80 @@ func createGameActions(id string) []gameAction {
 		},
 	}
 }
+
+func TestEvents(t *testing.T) {
+	a := assert.New(t)
+
+	c := newClient(nil, time.Now().UnixMicro())
+	c.ctx, c.cf = context.WithCancel(context.Background())
+	defer c.cf()
+	c.updateClientState([]lurk.LurkMessage{
+		&lurk.Game{GameDesc: "Battle School"},
+		&lurk.Room{RoomNumber: 1, RoomName: "Battle School"},
+	})
+	// Without a server connection, so it isn't started.
+	m := NewManager(time.Minute)
+	m.sessions[c.id] = &session{c: c, lastSeen: time.Now()}
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	url := fmt.Sprintf("%s%s%d/", srv.URL, eventsEP, c.id)
+
+	// readEvent returns the next event's ID and data, skipping keep-alives.
+	readEvent := func(r *bufio.Reader) (id string, delta *stateDelta) {
+		for {
+			line, err := r.ReadString('\n')
+			a.NoError(err)
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimSpace(strings.TrimPrefix(line, "id: "))
+			case strings.HasPrefix(line, "data: "):
+				delta = &stateDelta{}
+				a.NoError(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), delta))
+				return id, delta
+			}
+		}
+	}
+	stream := func(lastID string) (*http.Response, *bufio.Reader) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		a.NoError(err)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		a.NoError(err)
+		a.True(resp.StatusCode == http.StatusOK)
+		a.True(resp.Header.Get("Content-Type") == "text/event-stream")
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	resp, r := stream("")
+	id, delta := readEvent(r)
+	a.True(id == "1")
+	a.True(delta.Full && len(delta.Log) == 1)
+	a.True(delta.Room.RoomName == "Battle School")
+
+	c.updateClientState([]lurk.LurkMessage{&lurk.Message{Sender: "Bean", Text: "one"}})
+	id, delta = readEvent(r)
+	a.True(id == "2")
+	a.True(!delta.Full && len(delta.Log) == 1 && delta.Log[0].Text == "one")
+	_ = resp.Body.Close()
+
+	// Missed while disconnected.
+	c.updateClientState([]lurk.LurkMessage{
+		&lurk.Message{Sender: "Bean", Text: "two"},
+		&lurk.Message{Sender: "Bean", Text: "three"},
+	})
+	resp, r = stream(id)
+	defer cross.LogOnErr(resp.Body.Close)
+	id, delta = readEvent(r)
+	a.True(id == "4")
+	a.True(!delta.Full && len(delta.Log) == 2 && delta.Log[0].Text == "two" && delta.Log[1].Text == "three")
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	a.NoError(err)
+	req.Header.Set("Last-Event-ID", "nope")
+	bad, err := http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.True(bad.StatusCode == http.StatusBadRequest)
+	_ = bad.Body.Close()
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// htmlState is the state as it was before the UI drew it itself, with every section already
+// rendered. Each field is the innerHTML of one element on the page.
+type htmlState struct {
+	Info        string `json:"info"`
+	Rooms       string `json:"rooms"`
+	Connections string `json:"connections"`
+	Players     string `json:"players"`
+	Id          int64  `json:"id"`
+}
+
+func renderHTML(s *ClientState) *htmlState {
+	h := &htmlState{Id: s.Id}
+	if s.Version != nil {
+		extensionBytes := 0
+		for _, v := range s.Version.Extensions {
+			extensionBytes += len(v)
+		}
+		h.Info += fmt.Sprintf("LURK Version %v.%v | %v Bytes of Extensions\n", s.Version.Major, s.Version.Minor, extensionBytes)
+	}
+	if s.Game != nil {
+		h.Info += fmt.Sprintf("Stat Limit: %v\nInitial Points: %v\n%s\n", s.Game.StatLimit, s.Game.InitialPoints, s.Game.GameDesc)
+	}
+	for _, entry := range s.Log {
+		h.Info += renderEntry(entry)
+	}
+
+	if s.Room != nil {
+		h.Rooms = fmt.Sprintf(roomTemplate, s.Room.RoomNumber, s.Room.RoomName, s.Room.RoomDesc)
+	}
+	for _, connection := range s.Connections {
+		h.Connections += fmt.Sprintf(connectionTemplate, connection.RoomNumber, connection.RoomName, connection.RoomDesc)
+	}
+
+	if s.Self == nil {
+		return h
+	}
+	h.Players = fmt.Sprintf(userTemplate, s.Self.Name, s.Self.Attack, s.Self.Defense, s.Self.Regen, s.Self.Health, s.Self.Gold)
+	for _, character := range s.Characters {
+		switch {
+		case !character.Flags[lurk.Alive]:
+			h.Players += fmt.Sprintf(deadEntity, character.Name, character.Attack, character.Defense, character.Regen, character.Gold)
+		case character.Flags[lurk.Monster]:
+			h.Players += fmt.Sprintf(monsterTemplate, character.Name, character.Attack, character.Defense, character.Regen, character.Health)
+		default:
+			h.Players += fmt.Sprintf(characterTemplate, character.Name, character.Attack, character.Defense, character.Regen, character.Health, character.Gold)
+		}
+	}
+	return h
+}
+
+// renderEntry draws a log entry. The game and version are drawn at the top instead.
+func renderEntry(entry *LogEntry) string {
+	switch entry.Kind {
+	case LogArrival:
+		return lineBreak + fmt.Sprintf(newPlayer, entry.Sender, entry.Text)
+	case LogMessage:
+		return lineBreak + fmt.Sprintf(messageTemplate, entry.Sender, entry.Recipient, entry.Text)
+	case LogNarration:
+		return lineBreak + fmt.Sprintf(narratorTemplate, entry.Sender, entry.Recipient, entry.Text)
+	case LogError:
+		return lineBreak + fmt.Sprintf(errorTemplate, entry.Code, entry.Text)
+	case LogSent:
+		return lineBreak + sentMessageTemplate
+	}
+	return ""
+}
+
+const characterTemplate = `
+%s
+  | Attack: %v
+  | Defense: %v
+  | Regen: %v
+  | Health: %v
+  | Gold: %v
+  `
+
+const monsterTemplate = `
+<span style="color: red;">%s</span>
+  | Attack: %v
+  | Defense: %v
+  | Regen: %v
+  | Health: %v
+  `
+
+// Think about showing their descriptions somehow. Maybe a button that will list everything.
+const userTemplate = `
+<span style="color: green;">%s</span>
+  | Attack: %v
+  | Defense: %v
+  | Regen: %v
+  | Health: %v
+  | Gold: %v
+  `
+
+const deadEntity = `
+<span style="background-color: red; color: white;">%s</span>
+  | Attack: %v
+  | Defense: %v
+  | Regen: %v
+  | Gold: %v
+`
+
+const errorTemplate = `
+<span style="color: red;">Error #%d</span>: %s
+`
+
+const roomTemplate = `
+(Current Room) %v: %s
+-> %s
+`
+
+const connectionTemplate = `
+%v: %s
+-> %s
+`
+
+const messageTemplate = `
+%s => %s: %s
+`
+
+const sentMessageTemplate = `
+Successfully sent message.
+`
+
+const narratorTemplate = `
+<span style="color: purple;">%s</span> => %s: %s`
+
+const lineBreak = `
+==================================================
+`
+
+const newPlayer = `
+%s: 
+-> %s
+`
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// Connection statuses, in ClientState.Status.
+const (
+	StatusConnected    = "connected"
+	StatusReconnecting = "reconnecting"
+)
+
+var errDisconnected = errors.New("not connected to the server, reconnecting")
+
+// Reconnect attempts wait twice as long as the last, up to maxReconnectDelay.
+var (
+	reconnectDelay    = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+const handshakeTimeout = 5 * time.Second
+
+// send writes to the server, unless the connection is being replaced.
+func (c *Client) send(msg lurk.LurkMessage) error {
+	c.mu.Lock()
+	conn, status := c.conn, c.State.Status
+	c.mu.Unlock()
+	if status != StatusConnected {
+		return errDisconnected
+	}
+	return conn.Send(msg)
+}
+
+// reconnect dials the server until it gets back in, or the client is closed. If the player had
+// started, their character is sent again and started, and the server decides where they are.
+func (c *Client) reconnect(cause error) bool {
+	c.mu.Lock()
+	_ = c.conn.Close()
+	c.State.Status = StatusReconnecting
+	c.State.addLog(&LogEntry{Kind: LogStatus, Text: fmt.Sprintf("Lost the connection to the server (%v), reconnecting...", cause)})
+	c.notifyChange()
+	c.mu.Unlock()
+
+	delay := reconnectDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+
+		conn, err := c.redial()
+		if err != nil {
+			log.Printf("ID:%v reconnect attempt %d: %v", c.id, attempt, err)
+			continue
+		}
+		c.mu.Lock()
+		c.conn = conn
+		c.State.Status = StatusConnected
+		c.State.addLog(&LogEntry{Kind: LogStatus, Text: "Reconnected to the server."})
+		c.notifyChange()
+		c.mu.Unlock()
+		log.Printf("ID:%v reconnected after %d attempts", c.id, attempt)
+		return true
+	}
+}
+
+// redial connects and, if the player had started, logs back in. Everything the server sends
+// on the way is applied to the state.
+func (c *Client) redial() (*lurk.Conn, error) {
+	netConn, err := dial(c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	conn := lurk.NewConn(netConn)
+	ok := false
+	defer func() {
+		if !ok {
+			_ = conn.Close()
+		}
+	}()
+
+	// The VERSION and GAME are the same as the first time.
+	greeting, err := readAllMessagesInBuffer(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(greeting) == 0 {
+		return nil, cross.ErrNotInitialized
+	}
+
+	// The old room and everyone in it are gone, the server sends where the player is now.
+	c.mu.Lock()
+	character := c.started
+	c.State.Room = nil
+	c.State.Connections = []*lurk.Connection{}
+	clear(c.State.characters)
+	c.updateCharacters()
+	c.mu.Unlock()
+
+	if character != nil {
+		if err = c.handshake(conn, character, lurk.TypeCharacter); err != nil {
+			return nil, err
+		}
+		if err = c.handshake(conn, &lurk.Start{}, lurk.TypeStart); err != nil {
+			return nil, err
+		}
+	}
+
+	ok = true
+	return conn, nil
+}
+
+// handshake sends msg and waits for it to be accepted, applying anything else that arrives.
+func (c *Client) handshake(conn *lurk.Conn, msg lurk.LurkMessage, action lurk.MessageType) error {
+	if err := conn.Send(msg); err != nil {
+		return err
+	}
+	conn.SetReadTimeout(handshakeTimeout)
+	defer conn.SetReadTimeout(0)
+	for {
+		reply, err := conn.Receive()
+		if errors.Is(err, cross.ErrInvalidMessageType) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		switch reply := reply.(type) {
+		case *lurk.Error:
+			// Most likely the server hasn't noticed the old connection is gone yet.
+			return fmt.Errorf("%s rejected: %s", action, reply.ErrMessage)
+		case *lurk.Accept:
+			if reply.Action == action {
+				return nil
+			}
+		}
+		c.updateClientState([]lurk.LurkMessage{reply})
+	}
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/server/code/server"
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+// proxy forwards connections to a server, and can drop them to play a network failure.
+type proxy struct {
+	l      net.Listener
+	target string
+
+	mu     sync.Mutex
+	conns  []net.Conn
+	paused bool
+}
+
+func newProxy(a *assert.Assert, target string) *proxy {
+	l, err := net.Listen("tcp", "localhost:0")
+	a.NoError(err)
+	p := &proxy{l: l, target: target}
+	go p.run()
+	return p
+}
+
+func (p *proxy) run() {
+	for {
+		conn, err := p.l.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		paused := p.paused
+		p.mu.Unlock()
+		if paused {
+			_ = conn.Close()
+			continue
+		}
+		upstream, err := net.Dial("tcp", p.target)
+		if err != nil {
+			_ = conn.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, conn, upstream)
+		p.mu.Unlock()
+		go func() { _, _ = io.Copy(upstream, conn); _ = upstream.Close() }()
+		go func() { _, _ = io.Copy(conn, upstream); _ = conn.Close() }()
+	}
+}
+
+// cut drops every connection, and turns new ones away while paused.
+func (p *proxy) cut(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = paused
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *proxy) port() string {
+	return fmt.Sprint(p.l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestReconnect(t *testing.T) {
+	a := assert.New(t)
+	serverPort := cross.GetFreePort()
+	cfs, err := server.New(&server.Config{Port: serverPort})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+	p := newProxy(a, fmt.Sprintf("localhost:%d", serverPort))
+	defer cross.LogOnErr(p.l.Close)
+
+	reconnectDelay, maxReconnectDelay = 10*time.Millisecond, 50*time.Millisecond
+	defer func() {
+		reconnectDelay, maxReconnectDelay = 500*time.Millisecond, 30*time.Second
+	}()
+
+	m := NewManager(time.Minute)
+	defer m.Close()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	c, err := New(&Config{Hostname: "localhost", Port: p.port()})
+	a.NoError(err)
+	m.Add(c)
+
+	post := func(endpoint string, body any) int {
+		ba, err := json.Marshal(body)
+		a.NoError(err)
+		resp, err := http.Post(fmt.Sprintf("%s%s%d/", srv.URL, endpoint, c.id), "application/json", bytes.NewReader(ba))
+		a.NoError(err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	inRoom := func(room uint16) func() bool {
+		return func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.State.Status == StatusConnected && c.State.Room != nil && c.State.Room.RoomNumber == room
+		}
+	}
+	status := func() string {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.State.Status
+	}
+
+	a.True(post(startEP, &jsonCharacter{"Ender", "30", "30", "30", "yes", "Third"}) == http.StatusOK)
+	a.Eventually(inRoom(1), 2*time.Second, 10*time.Millisecond)
+	a.True(post(changeRoomEP, &jsonChangeRoom{"3"}) == http.StatusOK)
+	a.Eventually(inRoom(3), 2*time.Second, 10*time.Millisecond)
+	a.True(hasCharacter(c, "Petra Arkanian"))
+
+	p.cut(true)
+	a.Eventually(func() bool { return status() == StatusReconnecting }, 2*time.Second, 10*time.Millisecond)
+	a.True(post(fightEP, struct{}{}) == http.StatusInternalServerError)
+	a.True(hasLog(c, LogStatus, "Lost the connection"))
+
+	// Logged in again, back where the server starts everyone.
+	p.cut(false)
+	a.Eventually(inRoom(1), 5*time.Second, 10*time.Millisecond)
+	a.True(hasLog(c, LogStatus, "Reconnected"))
+	a.True(!hasCharacter(c, "Petra Arkanian"))
+	a.True(c.completions().Self.Name == "Ender")
+	a.True(post(changeRoomEP, &jsonChangeRoom{"2"}) == http.StatusOK)
+	a.Eventually(inRoom(2), 2*time.Second, 10*time.Millisecond)
+}
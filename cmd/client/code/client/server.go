@@ -2,23 +2,34 @@ package client
 
 import (
 	"context"
-	"log"
+	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"time"
 
 	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
 )
 
 // Config uses a string value for port since it is being received as a JSON object.
 type Config struct {
 	Hostname, Port string
+	// TLS dials the server over TLS. The certificate is verified against Hostname.
+	TLS bool
+	// InsecureSkipVerify accepts any certificate, such as the one from a server running in
+	// self signed mode.
+	InsecureSkipVerify bool
+	// RootCAs replaces the system roots when verifying the server. It is set by the backend,
+	// never by the UI.
+	RootCAs *x509.CertPool `json:"-"`
 }
 
 func New(cfg *Config) (*Client, error) {
-	conn, err := net.Dial("tcp", cfg.Hostname+":"+cfg.Port)
+	netConn, err := dial(cfg)
 	if err != nil {
 		return nil, err
 	}
+	conn := lurk.NewConn(netConn)
 
 	lurkMessages, err := readAllMessagesInBuffer(conn)
 	if err != nil {
@@ -31,27 +42,33 @@ func New(cfg *Config) (*Client, error) {
 	id := time.Now().UnixMicro()
 
 	c := newClient(conn, id)
+	c.cfg = cfg
 
 	c.updateClientState(lurkMessages)
+	// The setup endpoint returns this state, so updates start after it.
+	c.served = c.rev
 
 	return c, nil
 }
 
+func dial(cfg *Config) (net.Conn, error) {
+	address := net.JoinHostPort(cfg.Hostname, cfg.Port)
+	if !cfg.TLS {
+		return net.Dial("tcp", address)
+	}
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName:         cfg.Hostname,
+			RootCAs:            cfg.RootCAs,
+			InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // Opt in for development servers.
+			MinVersion:         tls.VersionTLS12,
+		},
+	}
+	return dialer.Dial("tcp", address)
+}
+
+// Start reads from the server in the background. Requests reach the client through a Manager.
 func (c *Client) Start() {
-	c.registerEndpoints()
 	c.ctx, c.cf = context.WithCancel(context.Background())
 	go c.readFromServer()
 }
-
-func (c *Client) registerEndpoints() {
-	c.registerStartEP()
-	c.registerUpdateEP()
-	c.registerTerminateEP()
-	c.registerChangeRoomEP()
-	c.registerFightEP()
-	c.registerLootEP()
-	c.registerPvpEP()
-	c.registerMessageEP()
-	// register more.
-	log.Printf("Registered endpoints for ID:%d", c.id)
-}
@@ -1,12 +1,15 @@
 package client
 
 import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
-	"strings"
 	"testing"
 	"time"
 
@@ -37,9 +40,10 @@ func TestStartingServer(t *testing.T) {
 			Port: fmt.Sprint(serverPort),
 		}
 
-		clientPort := cross.GetFreePort()
-		//nolint:errcheck // In a test.
-		go http.ListenAndServe(fmt.Sprintf("0.0.0.0:%v", clientPort), nil)
+		m := NewManager(time.Minute)
+		defer m.Close()
+		srv := httptest.NewServer(m)
+		defer srv.Close()
 
 		filename := filepath.Join(os.TempDir(), "test.txt")
 		fd, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, os.ModePerm)
@@ -58,16 +62,15 @@ func TestStartingServer(t *testing.T) {
 
 		client, err := New(clientConfig)
 		a.NoError(err)
-		a.True(strings.Contains(client.State.Info, "LURK")) // from the version
+		a.True(client.State.Version != nil)
+		a.True(hasLog(client, LogVersion, "LURK"))
 		a.True(client.id != 0)
 		a.True(client.id == client.State.Id)
 		a.NotNil(client.Game)
 
-		client.Start()
-		defer client.cf()
-		time.Sleep(50 * time.Millisecond)
+		m.Add(client)
 
-		uri := fmt.Sprintf("http://localhost:%d", clientPort) + "%v" + fmt.Sprintf("%d/", client.id)
+		uri := srv.URL + "%v" + fmt.Sprintf("%d/", client.id)
 		resp, err := http.Post(fmt.Sprintf(uri, startEP), "application/json", file)
 		a.NoError(err)
 		defer cross.LogOnErr(resp.Body.Close)
@@ -78,7 +81,7 @@ func TestStartingServer(t *testing.T) {
 			a.NoError(err)
 			a.True(resp.StatusCode == http.StatusOK)
 			defer cross.LogOnErr(resp.Body.Close)
-			return strings.Contains(client.State.Players, "Colonel Graph")
+			return hasCharacter(client, "Colonel Graph")
 		}, time.Second*2, time.Millisecond*100)
 
 		bot := startClientConnection(a, serverConfig, &lurk.Character{
@@ -101,7 +104,7 @@ func TestStartingServer(t *testing.T) {
 			a.NoError(err)
 			a.True(resp.StatusCode == http.StatusOK)
 			defer cross.LogOnErr(resp.Body.Close)
-			return strings.Contains(client.State.Info, "HELLO")
+			return hasLog(client, LogMessage, "HELLO")
 		}, time.Second*2, time.Millisecond*100)
 	})
 	t.Run("TestBadDial", func(_ *testing.T) {
@@ -185,3 +188,51 @@ func startClientConnection(a *assert.Assert, cfg *server.Config, char *lurk.Char
 
 	return conn
 }
+
+func TestTLSServer(t *testing.T) {
+	a := assert.New(t)
+
+	cert, err := cross.SelfSignedCertificate("localhost")
+	a.NoError(err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	a.NoError(err)
+	a.NoError(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	a.NoError(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	serverPort := cross.GetFreePort()
+	cfs, err := server.New(&server.Config{
+		Port: serverPort,
+		TLS:  &server.TLSConfig{CertFile: certFile, KeyFile: keyFile},
+	})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+
+	t.Run("TestVerified", func(_ *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(cert.Leaf)
+		c, err := New(&Config{Hostname: "localhost", Port: fmt.Sprint(serverPort), TLS: true, RootCAs: pool})
+		a.NoError(err)
+		a.NotNil(c.Game)
+		a.NoError(c.conn.Close())
+	})
+	t.Run("TestUntrusted", func(_ *testing.T) {
+		_, err := New(&Config{Hostname: "localhost", Port: fmt.Sprint(serverPort), TLS: true})
+		a.Error(err)
+	})
+	t.Run("TestSkipVerify", func(_ *testing.T) {
+		c, err := New(&Config{Hostname: "localhost", Port: fmt.Sprint(serverPort), TLS: true, InsecureSkipVerify: true})
+		a.NoError(err)
+		a.NotNil(c.Game)
+		a.NoError(c.conn.Close())
+	})
+	t.Run("TestPlainToTLS", func(_ *testing.T) {
+		_, err := New(&Config{Hostname: "localhost", Port: fmt.Sprint(serverPort)})
+		a.Error(err)
+	})
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// Prefix is the path every client endpoint lives under, as /lurk-client/{action}/{id}/.
+const Prefix = "/lurk-client/"
+
+const sessionsEP = "/lurk-client/sessions/"
+
+// endpoints maps each action to its handler.
+var endpoints = map[string]func(*Client, http.ResponseWriter, *http.Request){
+	startEP:      (*Client).serveStart,
+	updateEP:     (*Client).serveUpdate,
+	eventsEP:     (*Client).serveEvents,
+	terminateEP:  (*Client).serveTerminate,
+	changeRoomEP: (*Client).serveChangeRoom,
+	fightEP:      (*Client).serveFight,
+	lootEP:       (*Client).serveLoot,
+	pvpFightEP:   (*Client).servePVP,
+	messageEP:    (*Client).serveMessage,
+	commandEP:    (*Client).serveCommand,
+}
+
+// Manager owns the running clients and routes requests to them. Clients nobody has made a
+// request to for the idle timeout are disconnected from their server and forgotten.
+type Manager struct {
+	idle time.Duration
+
+	mu       sync.Mutex
+	sessions map[int64]*session
+	done     chan struct{}
+}
+
+type session struct {
+	c        *Client
+	lastSeen time.Time
+	// requests is how many requests are being served, an open event stream being one.
+	requests int
+}
+
+// NewManager starts a manager that expires sessions after idle.
+func NewManager(idle time.Duration) *Manager {
+	m := &Manager{
+		idle:     idle,
+		sessions: map[int64]*session{},
+		done:     make(chan struct{}),
+	}
+	go m.expire()
+	return m
+}
+
+// Add starts c and routes requests for its ID to it.
+func (m *Manager) Add(c *Client) {
+	c.Start()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[c.id] = &session{c: c, lastSeen: time.Now()}
+	log.Printf("ID:%d started, %d active sessions", c.id, len(m.sessions))
+}
+
+// Active is the number of sessions.
+func (m *Manager) Active() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// Close disconnects every session.
+func (m *Manager) Close() {
+	close(m.done)
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = map[int64]*session{}
+	m.mu.Unlock()
+	for _, s := range sessions {
+		s.c.close()
+	}
+}
+
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == sessionsEP {
+		m.serveSessions(w)
+		return
+	}
+	action, idText, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	serve, ok := endpoints[Prefix+action+"/"]
+	id, err := strconv.ParseInt(strings.TrimSuffix(idText, "/"), 10, 64)
+	if !ok || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s := m.begin(id)
+	if s == nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer m.end(id, s)
+	serve(s.c, w, r)
+}
+
+// begin finds the session for a request, or nil.
+func (m *Manager) begin(id int64) *session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil
+	}
+	s.requests++
+	s.lastSeen = time.Now()
+	return s
+}
+
+// end forgets the session right away if the request ended it.
+func (m *Manager) end(id int64, s *session) {
+	m.mu.Lock()
+	s.requests--
+	s.lastSeen = time.Now()
+	ended := s.c.ctx.Err() != nil && m.sessions[id] == s
+	if ended {
+		m.remove(id, "ended")
+	}
+	m.mu.Unlock()
+	if ended {
+		s.c.close()
+	}
+}
+
+// remove must be called with m.mu held. The client is closed by the caller, outside the lock.
+func (m *Manager) remove(id int64, why string) {
+	delete(m.sessions, id)
+	log.Printf("ID:%d %s, %d active sessions", id, why, len(m.sessions))
+}
+
+func (m *Manager) expire() {
+	ticker := time.NewTicker(max(m.idle/4, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+		var gone []*Client
+		m.mu.Lock()
+		for id, s := range m.sessions {
+			switch {
+			case s.c.ctx.Err() != nil:
+				m.remove(id, "ended")
+			case s.requests == 0 && time.Since(s.lastSeen) > m.idle:
+				m.remove(id, "expired")
+			default:
+				continue
+			}
+			gone = append(gone, s.c)
+		}
+		m.mu.Unlock()
+		for _, c := range gone {
+			c.close()
+		}
+	}
+}
+
+type jsonSessions struct {
+	Active int `json:"active"`
+}
+
+func (m *Manager) serveSessions(w http.ResponseWriter) {
+	jsonData, err := json.Marshal(&jsonSessions{Active: m.Active()})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(jsonData)
+}
+
+// close leaves the game and hangs up. It is safe to call more than once.
+func (c *Client) close() {
+	c.cf()
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	_ = conn.Send(&lurk.Leave{})
+	_ = conn.Close()
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/server/code/server"
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+func TestManager(t *testing.T) {
+	a := assert.New(t)
+	serverPort := cross.GetFreePort()
+	cfs, err := server.New(&server.Config{Port: serverPort})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+
+	const idle = 100 * time.Millisecond
+	m := NewManager(idle)
+	defer m.Close()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	newSession := func() *Client {
+		c, err := New(&Config{Port: fmt.Sprint(serverPort)})
+		a.NoError(err)
+		m.Add(c)
+		return c
+	}
+	post := func(endpoint string, c *Client) int {
+		resp, err := http.Post(fmt.Sprintf("%s%s%d/", srv.URL, endpoint, c.id), "application/json", nil)
+		a.NoError(err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("TestRouting", func(_ *testing.T) {
+		c := newSession()
+		a.True(m.Active() == 1)
+
+		resp, err := http.Get(srv.URL + sessionsEP)
+		a.NoError(err)
+		ba, err := io.ReadAll(resp.Body)
+		a.NoError(err)
+		_ = resp.Body.Close()
+		sessions := &jsonSessions{}
+		a.NoError(json.Unmarshal(ba, sessions))
+		a.True(sessions.Active == 1)
+
+		a.True(post(fightEP, c) == http.StatusOK)
+		for _, path := range []string{"dance/" + fmt.Sprint(c.id), "fight/12", "fight/nope", "fight"} {
+			resp, err := http.Post(srv.URL+Prefix+path, "application/json", nil)
+			a.NoError(err)
+			_ = resp.Body.Close()
+			a.True(resp.StatusCode == http.StatusNotFound)
+		}
+
+		a.True(post(terminateEP, c) == http.StatusOK)
+		a.True(m.Active() == 0)
+		a.True(c.ctx.Err() != nil)
+		a.True(post(fightEP, c) == http.StatusNotFound)
+	})
+	t.Run("TestExpiry", func(_ *testing.T) {
+		c := newSession()
+		a.Eventually(func() bool {
+			return m.Active() == 0
+		}, 10*idle, idle/10)
+		a.True(c.ctx.Err() != nil)
+	})
+	t.Run("TestOpenStream", func(_ *testing.T) {
+		c := newSession()
+		resp, err := http.Get(fmt.Sprintf("%s%s%d/", srv.URL, eventsEP, c.id))
+		a.NoError(err)
+		time.Sleep(3 * idle)
+		a.True(m.Active() == 1)
+
+		_ = resp.Body.Close()
+		a.Eventually(func() bool {
+			return m.Active() == 0
+		}, 10*idle, idle/10)
+	})
+}
@@ -1,174 +1,213 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/Clayal10/enders_game/pkg/cross"
 	"github.com/Clayal10/enders_game/pkg/lurk"
 )
 
-// Each field correlates to a section of the UI that should be updated in the JS.
-// This struct will be json'd and each field should be added to the innerHTML of the HTML element,
-// and should not overwrite the data already in it.
+// ClientState is what the UI draws. It is plain data, how it looks is up to the JS.
 type ClientState struct {
-	Info        string `json:"info"`
-	Rooms       string `json:"rooms"`
-	Connections string `json:"connections"`
-	Players     string `json:"players"`
-	Id          int64  `json:"id"`
+	Id int64 `json:"id"`
+	// Status is StatusConnected, or StatusReconnecting while the server can't be reached.
+	Status      string             `json:"status"`
+	Game        *lurk.Game         `json:"game,omitempty"`
+	Version     *lurk.Version      `json:"version,omitempty"`
+	Room        *lurk.Room         `json:"room,omitempty"`
+	Connections []*lurk.Connection `json:"connections"`
+	Self        *lurk.Character    `json:"self,omitempty"`
+	// Characters are everyone else in the room, by name.
+	Characters []*lurk.Character `json:"characters"`
+	// Log holds the newest maxLog entries, oldest first.
+	Log []*LogEntry `json:"log"`
 
 	characters map[string]*lurk.Character `json:"-"`
+	seq        uint64                     `json:"-"`
 }
 
+// LogEntry kinds.
+const (
+	LogGame      = "game"
+	LogVersion   = "version"
+	LogArrival   = "arrival"
+	LogMessage   = "message"
+	LogNarration = "narration"
+	LogSent      = "sent"
+	LogError     = "error"
+	LogStatus    = "status"
+)
+
+// LogEntry is one line of the game log.
+type LogEntry struct {
+	// Seq counts up from 1 for the life of the client, so the UI can tell which entries are new.
+	Seq       uint64        `json:"seq"`
+	Kind      string        `json:"type"`
+	Sender    string        `json:"sender,omitempty"`
+	Recipient string        `json:"recipient,omitempty"`
+	Text      string        `json:"text"`
+	Code      cross.ErrCode `json:"code,omitempty"`
+	Time      time.Time     `json:"time"`
+}
+
+const maxLog = 200
+
 func newClientState(id int64) *ClientState {
 	return &ClientState{
-		Id:         id,
-		characters: map[string]*lurk.Character{},
+		Id:          id,
+		Status:      StatusConnected,
+		Connections: []*lurk.Connection{},
+		Characters:  []*lurk.Character{},
+		Log:         []*LogEntry{},
+		characters:  map[string]*lurk.Character{},
+	}
+}
+
+func (s *ClientState) addLog(entry *LogEntry) {
+	s.seq++
+	entry.Seq = s.seq
+	entry.Time = time.Now()
+	if len(s.Log) == maxLog {
+		s.Log = s.Log[1:]
 	}
+	s.Log = append(s.Log, entry)
 }
 
-// updateClientState will take a slice of LurkMessage interface objects and modifies the ClientUpdate field with
-// the proper text fields.
+// updateClientState applies messages from the server to the client state.
 func (c *Client) updateClientState(lurkMessages []lurk.LurkMessage) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.notifyChange()
 	for _, msg := range lurkMessages {
 		switch msg.GetType() {
 		case lurk.TypeGame:
 			game := msg.(*lurk.Game)
 			c.Game = game
-			c.State.Info += fmt.Sprintf("Stat Limit: %v\nInitial Points: %v\n%s\n", game.StatLimit, game.InitialPoints, game.GameDesc)
+			c.State.Game = game
+			c.State.addLog(&LogEntry{Kind: LogGame, Text: game.GameDesc})
 		case lurk.TypeVersion:
 			version := msg.(*lurk.Version)
-			extensionBytes := 0
-			for _, v := range version.Extensions {
-				extensionBytes += len(v)
-			}
-			c.State.Info += fmt.Sprintf("LURK Version %v.%v | %v Bytes of Extensions\n", version.Major, version.Minor, extensionBytes)
+			c.State.Version = version
+			c.State.addLog(&LogEntry{Kind: LogVersion, Text: fmt.Sprintf("LURK Version %v.%v", version.Major, version.Minor)})
 		case lurk.TypeCharacter:
 			character := msg.(*lurk.Character)
-			if _, ok := c.State.characters[character.Name]; !ok && character.Name != c.character.Name && character.RoomNum == c.character.RoomNum {
-				c.State.Info += lineBreak
-				c.State.Info += fmt.Sprintf(newPlayer, character.Name, character.PlayerDesc)
+			self := c.character != nil && character.Name == c.character.Name
+			if _, ok := c.State.characters[character.Name]; !ok && !self && c.character != nil && character.RoomNum == c.character.RoomNum {
+				c.State.addLog(&LogEntry{Kind: LogArrival, Sender: character.Name, Text: character.PlayerDesc})
 			}
 
 			c.State.characters[character.Name] = character
-			if character.Name == c.character.Name {
+			if self {
 				c.character = character
 			}
-			c.stringifyCharacters()
+			c.updateCharacters()
 		case lurk.TypeRoom:
 			room := msg.(*lurk.Room)
-			c.State.Connections = ""
-			c.State.Rooms = fmt.Sprintf(roomTemplate, room.RoomNumber, room.RoomName, room.RoomDesc)
+			c.State.Room = room
+			c.State.Connections = []*lurk.Connection{}
 		case lurk.TypeConnection:
-			connection := msg.(*lurk.Connection)
-			c.State.Connections += fmt.Sprintf(connectionTemplate, connection.RoomNumber, connection.RoomName, connection.RoomDesc)
+			c.State.Connections = append(c.State.Connections, msg.(*lurk.Connection))
 		case lurk.TypeMessage:
 			message := msg.(*lurk.Message)
-			c.State.Info += lineBreak
+			kind := LogMessage
 			if message.Narration {
-				c.State.Info += fmt.Sprintf(narratorTemplate, message.Sender, message.Recipient, message.Text)
-				continue
+				kind = LogNarration
 			}
-			c.State.Info += fmt.Sprintf(messageTemplate, message.Sender, message.Recipient, message.Text)
+			c.State.addLog(&LogEntry{Kind: kind, Sender: message.Sender, Recipient: message.Recipient, Text: message.Text})
 		case lurk.TypeError:
 			e := msg.(*lurk.Error)
-			c.State.Info += lineBreak
-			c.State.Info += fmt.Sprintf(errorTemplate, e.ErrCode, e.ErrMessage)
+			c.State.addLog(&LogEntry{Kind: LogError, Code: e.ErrCode, Text: e.ErrMessage})
 		case lurk.TypeAccept:
 			accept := msg.(*lurk.Accept)
 			if accept.Action != lurk.TypeMessage {
 				continue
 			}
-			c.State.Info += lineBreak
-			c.State.Info += sentMessageTemplate
+			c.State.addLog(&LogEntry{Kind: LogSent, Text: "Successfully sent message."})
 		}
 	}
 }
 
-func (c *Client) stringifyCharacters() {
-	c.State.Players = ""
-	c.State.Players = fmt.Sprintf(userTemplate, c.character.Name, c.character.Attack, c.character.Defense, c.character.Regen, c.character.Health, c.character.Gold)
+// notifyChange wakes everyone waiting for the state to change. c.mu must be held.
+func (c *Client) notifyChange() {
+	c.rev++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// updateCharacters refreshes the player and everyone in the same room.
+func (c *Client) updateCharacters() {
+	c.State.Self = c.character
+	c.State.Characters = []*lurk.Character{}
+	if c.character == nil {
+		return
+	}
 	for _, character := range c.State.characters {
 		if character.RoomNum != c.character.RoomNum || character.Name == c.character.Name {
 			continue
 		}
-		switch {
-		case !character.Flags[lurk.Alive]:
-			c.State.Players += fmt.Sprintf(deadEntity, character.Name, character.Attack, character.Defense, character.Regen, character.Gold)
-		case character.Flags[lurk.Monster]:
-			c.State.Players += fmt.Sprintf(monsterTemplate, character.Name, character.Attack, character.Defense, character.Regen, character.Health)
-		default:
-			c.State.Players += fmt.Sprintf(characterTemplate, character.Name, character.Attack, character.Defense, character.Regen, character.Health, character.Gold)
+		c.State.Characters = append(c.State.Characters, character)
+	}
+	sort.Slice(c.State.Characters, func(i, j int) bool {
+		return c.State.Characters[i].Name < c.State.Characters[j].Name
+	})
+}
+
+// stateDelta is one event on the event stream. It has every section of the state but only the
+// log entries after the last event the browser saw.
+type stateDelta struct {
+	*ClientState
+	Log []*LogEntry `json:"log"`
+	// Full is set when Log has every entry the client still has, so the browser should replace
+	// its log rather than add to it. That happens on a fresh stream, or when the browser was
+	// away long enough for entries it missed to be dropped.
+	Full bool `json:"full"`
+}
+
+// deltaJSON encodes the state with the log entries after seq. It also returns the newest log
+// seq, which is the event ID, and the rev the delta was taken at.
+func (c *Client) deltaJSON(seq uint64) (_ []byte, last, rev uint64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delta := &stateDelta{ClientState: c.State, Log: []*LogEntry{}}
+	log := c.State.Log
+	delta.Full = seq == 0 || seq > c.State.seq || len(log) == 0 || log[0].Seq > seq+1
+	for _, entry := range log {
+		if delta.Full || entry.Seq > seq {
+			delta.Log = append(delta.Log, entry)
 		}
 	}
+	ba, err := json.Marshal(delta)
+	return ba, c.State.seq, c.rev, err
 }
 
-const characterTemplate = `
-%s
-  | Attack: %v
-  | Defense: %v
-  | Regen: %v
-  | Health: %v
-  | Gold: %v
-  `
-
-const monsterTemplate = `
-<span style="color: red;">%s</span>
-  | Attack: %v
-  | Defense: %v
-  | Regen: %v
-  | Health: %v
-  `
-
-// Think about showing their descriptions somehow. Maybe a button that will list everything.
-const userTemplate = `
-<span style="color: green;">%s</span>
-  | Attack: %v
-  | Defense: %v
-  | Regen: %v
-  | Health: %v
-  | Gold: %v
-  `
-
-const deadEntity = `
-<span style="background-color: red; color: white;">%s</span>
-  | Attack: %v
-  | Defense: %v
-  | Regen: %v
-  | Gold: %v
-`
-
-const errorTemplate = `
-<span style="color: red;">Error #%d</span>: %s
-`
-
-const roomTemplate = `
-(Current Room) %v: %s
--> %s
-`
-
-const connectionTemplate = `
-%v: %s
--> %s
-`
-
-const messageTemplate = `
-%s => %s: %s
-`
-
-const sentMessageTemplate = `
-Successfully sent message.
-`
-
-const narratorTemplate = `
-<span style="color: purple;">%s</span> => %s: %s`
-
-const lineBreak = `
-==================================================
-`
-
-const newPlayer = `
-%s: 
--> %s
-`
+// ViewHTML asks StateJSON for the old pre-rendered HTML state.
+const ViewHTML = "html"
+
+// StateJSON encodes the client state. view is normally empty; ViewHTML keeps pages written
+// against the old HTML strings working.
+func (c *Client) StateJSON(view string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if view == ViewHTML {
+		return json.Marshal(renderHTML(c.State))
+	}
+	return json.Marshal(c.State)
+}
+
+// completions lists what the player can see, for the command interpreter. Self is left out
+// until the character has started.
+func (c *Client) completions() *Completions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	known := &Completions{
+		Connections: c.State.Connections,
+		Characters:  c.State.Characters,
+	}
+	if c.started != nil {
+		known.Self = c.character
+	}
+	return known
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func hasLog(c *Client, kind, text string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.State.Log {
+		if entry.Kind == kind && strings.Contains(entry.Text, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCharacter(c *Client, name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, character := range c.State.Characters {
+		if character.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClientState(t *testing.T) {
+	a := assert.New(t)
+
+	c := newClient(nil, 7)
+	c.character = &lurk.Character{Name: "Ender"}
+	c.updateClientState([]lurk.LurkMessage{
+		&lurk.Version{Major: 2, Minor: 3},
+		&lurk.Game{InitialPoints: 100, StatLimit: 200, GameDesc: "Battle School"},
+		&lurk.Room{RoomNumber: 4, RoomName: "Battle Room", RoomDesc: "Zero gravity"},
+		&lurk.Connection{RoomNumber: 1, RoomName: "Battle School"},
+		&lurk.Connection{RoomNumber: 2, RoomName: "Barracks"},
+		&lurk.Character{Name: "Ender", RoomNum: 4, Health: 100, Flags: map[string]bool{lurk.Alive: true}},
+		&lurk.Character{Name: "Bonito", RoomNum: 4, Flags: map[string]bool{lurk.Monster: true}},
+		&lurk.Character{Name: "Bean", RoomNum: 4, Health: 80, Flags: map[string]bool{lurk.Alive: true}, PlayerDesc: "Small"},
+		&lurk.Character{Name: "Petra", RoomNum: 3},
+		&lurk.Message{Sender: "Bean", Recipient: "Ender", Text: "hi"},
+		&lurk.Message{Sender: "Narrator", Recipient: "Ender", Text: "Welcome", Narration: true},
+		&lurk.Error{ErrCode: cross.BadRoom, ErrMessage: "no"},
+		&lurk.Accept{Action: lurk.TypeMessage},
+		&lurk.Accept{Action: lurk.TypeFight},
+	})
+
+	s := c.State
+	a.True(s.Game.StatLimit == 200)
+	a.True(s.Room.RoomNumber == 4)
+	a.True(len(s.Connections) == 2)
+	a.True(s.Self.Name == "Ender" && s.Self.Health == 100)
+	// Sorted by name, without the player or anyone in another room.
+	a.True(len(s.Characters) == 2 && s.Characters[0].Name == "Bean" && s.Characters[1].Name == "Bonito")
+
+	var kinds []string
+	for i, entry := range s.Log {
+		a.True(entry.Seq == uint64(i+1))
+		a.True(!entry.Time.IsZero())
+		kinds = append(kinds, entry.Kind)
+	}
+	a.True(strings.Join(kinds, " ") == "version game arrival arrival message narration error sent")
+	a.True(s.Log[6].Code == cross.BadRoom)
+
+	ba, err := c.StateJSON("")
+	a.NoError(err)
+	var decoded map[string]any
+	a.NoError(json.Unmarshal(ba, &decoded))
+	for _, key := range []string{"id", "game", "room", "connections", "self", "characters", "log"} {
+		_, ok := decoded[key]
+		a.True(ok)
+	}
+	a.True(strings.Contains(string(ba), `"flags":{"Alive":true`))
+
+	ba, err = c.StateJSON(ViewHTML)
+	a.NoError(err)
+	h := &htmlState{}
+	a.NoError(json.Unmarshal(ba, h))
+	a.True(h.Id == 7)
+	a.True(strings.Contains(h.Info, "Stat Limit: 200"))
+	a.True(strings.Contains(h.Info, "Bean => Ender: hi"))
+	a.True(strings.Contains(h.Info, "Error #"))
+	a.True(strings.Contains(h.Rooms, "(Current Room) 4: Battle Room"))
+	a.True(strings.Contains(h.Connections, "2: Barracks"))
+	a.True(strings.Contains(h.Players, `<span style="color: green;">Ender</span>`))
+	a.True(strings.Contains(h.Players, `<span style="background-color: red; color: white;">Bonito</span>`))
+
+	t.Run("TestLogLimit", func(_ *testing.T) {
+		for i := range maxLog + 10 {
+			c.updateClientState([]lurk.LurkMessage{&lurk.Message{Sender: "Bean", Text: fmt.Sprint(i)}})
+		}
+		a.True(len(s.Log) == maxLog)
+		a.True(s.Log[maxLog-1].Text == fmt.Sprint(maxLog+9))
+		a.True(s.Log[maxLog-1].Seq == uint64(8+maxLog+10))
+	})
+}
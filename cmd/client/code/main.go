@@ -1,13 +1,17 @@
 package main
 
 import (
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/Clayal10/enders_game/cmd/client/code/client"
 )
@@ -17,7 +21,24 @@ const setupEP = "/lurk-client/setup/"
 const defaultPort = 5068
 const staticDir = "../cmd/client/code/ui" // exe must be in root of repo
 
+// rootCAs are trusted when verifying LURK servers over TLS. nil uses the system roots.
+var rootCAs *x509.CertPool
+
+var sessions *client.Manager
+
 func main() {
+	caFile := flag.String("lurk-ca", "", "PEM file with extra certificates to trust for LURK servers using TLS")
+	idle := flag.Duration("idle", 10*time.Minute, "disconnect sessions the browser hasn't used for this long")
+	flag.Parse()
+	if *caFile != "" {
+		var err error
+		if rootCAs, err = loadRootCAs(*caFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sessions = client.NewManager(*idle)
+	http.Handle(client.Prefix, sessions)
 	http.HandleFunc(setupEP, handleSetup)
 	if err := serve(); err != nil {
 		log.Fatal(err)
@@ -41,6 +62,7 @@ func handleSetup(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
+	cfg.RootCAs = rootCAs
 
 	c, err := client.New(cfg)
 	if err != nil {
@@ -49,20 +71,36 @@ func handleSetup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jsonData, err := json.Marshal(c.State)
+	jsonData, err := c.StateJSON(r.URL.Query().Get("view"))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		log.Println(err)
 		return
 	}
+	// Added before answering, so the browser can't get ahead of it.
+	sessions.Add(c)
 
 	if _, err = w.Write(jsonData); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		log.Println(err)
 		return
 	}
+}
 
-	c.Start()
+// loadRootCAs returns the system roots along with the certificates in caFile.
+func loadRootCAs(caFile string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in " + caFile)
+	}
+	return pool, nil
 }
 
 const (
@@ -2,24 +2,28 @@ const setupAPI = "/lurk-client/setup/"
 // These require IDs
 const startAPI = "/lurk-client/start/"
 const updateAPI = "/lurk-client/update/"
+const eventsAPI = "/lurk-client/events/"
 const terminateAPI = "/lurk-client/terminate/"
 const changeRoomAPI = "/lurk-client/change-room/"
 const fightAPI = "/lurk-client/fight/"
 const lootAPI = "/lurk-client/loot/"
 const pvpFightAPI = "/lurk-client/pvp/"
 const messageAPI = "/lurk-client/message/"
+const commandAPI = "/lurk-client/command/"
 
 class Client{
     constructor(id){
         this.id = id;
         this.startAPI = startAPI+id+"/";
         this.updateAPI = updateAPI+id+"/";
+        this.eventsAPI = eventsAPI+id+"/";
         this.terminateAPI = terminateAPI+id+"/";
         this.changeRoomAPI = changeRoomAPI+id+"/";
         this.fightAPI = fightAPI+id+"/";
         this.lootAPI = lootAPI+id+"/";
         this.pvpFightAPI = pvpFightAPI+id+"/";
         this.messageAPI = messageAPI+id+"/";
+        this.commandAPI = commandAPI+id+"/";
     };
 };
 
@@ -31,24 +35,30 @@ window.addEventListener('beforeunload', (event) => {
     return
   }
   navigator.sendBeacon(client.terminateAPI);
-  shouldPoll = false;
+  stopEvents();
 });
 
 // Sends:
 // - Hostname
 // - Port
 // Receives:
-// - Client Update object:
-//  - info | general info about the game
-//  - players | Already string formatted player / monster list
+// - Client state object:
+//  - game, version | what the server sent on connect
+//  - room, connections | where the player is and where they can go
+//  - self, characters | the player and everyone in the room, with their flags
+//  - log | newest entries with type, sender, recipient, text and time
 function sendConfig(){
     let hostname = document.getElementById("input-hostname");
     let port = document.getElementById("input-port");
+    let tls = document.getElementById("input-tls-enabled");
+    let insecure = document.getElementById("input-tls-insecure");
     let text = document.getElementById("game-text");
     text.innerHTML = "";
     const cfg = {
         "Hostname": hostname.value,
-        "Port": port.value
+        "Port": port.value,
+        "TLS": tls.checked,
+        "InsecureSkipVerify": insecure.checked
     };
 
     fetch(setupAPI, {
@@ -69,7 +79,7 @@ function sendConfig(){
         }
         console.log("New client ID: ", data.id);
         client = new Client(data.id)
-        updateGame(data);
+        applyDelta({...data, full: true});
         setupDisplay(); // For character input.
     }).catch(err => {
         console.error("Could not send config: ", err);
@@ -115,11 +125,10 @@ function sendCharacter(character){
             handleCharacterError();
             throw new Error("Bad Response");
         }
-        shouldPoll = true;
         hide("input-submit-button");
         hideConfig();
         revealGameInput();
-        pollUpdateEP();
+        startEvents();
     }).catch(e => {
         console.error("Could not send start: ", e);
         return
@@ -237,20 +246,49 @@ function sendMessage(){
     })
 }
 
-var shouldPoll = true;
-async function pollUpdateEP(){
-    try{
-        let response = await fetch(client.updateAPI)
-        if(response.status === 200){
-            data = await response.json();
-            updateGame(data);
-        }
-    }catch(e){
-        console.error(e);
-    }finally{
-        if(shouldPoll){
-            await pollUpdateEP();
-        }
+// Sends a typed line such as "go barracks". Local answers like help and stats, and mistakes in
+// the line, come back as the reply.
+function sendCommand(){
+    let cmdElement = document.getElementById("game-input-command");
+    let replyElement = document.getElementById("game-command-reply");
+    let cmd = {
+        line: cmdElement.value
     }
+    cmdElement.value = "";
+
+    fetch(client.commandAPI, {
+        method: "POST",
+        headers: {
+            'Content-Type': 'application/json',
+            'Accept': 'application/json',
+        },
+        body: JSON.stringify(cmd)
+    }).then(response => {
+        return response.json();
+    }).then(data => {
+        replyElement.textContent = data.error ? "Error: " + data.error : (data.reply || "");
+    }).catch(e => {
+        console.error("Could not send command: ", e)
+    })
 }
 
+// The event stream pushes a delta whenever the game changes. EventSource reconnects by itself,
+// sending the last event ID so the backend only sends the log entries we missed.
+var events = null;
+function startEvents(){
+    stopEvents();
+    events = new EventSource(client.eventsAPI);
+    events.onmessage = (event) => {
+        applyDelta(JSON.parse(event.data));
+    };
+    events.onerror = (e) => {
+        console.error("Event stream interrupted, reconnecting: ", e);
+    };
+}
+
+function stopEvents(){
+    if(events !== null){
+        events.close();
+        events = null;
+    }
+}
@@ -1,15 +1,119 @@
+// gameLog is every log entry received, up to the backend's limit.
+var gameLog = [];
+const maxLog = 200;
+
+// applyDelta merges an event from the stream. It has the whole state apart from the log, which
+// only has new entries unless full is set.
+function applyDelta(delta) {
+    if (delta.full) {
+        gameLog = [];
+    }
+    gameLog = gameLog.concat(delta.log).slice(-maxLog);
+    delta.log = gameLog;
+    updateGame(delta);
+}
+
+// The state is plain data, see ClientState in the client package. Everything is added with
+// textContent so names and messages from the server can't inject HTML.
 function updateGame(data) {
     const gameDesc = document.getElementById("game-text");
     const gamePlayers = document.getElementById("game-players");
     const gameRooms = document.getElementById("game-rooms");
-    gameDesc.innerHTML = data.info.replace(/\n/g, '<br>');
-    gamePlayers.innerHTML = data.players.replace(/\n/g, '<br>');
-    gameRooms.innerHTML = data.rooms.replace(/\n/g, '<br>');
-    gameRooms.innerHTML += data.connections.replace(/\n/g, `<br>`);
+
+    if (data.status === "reconnecting") {
+        reveal("game-status");
+    } else {
+        hide("game-status");
+    }
+
+    gameDesc.replaceChildren();
+    if (data.version) {
+        addLine(gameDesc, "", `LURK Version ${data.version.major}.${data.version.minor}`);
+    }
+    if (data.game) {
+        addLine(gameDesc, "", `Stat Limit: ${data.game.statLimit}\nInitial Points: ${data.game.initialPoints}\n${data.game.description}`);
+    }
+    for (const entry of data.log) {
+        renderLogEntry(gameDesc, entry);
+    }
+
+    gamePlayers.replaceChildren();
+    if (data.self) {
+        renderCharacter(gamePlayers, data.self, "character-self");
+    }
+    for (const character of data.characters) {
+        let style = "";
+        if (!character.flags.Alive) {
+            style = "character-dead";
+        } else if (character.flags.Monster) {
+            style = "character-monster";
+        }
+        renderCharacter(gamePlayers, character, style);
+    }
+
+    gameRooms.replaceChildren();
+    if (data.room) {
+        addLine(gameRooms, "", `(Current Room) ${data.room.roomNumber}: ${data.room.name}\n-> ${data.room.description}\n`);
+    }
+    for (const connection of data.connections) {
+        addLine(gameRooms, "", `${connection.roomNumber}: ${connection.name}\n-> ${connection.description}\n`);
+    }
 
     gameDesc.scrollTop = gameDesc.scrollHeight;
 }
 
+function addLine(el, style, text) {
+    const span = document.createElement("span");
+    if (style !== "") {
+        span.classList.add(style);
+    }
+    span.textContent = text;
+    el.appendChild(span);
+    el.appendChild(document.createTextNode("\n"));
+    return span;
+}
+
+const lineBreak = "==================================================";
+
+function renderLogEntry(el, entry) {
+    const time = new Date(entry.time).toLocaleTimeString();
+    if (entry.type === "game" || entry.type === "version") {
+        return;
+    }
+    addLine(el, "log-break", lineBreak);
+    switch (entry.type) {
+    case "arrival":
+        addLine(el, "", `${time} ${entry.sender} arrived\n-> ${entry.text}`);
+        break;
+    case "message":
+        addLine(el, "", `${time} ${entry.sender} => ${entry.recipient}: ${entry.text}`);
+        break;
+    case "narration":
+        addLine(el, "log-narration", `${time} ${entry.sender} => ${entry.recipient}: ${entry.text}`);
+        break;
+    case "error":
+        addLine(el, "log-error", `${time} Error #${entry.code}: ${entry.text}`);
+        break;
+    case "status":
+        addLine(el, "log-status", `${time} ${entry.text}`);
+        break;
+    default:
+        addLine(el, "", `${time} ${entry.text}`);
+    }
+}
+
+function renderCharacter(el, character, style) {
+    addLine(el, style, character.name);
+    let stats = `  | Attack: ${character.attack}\n  | Defense: ${character.defense}\n  | Regen: ${character.regen}`;
+    if (character.flags.Alive) {
+        stats += `\n  | Health: ${character.health}`;
+    }
+    if (!character.flags.Monster) {
+        stats += `\n  | Gold: ${character.gold}`;
+    }
+    addLine(el, "", stats + "\n");
+}
+
 function setupDisplay() {
     hide("submit-button");
     reveal("terminate-button");
@@ -76,7 +180,9 @@ function hideConfig(){
 }
 
 function cleanup() {
-    shouldPoll = false;
+    stopEvents();
+    gameLog = [];
+    hide("game-status");
     hide("terminate-button");
     hideGameInput()
     hide("submit-button");
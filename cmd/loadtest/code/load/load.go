@@ -0,0 +1,278 @@
+// Package load plays many bots against a server at once and reports how quickly, and how
+// reliably, it answered them.
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/bot"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// Actions a bot can be measured on.
+const (
+	ActionConnect = "connect"
+	ActionWander  = "wander"
+	ActionFight   = "fight"
+	ActionChat    = "chat"
+	ActionPVP     = "pvp"
+)
+
+var ErrNoActions = errors.New("behaviour mix has no weight")
+
+// Mix weighs how often bots pick each behaviour. A zero weight turns a behaviour off.
+type Mix struct {
+	Wander int `json:"wander"`
+	Fight  int `json:"fight"`
+	Chat   int `json:"chat"`
+	PVP    int `json:"pvp"`
+}
+
+type Config struct {
+	Address string `json:"address"`
+	Players int    `json:"players"`
+	// Duration is how long the bots play once they have all connected.
+	Duration time.Duration `json:"duration"`
+	// Ramp spreads the connections out instead of opening them all at once.
+	Ramp time.Duration `json:"ramp"`
+	// Think is the pause between one bot's actions.
+	Think time.Duration `json:"think"`
+	// Timeout bounds how long an action waits for the server.
+	Timeout time.Duration `json:"timeout"`
+	Mix     Mix           `json:"mix"`
+	// Prefix starts every bot name, so runs can share a server.
+	Prefix string `json:"prefix"`
+}
+
+// Report is the outcome of a run. It is written as JSON so runs can be compared.
+type Report struct {
+	Config    *Config                 `json:"config"`
+	Started   time.Time               `json:"started"`
+	Elapsed   time.Duration           `json:"elapsed"`
+	Connected int                     `json:"connected"`
+	Actions   map[string]*ActionStats `json:"actions"`
+}
+
+// ActionStats summarises one kind of action. Rejected actions got an ERROR from the server,
+// which is part of the game, Errors are timeouts and broken connections.
+type ActionStats struct {
+	Count    int           `json:"count"`
+	Rejected int           `json:"rejected"`
+	Errors   int           `json:"errors"`
+	P50      time.Duration `json:"p50"`
+	P90      time.Duration `json:"p90"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+}
+
+// ErrorRate is the share of actions that failed outright.
+func (s *ActionStats) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Count)
+}
+
+// recorder collects latencies from every bot.
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	rejected  map[string]int
+	errors    map[string]int
+}
+
+func (r *recorder) record(action string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+	case errors.Is(err, bot.ErrRejected):
+		r.rejected[action]++
+	default:
+		r.errors[action]++
+		return
+	}
+	r.latencies[action] = append(r.latencies[action], latency)
+}
+
+// Run connects cfg.Players bots and lets them play until cfg.Duration has passed or ctx is
+// done.
+func Run(ctx context.Context, cfg *Config) (*Report, error) {
+	if cfg.Mix.Wander+cfg.Mix.Fight+cfg.Mix.Chat+cfg.Mix.PVP <= 0 {
+		return nil, ErrNoActions
+	}
+	rec := &recorder{
+		latencies: make(map[string][]time.Duration),
+		rejected:  make(map[string]int),
+		errors:    make(map[string]int),
+	}
+	report := &Report{Config: cfg, Started: time.Now()}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Ramp+cfg.Duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var connectedMu sync.Mutex
+	for i := range cfg.Players {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cfg.Players > 1 {
+				if !sleep(ctx, cfg.Ramp*time.Duration(i)/time.Duration(cfg.Players-1)) {
+					return
+				}
+			}
+			p := &player{cfg: cfg, rec: rec, name: botName(cfg, i), rng: rand.New(rand.NewPCG(uint64(i), 0))}
+			if !p.connect() {
+				return
+			}
+			connectedMu.Lock()
+			report.Connected++
+			connectedMu.Unlock()
+			p.play(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report.Elapsed = time.Since(report.Started)
+	report.Actions = rec.summarise()
+	return report, nil
+}
+
+func (r *recorder) summarise() map[string]*ActionStats {
+	actions := make(map[string]*ActionStats)
+	get := func(action string) *ActionStats {
+		if actions[action] == nil {
+			actions[action] = &ActionStats{}
+		}
+		return actions[action]
+	}
+	for action, latencies := range r.latencies {
+		s := get(action)
+		s.Count += len(latencies)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		s.P50 = percentile(latencies, 0.50)
+		s.P90 = percentile(latencies, 0.90)
+		s.P99 = percentile(latencies, 0.99)
+		s.Max = latencies[len(latencies)-1]
+	}
+	for action, n := range r.rejected {
+		get(action).Rejected = n
+	}
+	for action, n := range r.errors {
+		s := get(action)
+		s.Errors = n
+		s.Count += n
+	}
+	return actions
+}
+
+// percentile expects sorted latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(latencies))*p+0.5) - 1
+	return latencies[max(0, min(i, len(latencies)-1))]
+}
+
+func botName(cfg *Config, i int) string {
+	return fmt.Sprintf("%s%d", cfg.Prefix, i)
+}
+
+// player is one bot and the choices it makes. Each bot is seeded by its number, so runs with
+// the same config make the same choices.
+type player struct {
+	cfg  *Config
+	rec  *recorder
+	name string
+	rng  *rand.Rand
+	b    *bot.Bot
+}
+
+func (p *player) connect() bool {
+	start := time.Now()
+	b, err := bot.Connect(p.cfg.Address, &bot.Options{Timeout: p.cfg.Timeout})
+	if err == nil {
+		p.b = b
+		err = b.CreateCharacter(&lurk.Character{
+			Name:       p.name,
+			Attack:     40,
+			Defense:    30,
+			Regen:      30,
+			PlayerDesc: "A load testing bot.",
+		})
+	}
+	if err == nil {
+		err = p.b.Start()
+	}
+	p.rec.record(ActionConnect, time.Since(start), err)
+	if err != nil && p.b != nil {
+		_ = p.b.Close()
+	}
+	return err == nil
+}
+
+func (p *player) play(ctx context.Context) {
+	defer func() { _ = p.b.Leave() }()
+	for sleep(ctx, p.cfg.Think) {
+		action, do := p.choose()
+		start := time.Now()
+		err := do()
+		p.rec.record(action, time.Since(start), err)
+		// Timeouts are worth measuring again, a broken connection isn't.
+		if err != nil && !errors.Is(err, bot.ErrRejected) && !errors.Is(err, bot.ErrTimeout) {
+			return
+		}
+	}
+}
+
+// choose picks a behaviour by weight. Behaviours that have no target fall back to wandering.
+func (p *player) choose() (string, func() error) {
+	mix := p.cfg.Mix
+	n := p.rng.IntN(mix.Wander + mix.Fight + mix.Chat + mix.PVP)
+	switch {
+	case n < mix.Wander:
+	case n < mix.Wander+mix.Fight:
+		return ActionFight, p.b.Fight
+	case n < mix.Wander+mix.Fight+mix.Chat:
+		other := botName(p.cfg, p.rng.IntN(p.cfg.Players))
+		return ActionChat, func() error { return p.b.Say(other, "Hello from "+p.name) }
+	default:
+		var targets []string
+		for _, c := range p.b.Others() {
+			if !c.Flags[lurk.Monster] {
+				targets = append(targets, c.Name)
+			}
+		}
+		if len(targets) != 0 {
+			target := targets[p.rng.IntN(len(targets))]
+			return ActionPVP, func() error { return p.b.PVP(target) }
+		}
+	}
+
+	connections := p.b.Connections()
+	if len(connections) == 0 {
+		return ActionFight, p.b.Fight
+	}
+	room := connections[p.rng.IntN(len(connections))].RoomNumber
+	return ActionWander, func() error { return p.b.Move(room) }
+}
+
+// sleep waits for d, returning false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,78 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/server/code/server"
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+func TestRun(t *testing.T) {
+	a := assert.New(t)
+
+	port := cross.GetFreePort()
+	cfs, err := server.New(&server.Config{Port: port})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+
+	cfg := &Config{
+		Address:  fmt.Sprintf("localhost:%v", port),
+		Players:  8,
+		Duration: 300 * time.Millisecond,
+		Ramp:     50 * time.Millisecond,
+		Think:    5 * time.Millisecond,
+		Timeout:  time.Second,
+		Mix:      Mix{Wander: 1, Fight: 1, Chat: 1, PVP: 1},
+		Prefix:   "test",
+	}
+	report, err := Run(context.Background(), cfg)
+	a.NoError(err)
+	a.True(report.Connected == cfg.Players)
+	a.True(report.Actions[ActionConnect].Count == cfg.Players)
+	for _, action := range []string{ActionWander, ActionFight, ActionChat} {
+		s := report.Actions[action]
+		a.True(s != nil && s.Count != 0)
+		a.True(s.P50 <= s.P90 && s.P90 <= s.P99 && s.P99 <= s.Max)
+		a.True(s.Errors == 0)
+	}
+
+	var out bytes.Buffer
+	a.NoError(report.WriteText(&out))
+	a.True(strings.Contains(out.String(), "8 of 8 players connected"))
+
+	ba, err := json.Marshal(report)
+	a.NoError(err)
+	before, err := ReadReport(bytes.NewReader(ba))
+	a.NoError(err)
+	out.Reset()
+	a.NoError(report.WriteCompare(&out, before))
+	a.True(strings.Contains(out.String(), "+0.0%"))
+
+	_, err = Run(context.Background(), &Config{})
+	a.True(errors.Is(err, ErrNoActions))
+}
+
+func TestPercentile(t *testing.T) {
+	a := assert.New(t)
+
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i))
+	}
+	a.True(percentile(latencies, 0.5) == 50)
+	a.True(percentile(latencies, 0.99) == 99)
+	a.True(percentile(latencies[:1], 0.99) == 1)
+	a.True(percentile(nil, 0.5) == 0)
+}
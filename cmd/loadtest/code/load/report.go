@@ -0,0 +1,71 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteText prints the report as a table, one row per action.
+func (r *Report) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%d of %d players connected to %s, played for %v\n\n",
+		r.Connected, r.Config.Players, r.Config.Address, r.Elapsed.Round(time.Millisecond)); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "action\tcount\trejected\terrors\terror rate\tp50\tp90\tp99\tmax\t")
+	for _, action := range r.actionNames() {
+		s := r.Actions[action]
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f%%\t%v\t%v\t%v\t%v\t\n", action, s.Count, s.Rejected, s.Errors,
+			100*s.ErrorRate(), round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	return tw.Flush()
+}
+
+// WriteCompare prints how r changed since an earlier report. Positive changes are slower.
+func (r *Report) WriteCompare(w io.Writer, before *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "action\terror rate\tp50\tp90\tp99\t")
+	for _, action := range r.actionNames() {
+		now, then := r.Actions[action], before.Actions[action]
+		if then == nil {
+			_, _ = fmt.Fprintf(tw, "%s\tnew\tnew\tnew\tnew\t\n", action)
+			continue
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%+.2f%%\t%s\t%s\t%s\t\n", action, 100*(now.ErrorRate()-then.ErrorRate()),
+			change(then.P50, now.P50), change(then.P90, now.P90), change(then.P99, now.P99))
+	}
+	return tw.Flush()
+}
+
+// ReadReport loads a report written as JSON.
+func ReadReport(r io.Reader) (*Report, error) {
+	report := &Report{}
+	if err := json.NewDecoder(r).Decode(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *Report) actionNames() []string {
+	names := make([]string, 0, len(r.Actions))
+	for action := range r.Actions {
+		names = append(names, action)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func change(before, after time.Duration) string {
+	if before == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", 100*float64(after-before)/float64(before))
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
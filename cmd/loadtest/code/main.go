@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/loadtest/code/load"
+)
+
+func main() {
+	cfg := &load.Config{}
+	flag.StringVar(&cfg.Address, "addr", "localhost:5069", "LURK server to load")
+	flag.IntVar(&cfg.Players, "players", 100, "number of bots to connect")
+	flag.DurationVar(&cfg.Duration, "duration", 30*time.Second, "how long the bots play once connected")
+	flag.DurationVar(&cfg.Ramp, "ramp", 5*time.Second, "time to spread the connections over")
+	flag.DurationVar(&cfg.Think, "think", 200*time.Millisecond, "pause between one bot's actions")
+	flag.DurationVar(&cfg.Timeout, "timeout", 5*time.Second, "how long an action waits for the server")
+	flag.StringVar(&cfg.Prefix, "prefix", "load", "start of every bot name")
+	flag.IntVar(&cfg.Mix.Wander, "wander", 4, "weight of moving between rooms")
+	flag.IntVar(&cfg.Mix.Fight, "fight", 3, "weight of fighting monsters")
+	flag.IntVar(&cfg.Mix.Chat, "chat", 2, "weight of messaging other bots")
+	flag.IntVar(&cfg.Mix.PVP, "pvp", 1, "weight of fighting other bots")
+	jsonOut := flag.String("json", "", "also write the report as JSON to this file")
+	compare := flag.String("compare", "", "JSON report from an earlier run to compare against")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT)
+	defer cancel()
+
+	report, err := load.Run(ctx, cfg)
+	fatalOnErr(err)
+	fatalOnErr(report.WriteText(os.Stdout))
+
+	if *jsonOut != "" {
+		ba, err := json.MarshalIndent(report, "", "\t")
+		fatalOnErr(err)
+		fatalOnErr(os.WriteFile(*jsonOut, ba, 0o600))
+	}
+	if *compare != "" {
+		f, err := os.Open(*compare)
+		fatalOnErr(err)
+		before, err := load.ReadReport(f)
+		_ = f.Close()
+		fatalOnErr(err)
+		fmt.Printf("\nCompared to %s:\n", *compare)
+		fatalOnErr(report.WriteCompare(os.Stdout, before))
+	}
+}
+
+func fatalOnErr(err error) {
+	if err != nil {
+		log.Fatalf("%v: load test failed", err.Error())
+	}
+}
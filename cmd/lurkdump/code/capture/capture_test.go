@@ -0,0 +1,182 @@
+package capture_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Clayal10/enders_game/cmd/lurkdump/code/capture"
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func TestPcapDecoding(t *testing.T) {
+	a := assert.New(t)
+
+	fromServer := append(lurk.Marshal(&lurk.Version{Major: 2, Minor: 3}), lurk.Marshal(&lurk.Game{
+		InitialPoints: 100,
+		StatLimit:     65535,
+		GameDesc:      "A test game",
+	})...)
+	fromClient := append(lurk.Marshal(&lurk.Character{
+		Name:       "Tester",
+		Attack:     50,
+		PlayerDesc: "Test character",
+	}), 0x07, 0x0a, 0x00, 0x00) // ERROR with a bad error code
+
+	var pcap bytes.Buffer
+	writeGlobalHeader(&pcap)
+	// server -> client, out of order with a retransmission.
+	writeTCPPacket(&pcap, 5069, 40000, 1000, 0x02, nil) // SYN
+	writeTCPPacket(&pcap, 5069, 40000, 1001+10, 0, fromServer[10:])
+	writeTCPPacket(&pcap, 5069, 40000, 1001, 0, fromServer[:10])
+	writeTCPPacket(&pcap, 5069, 40000, 1001, 0, fromServer[:10])
+	// client -> server
+	writeTCPPacket(&pcap, 40000, 5069, 0xfffffff0, 0, fromClient[:20]) // wraps
+	writeTCPPacket(&pcap, 40000, 5069, 0x00000004, 0, fromClient[20:])
+
+	segments, err := capture.ReadPcap(&pcap)
+	a.NoError(err)
+	a.True(len(segments) == 6)
+
+	streams := capture.Reassemble(segments)
+	a.True(len(streams) == 2)
+
+	server := streams[0]
+	a.True(server.Src.Port == 5069)
+	a.True(server.Gaps() == 0)
+	frames := capture.Decode(server)
+	a.True(len(frames) == 2)
+	a.True(frames[0].Offset == 0)
+	a.True(frames[0].Message.GetType() == lurk.TypeVersion)
+	a.True(frames[1].Offset == 5)
+	game, ok := frames[1].Message.(*lurk.Game)
+	a.True(ok)
+	a.True(game.GameDesc == "A test game")
+
+	client := streams[1]
+	frames = capture.Decode(client)
+	a.True(len(frames) == 2)
+	character, ok := frames[0].Message.(*lurk.Character)
+	a.True(ok)
+	a.True(character.Name == "Tester")
+	a.Error(frames[1].Err)
+	a.Nil(frames[1].Message)
+
+	var report bytes.Buffer
+	a.NoError(capture.Report(&report, client, frames))
+	a.True(strings.Contains(report.String(), "CHARACTER"))
+	a.True(strings.Contains(report.String(), "FAILED: invalid error code"))
+}
+
+func TestStreamErrors(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("TestGapsAndTruncation", func(_ *testing.T) {
+		ba := lurk.Marshal(&lurk.Room{RoomNumber: 1, RoomName: "Room", RoomDesc: "A room"})
+		var pcap bytes.Buffer
+		writeGlobalHeader(&pcap)
+		writeTCPPacket(&pcap, 5069, 40000, 100, 0x02, nil)
+		writeTCPPacket(&pcap, 5069, 40000, 101, 0, ba[:10])
+		writeTCPPacket(&pcap, 5069, 40000, 101+20, 0, ba[20:])
+
+		segments, err := capture.ReadPcap(&pcap)
+		a.NoError(err)
+		streams := capture.Reassemble(segments)
+		a.True(len(streams) == 1)
+		a.True(streams[0].Gaps() == 1)
+
+		frames := capture.Decode(streams[0])
+		a.True(len(frames) > 2)
+		a.True(errors.Is(frames[0].Err, capture.ErrTruncated))
+		a.True(errors.Is(frames[1].Err, capture.ErrJunk))
+		a.True(frames[1].Offset == 20)
+	})
+	t.Run("TestNotPcap", func(_ *testing.T) {
+		_, err := capture.ReadPcap(strings.NewReader("0a000b"))
+		a.True(errors.Is(err, capture.ErrNotPcap))
+	})
+}
+
+func TestHexDumps(t *testing.T) {
+	a := assert.New(t)
+	ba := append(lurk.Marshal(&lurk.ChangeRoom{RoomNumber: 3}), lurk.Marshal(&lurk.Fight{})...)
+	ba = append(ba, lurk.Marshal(&lurk.Loot{TargetName: "Bean"})...)
+
+	dumps := map[string]string{
+		"plain": "020300030542\n65616e00000000000000000000000000000000000000000000000000000000\n",
+		"xxd": `00000000: 0203 0003 0542 6561 6e00 0000 0000 0000  .....Bean.......
+00000010: 0000 0000 0000 0000 0000 0000 0000 0000  ................
+00000020: 0000 0000 00                             .....`,
+		"hexdump": `00000000  02 03 00 03 05 42 65 61  6e 00 00 00 00 00 00 00  |.....Bean.......|
+00000010  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|
+00000020  00 00 00 00 00                                    |.....|
+00000025`,
+		"c array": "0x02, 0x03, 0x0, 0x03,\n0x05, 0x42, 0x65, 0x61, 0x6e, " + strings.Repeat("0x00, ", 28),
+	}
+
+	for name, dump := range dumps {
+		t.Run(name, func(_ *testing.T) {
+			s, err := capture.ReadHexDump(strings.NewReader(dump))
+			a.NoError(err)
+			a.EqualSlice(s.Chunks[0].Data, ba)
+
+			frames := capture.Decode(s)
+			a.True(len(frames) == 3)
+			for _, f := range frames {
+				a.NoError(f.Err)
+			}
+		})
+	}
+
+	_, err := capture.ReadHexDump(strings.NewReader("abc"))
+	a.Error(err)
+}
+
+func writeGlobalHeader(buf *bytes.Buffer) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header, 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], 65535)
+	binary.LittleEndian.PutUint32(header[20:], 1) // Ethernet
+	buf.Write(header)
+}
+
+// writeTCPPacket writes an Ethernet / IPv4 / TCP packet between 10.0.0.1 and 10.0.0.2.
+func writeTCPPacket(buf *bytes.Buffer, srcPort, dstPort uint16, seq uint32, flags byte, payload []byte) {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp, srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+	ip[9] = 6
+	src, dst := []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}
+	if srcPort != 5069 {
+		src, dst = dst, src
+	}
+	copy(ip[12:], src)
+	copy(ip[16:], dst)
+	ip = append(ip, tcp...)
+
+	eth := make([]byte, 14)
+	binary.BigEndian.PutUint16(eth[12:], 0x0800)
+	packet := append(eth, ip...)
+	for len(packet) < 60 { // Ethernet padding should be ignored.
+		packet = append(packet, 0)
+	}
+
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(packet)))
+	buf.Write(record)
+	buf.Write(packet)
+}
@@ -0,0 +1,115 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+var (
+	ErrTruncated = errors.New("message truncated")
+	ErrJunk      = errors.New("bytes do not start a LURK message")
+)
+
+// Frame is a single LURK message, or a run of bytes that could not be decoded, found in a
+// stream.
+type Frame struct {
+	Offset  int
+	Raw     []byte
+	Message lurk.LurkMessage
+	// Err is set when the frame could not be unmarshaled. Message will be nil.
+	Err error
+}
+
+// Decode will split every chunk of the stream into LURK messages. Bytes that do not begin a
+// valid message are grouped into a single frame with ErrJunk and decoding continues at the
+// next byte, so one bad frame doesn't hide the rest of the capture.
+func Decode(s *Stream) (frames []*Frame) {
+	for _, chunk := range s.Chunks {
+		var junk *Frame
+		data := chunk.Data
+		for pos := 0; pos < len(data); {
+			remaining := data[pos:]
+			n, err := lurk.FrameLength(remaining)
+			if errors.Is(err, cross.ErrInvalidMessageType) {
+				if junk == nil {
+					junk = &Frame{Offset: chunk.Offset + pos, Err: ErrJunk}
+					frames = append(frames, junk)
+				}
+				junk.Raw = append(junk.Raw, remaining[0])
+				pos++
+				continue
+			}
+			junk = nil
+
+			if err != nil || n > len(remaining) {
+				frames = append(frames, &Frame{
+					Offset: chunk.Offset + pos,
+					Raw:    remaining,
+					Err:    truncatedError(remaining, n),
+				})
+				break
+			}
+
+			frame := &Frame{
+				Offset: chunk.Offset + pos,
+				Raw:    remaining[:n],
+			}
+			if frame.Message, frame.Err = lurk.Unmarshal(frame.Raw); frame.Err != nil {
+				frame.Message = nil
+			}
+			frames = append(frames, frame)
+			pos += n
+		}
+	}
+	return
+}
+
+func truncatedError(data []byte, needed int) error {
+	if needed == 0 {
+		return fmt.Errorf("%w: only %d bytes of the %v header", ErrTruncated, len(data), lurk.MessageType(data[0]))
+	}
+	return fmt.Errorf("%w: need %d bytes, have %d", ErrTruncated, needed, len(data))
+}
+
+// Report will write a human readable listing of the frames in a stream to w.
+func Report(w io.Writer, s *Stream, frames []*Frame) error {
+	total := 0
+	for _, chunk := range s.Chunks {
+		total += len(chunk.Data)
+	}
+	name := "hex dump"
+	if s.Src.IP != nil {
+		name = s.String()
+	}
+	if _, err := fmt.Fprintf(w, "== %s (%d bytes, %d frames, %d gaps)\n", name, total, len(frames), s.Gaps()); err != nil {
+		return err
+	}
+
+	for _, f := range frames {
+		var err error
+		switch {
+		case f.Err == nil:
+			_, err = fmt.Fprintf(w, "%#08x  %-10v %5d  %v\n", f.Offset, f.Message.GetType(), len(f.Raw), f.Message)
+		case errors.Is(f.Err, ErrJunk):
+			_, err = fmt.Fprintf(w, "%#08x  %-10s %5d  FAILED: %v: % x\n", f.Offset, "?", len(f.Raw), f.Err, preview(f.Raw))
+		default:
+			_, err = fmt.Fprintf(w, "%#08x  %-10v %5d  FAILED: %v: % x\n", f.Offset, lurk.MessageType(f.Raw[0]), len(f.Raw), f.Err, preview(f.Raw))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func preview(ba []byte) []byte {
+	const previewLen = 16
+	if len(ba) > previewLen {
+		return ba[:previewLen]
+	}
+	return ba
+}
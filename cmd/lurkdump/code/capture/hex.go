@@ -0,0 +1,93 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadHexDump will read a hex dump into a single stream. Plain hex (`xxd -p`), `xxd`,
+// `hexdump -C` and comma separated `0x` byte lists are all understood. Offsets and ASCII
+// columns are ignored.
+func ReadHexDump(r io.Reader) (*Stream, error) {
+	var data []byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	offsets := false
+	for scanner.Scan() {
+		lineNum++
+		digits := hexDigits(scanner.Text(), &offsets)
+		if digits == "" {
+			continue
+		}
+		if len(digits)%2 != 0 {
+			return nil, fmt.Errorf("line %d: odd number of hex digits", lineNum)
+		}
+		ba, err := hex.DecodeString(digits)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		data = append(data, ba...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Stream{
+		Chunks: []*Chunk{{Data: data}},
+	}, nil
+}
+
+// hexDigits strips everything that isn't part of the dumped bytes from a line. Once an offset
+// column has been seen, a line holding only an offset (the end of `hexdump -C`) is skipped.
+func hexDigits(line string, offsets *bool) string {
+	if i := strings.IndexAny(line, "#|"); i != -1 { // comments and hexdump -C ASCII column
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	switch first := fields[0]; {
+	case strings.HasSuffix(first, ":"):
+		// xxd: the ASCII column follows the hex after two spaces.
+		rest := strings.TrimSpace(line[strings.Index(line, ":")+1:])
+		if i := strings.Index(rest, "  "); i != -1 {
+			rest = rest[:i]
+		}
+		fields = strings.Fields(rest)
+		*offsets = true
+	case len(fields) == 1 && *offsets:
+		return ""
+	case len(fields) > 1 && len(first) >= 6 && isHex(first) && len(fields[1]) <= 4:
+		// hexdump -C / od style offset column.
+		fields = fields[1:]
+		*offsets = true
+	}
+
+	var sb strings.Builder
+	for _, f := range fields {
+		for _, tok := range strings.Split(f, ",") {
+			tok = strings.TrimPrefix(strings.TrimPrefix(tok, "0x"), "0X")
+			if len(tok) == 1 {
+				tok = "0" + tok
+			}
+			sb.WriteString(tok)
+		}
+	}
+	return sb.String()
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,254 @@
+// Package capture reads raw LURK traffic from pcap files and hex dumps, reassembles the TCP
+// streams and decodes them into LURK messages.
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+var (
+	ErrNotPcap         = errors.New("not a pcap file")
+	ErrUnsupportedLink = errors.New("unsupported link type")
+)
+
+// Magic numbers for the classic libpcap format. The nanosecond variant only changes how
+// the sub second part of the timestamp is read.
+const (
+	magicMicro = 0xa1b2c3d4
+	magicNano  = 0xa1b23c4d
+
+	globalHeaderLen = 24
+	recordHeaderLen = 16
+)
+
+// Link layer header types we know how to strip.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkSLL      = 113
+	linkSLL2     = 276
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+
+	protoTCP = 6
+)
+
+// TCP flags
+const (
+	flagFIN = 0x01
+	flagSYN = 0x02
+	flagRST = 0x04
+)
+
+// Segment is a single TCP segment pulled out of a capture.
+type Segment struct {
+	Time     time.Time
+	Src, Dst Endpoint
+	Seq      uint32
+	Flags    byte
+	Payload  []byte
+}
+
+// Endpoint is one side of a TCP connection.
+type Endpoint struct {
+	IP   net.IP
+	Port uint16
+}
+
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.IP.String(), fmt.Sprint(e.Port))
+}
+
+// ReadPcap will read every TCP segment out of a classic libpcap file. Packets that are not
+// TCP over IPv4 or IPv6 are skipped.
+func ReadPcap(r io.Reader) ([]*Segment, error) {
+	header := make([]byte, globalHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrNotPcap
+	}
+
+	var order binary.ByteOrder
+	nano := false
+	switch {
+	case binary.LittleEndian.Uint32(header) == magicMicro:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == magicMicro:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header) == magicNano:
+		order, nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header) == magicNano:
+		order, nano = binary.BigEndian, true
+	default:
+		return nil, ErrNotPcap
+	}
+	link := order.Uint32(header[20:])
+
+	var segments []*Segment
+	record := make([]byte, recordHeaderLen)
+	for {
+		if _, err := io.ReadFull(r, record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return segments, nil
+			}
+			return segments, fmt.Errorf("%w: truncated record header", err)
+		}
+		sec := int64(order.Uint32(record))
+		frac := int64(order.Uint32(record[4:]))
+		if !nano {
+			frac *= int64(time.Microsecond)
+		}
+		inclLen := order.Uint32(record[8:])
+
+		packet := make([]byte, inclLen)
+		if _, err := io.ReadFull(r, packet); err != nil {
+			return segments, fmt.Errorf("%w: truncated packet", err)
+		}
+
+		seg, err := decodePacket(link, packet)
+		if err != nil {
+			if errors.Is(err, ErrUnsupportedLink) {
+				return segments, err
+			}
+			continue
+		}
+		if seg == nil {
+			continue
+		}
+		seg.Time = time.Unix(sec, frac)
+		segments = append(segments, seg)
+	}
+}
+
+var errSkip = errors.New("not a tcp packet")
+
+// decodePacket strips the link layer and returns the TCP segment in the packet, if any.
+func decodePacket(link uint32, packet []byte) (*Segment, error) {
+	var etherType uint16
+	switch link {
+	case linkNull:
+		if len(packet) < 4 {
+			return nil, errSkip
+		}
+		// The family is in host byte order of the capturing machine, so check both.
+		family := binary.LittleEndian.Uint32(packet)
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(packet)
+		}
+		switch family {
+		case 2:
+			etherType = etherTypeIPv4
+		case 10, 24, 28, 30:
+			etherType = etherTypeIPv6
+		default:
+			return nil, errSkip
+		}
+		packet = packet[4:]
+	case linkEthernet:
+		if len(packet) < 14 {
+			return nil, errSkip
+		}
+		etherType = binary.BigEndian.Uint16(packet[12:])
+		packet = packet[14:]
+		for etherType == etherTypeVLAN {
+			if len(packet) < 4 {
+				return nil, errSkip
+			}
+			etherType = binary.BigEndian.Uint16(packet[2:])
+			packet = packet[4:]
+		}
+	case linkRaw:
+		if len(packet) < 1 {
+			return nil, errSkip
+		}
+		switch packet[0] >> 4 {
+		case 4:
+			etherType = etherTypeIPv4
+		case 6:
+			etherType = etherTypeIPv6
+		default:
+			return nil, errSkip
+		}
+	case linkSLL:
+		if len(packet) < 16 {
+			return nil, errSkip
+		}
+		etherType = binary.BigEndian.Uint16(packet[14:])
+		packet = packet[16:]
+	case linkSLL2:
+		if len(packet) < 20 {
+			return nil, errSkip
+		}
+		etherType = binary.BigEndian.Uint16(packet)
+		packet = packet[20:]
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedLink, link)
+	}
+
+	switch etherType {
+	case etherTypeIPv4:
+		return decodeIPv4(packet)
+	case etherTypeIPv6:
+		return decodeIPv6(packet)
+	}
+	return nil, errSkip
+}
+
+func decodeIPv4(packet []byte) (*Segment, error) {
+	if len(packet) < 20 {
+		return nil, errSkip
+	}
+	headerLen := int(packet[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(packet[2:]))
+	if packet[9] != protoTCP || headerLen < 20 || totalLen < headerLen || len(packet) < headerLen {
+		return nil, errSkip
+	}
+	// Ethernet pads short frames, so only trust the IP length.
+	if totalLen < len(packet) {
+		packet = packet[:totalLen]
+	}
+	src := net.IP(append([]byte{}, packet[12:16]...))
+	dst := net.IP(append([]byte{}, packet[16:20]...))
+	return decodeTCP(src, dst, packet[headerLen:])
+}
+
+func decodeIPv6(packet []byte) (*Segment, error) {
+	const headerLen = 40
+	if len(packet) < headerLen || packet[6] != protoTCP {
+		return nil, errSkip
+	}
+	payloadLen := int(binary.BigEndian.Uint16(packet[4:]))
+	src := net.IP(append([]byte{}, packet[8:24]...))
+	dst := net.IP(append([]byte{}, packet[24:40]...))
+	packet = packet[headerLen:]
+	if payloadLen < len(packet) {
+		packet = packet[:payloadLen]
+	}
+	return decodeTCP(src, dst, packet)
+}
+
+func decodeTCP(src, dst net.IP, packet []byte) (*Segment, error) {
+	if len(packet) < 20 {
+		return nil, errSkip
+	}
+	dataOffset := int(packet[12]>>4) * 4
+	if dataOffset < 20 || len(packet) < dataOffset {
+		return nil, errSkip
+	}
+	return &Segment{
+		Src:     Endpoint{IP: src, Port: binary.BigEndian.Uint16(packet)},
+		Dst:     Endpoint{IP: dst, Port: binary.BigEndian.Uint16(packet[2:])},
+		Seq:     binary.BigEndian.Uint32(packet[4:]),
+		Flags:   packet[13],
+		Payload: append([]byte{}, packet[dataOffset:]...),
+	}, nil
+}
@@ -0,0 +1,124 @@
+package capture
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Stream is the reassembled payload of one direction of a TCP connection.
+type Stream struct {
+	Src, Dst Endpoint
+	// Contiguous runs of the stream. A new chunk is started whenever segments are missing
+	// from the capture.
+	Chunks []*Chunk
+}
+
+// Chunk is a contiguous piece of a stream. Offset is relative to the first byte of the stream.
+type Chunk struct {
+	Offset int
+	Data   []byte
+}
+
+func (s *Stream) String() string {
+	return fmt.Sprintf("%v -> %v", s.Src, s.Dst)
+}
+
+// Gaps returns the number of places where data is missing from the stream.
+func (s *Stream) Gaps() int {
+	if len(s.Chunks) == 0 {
+		return 0
+	}
+	gaps := len(s.Chunks) - 1
+	if s.Chunks[0].Offset != 0 {
+		gaps++
+	}
+	return gaps
+}
+
+type flow struct {
+	stream   *Stream
+	ref      uint32
+	base     int64
+	sawSYN   bool
+	segments []*relSegment
+}
+
+type relSegment struct {
+	rel     int64
+	payload []byte
+}
+
+// Reassemble will group segments by direction and put their payloads back in sequence
+// order. Retransmitted and overlapping data is only used once. Streams are returned in the
+// order they first appear in the capture.
+func Reassemble(segments []*Segment) []*Stream {
+	flows := map[string]*flow{}
+	var order []*flow
+
+	for _, seg := range segments {
+		key := seg.Src.String() + ">" + seg.Dst.String()
+		f, ok := flows[key]
+		if !ok {
+			f = &flow{
+				stream: &Stream{Src: seg.Src, Dst: seg.Dst},
+				ref:    seg.Seq,
+			}
+			flows[key] = f
+			order = append(order, f)
+		}
+		// Relative to the first segment seen so sequence number wrap around is handled.
+		rel := int64(int32(seg.Seq - f.ref))
+		if seg.Flags&flagSYN != 0 {
+			f.sawSYN = true
+			f.base = rel + 1
+			continue
+		}
+		if len(seg.Payload) == 0 {
+			continue
+		}
+		f.segments = append(f.segments, &relSegment{rel: rel, payload: seg.Payload})
+	}
+
+	streams := make([]*Stream, 0, len(order))
+	for _, f := range order {
+		f.assemble()
+		if len(f.stream.Chunks) == 0 {
+			continue
+		}
+		streams = append(streams, f.stream)
+	}
+	return streams
+}
+
+func (f *flow) assemble() {
+	if len(f.segments) == 0 {
+		return
+	}
+	sort.SliceStable(f.segments, func(i, j int) bool {
+		return f.segments[i].rel < f.segments[j].rel
+	})
+	if !f.sawSYN {
+		f.base = f.segments[0].rel
+	}
+
+	var current *Chunk
+	next := int64(0)
+	for _, seg := range f.segments {
+		start := seg.rel - f.base
+		end := start + int64(len(seg.payload))
+		if end <= next || end <= 0 {
+			continue // retransmission
+		}
+		payload := seg.payload
+		if start < next {
+			payload = payload[next-start:]
+			start = next
+		}
+		if current == nil || start > next {
+			current = &Chunk{Offset: int(start)}
+			f.stream.Chunks = append(f.stream.Chunks, current)
+		}
+		current.Data = append(current.Data, payload...)
+		next = end
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Clayal10/enders_game/cmd/lurkdump/code/capture"
+)
+
+const defaultPort = 5069
+
+var (
+	hexInput = flag.Bool("hex", false, "treat the input as a hex dump even if it looks like a pcap file")
+	port     = flag.Uint("port", defaultPort, "only decode TCP streams to or from this port (0 for all)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <capture.pcap | dump.hex | ->\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	input, err := open(flag.Arg(0))
+	fatalOnErr(err)
+
+	streams, err := readStreams(input)
+	fatalOnErr(err)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer func() { fatalOnErr(out.Flush()) }()
+
+	failed := 0
+	for _, s := range streams {
+		frames := capture.Decode(s)
+		for _, f := range frames {
+			if f.Err != nil {
+				failed++
+			}
+		}
+		fatalOnErr(capture.Report(out, s, frames))
+	}
+	if failed != 0 {
+		_, _ = fmt.Fprintf(out, "%d frames failed to decode\n", failed)
+	}
+}
+
+func open(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func readStreams(input []byte) ([]*capture.Stream, error) {
+	if !*hexInput {
+		segments, err := capture.ReadPcap(bytes.NewReader(input))
+		switch {
+		case err == nil:
+			return filterPort(capture.Reassemble(segments)), nil
+		case !errors.Is(err, capture.ErrNotPcap):
+			log.Printf("%v: capture is damaged, decoding what was read", err)
+			return filterPort(capture.Reassemble(segments)), nil
+		}
+	}
+
+	s, err := capture.ReadHexDump(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	return []*capture.Stream{s}, nil
+}
+
+func filterPort(streams []*capture.Stream) (filtered []*capture.Stream) {
+	if *port == 0 {
+		return streams
+	}
+	for _, s := range streams {
+		if uint(s.Src.Port) == *port || uint(s.Dst.Port) == *port {
+			filtered = append(filtered, s)
+		}
+	}
+	return
+}
+
+func fatalOnErr(err error) {
+	if err != nil {
+		log.Fatalf("%v: could not decode capture", err.Error())
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -20,9 +22,57 @@ var (
 )
 
 func main() {
+	port := flag.Uint("port", defaultPort, "port to listen on")
+	wsPort := flag.Uint("ws-port", 0, "port for the JSON WebSocket gateway, 0 turns it off")
+	certFile := flag.String("tls-cert", "", "PEM certificate to serve TLS with")
+	keyFile := flag.String("tls-key", "", "PEM private key for -tls-cert")
+	selfSigned := flag.Bool("tls-self-signed", false, "serve TLS with a generated certificate, for development only")
+	worldFile := flag.String("world", "", "JSON world to host instead of the built in one")
+	auditFile := flag.String("audit", "", "file to keep a JSON line of every direct message in, for moderation")
+	questFile := flag.String("quests", "", "file to keep every player's quest progress in across restarts")
+	configFile := flag.String("config", "", "JSON file describing several instances to run, the other flags are ignored")
+	flag.Parse()
+
+	if *configFile != "" {
+		multiCfg, err := loadMultiConfig(*configFile)
+		fatalOnErr(err)
+		cancelFunctions, err := server.NewMulti(multiCfg)
+		fatalOnErr(err)
+		waitForInterrupt(cancelFunctions)
+		return
+	}
+
+	cfg.Port = uint16(*port)
+	cfg.WebSocketPort = uint16(*wsPort)
+	cfg.WorldFile = *worldFile
+	cfg.AuditFile = *auditFile
+	cfg.QuestFile = *questFile
+	if *certFile != "" || *keyFile != "" || *selfSigned {
+		cfg.TLS = &server.TLSConfig{
+			CertFile:   *certFile,
+			KeyFile:    *keyFile,
+			SelfSigned: *selfSigned,
+		}
+	}
+
 	cancelFunctions, err := server.New(cfg)
 	fatalOnErr(err)
+	waitForInterrupt(cancelFunctions)
+}
+
+func loadMultiConfig(filename string) (*server.MultiConfig, error) {
+	ba, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	multiCfg := &server.MultiConfig{}
+	if err := json.Unmarshal(ba, multiCfg); err != nil {
+		return nil, err
+	}
+	return multiCfg, nil
+}
 
+func waitForInterrupt(cancelFunctions []func()) {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT)
 
@@ -1,32 +1,22 @@
 package server
 
-import (
-	"net"
-
-	"github.com/Clayal10/enders_game/pkg/cross"
-	"github.com/Clayal10/enders_game/pkg/lurk"
-)
+import "github.com/Clayal10/enders_game/pkg/lurk"
 
 // These functions may need thread protection before getting called.
 
-// default game values.
-const (
-	initialPoints = 100
-	statLimit     = 65535
-	narrator      = "Narrator"
-)
+const narrator = "Narrator"
 
-func (g *game) sendStart(conn net.Conn) error {
-	if _, err := conn.Write(lurk.Marshal(g.version)); err != nil {
+func (g *game) sendStart(conn *lurk.Conn) error {
+	if err := conn.Send(g.version); err != nil {
 		return err
 	}
 
-	_, err := conn.Write(lurk.Marshal(g.game))
+	err := conn.Send(g.game)
 	return err
 }
 
-func (g *game) sendRoom(room *room, player string, conn net.Conn) error {
-	if _, err := conn.Write(lurk.Marshal(room.r)); err != nil {
+func (g *game) sendRoom(room *room, player string, conn sender) error {
+	if err := conn.Send(room.r); err != nil {
 		return err
 	}
 
@@ -38,94 +28,45 @@ func (g *game) sendRoom(room *room, player string, conn net.Conn) error {
 }
 
 // sends information on all users and monsters to the specified 'conn'
-func (g *game) sendAllEntities(room *room, conn net.Conn) (err error) {
-	// all characters and monsters in that room
-	if err = g.sendAllCharacters(room, conn); err != nil {
-		return
-	}
-
-	for _, npc := range g.monsters {
-		if npc.RoomNum != room.r.RoomNumber {
-			continue
-		}
-		if _, err = conn.Write(lurk.Marshal(npc)); err != nil {
-			return
-		}
-	}
-	return
+func (g *game) sendAllEntities(room *room, conn sender) (err error) {
+	return conn.SendEncoded(g.appendAllEntities(nil, room))
 }
 
-func (g *game) sendAllEntitiesToAll(room *room) (err error) {
-	for _, u := range g.users {
-		if u.c.RoomNum != room.r.RoomNumber {
-			continue
-		}
-		if err = g.sendAllEntities(room, u.conn); err != nil {
-			break
-		}
-	}
-	return
+// sends information on all users and monsters to every user in the room. The messages are
+// only marshaled once no matter how many users are in the room.
+func (g *game) sendAllEntitiesToAll(room *room) {
+	g.publish(roomTopic(room.r.RoomNumber), &event{frames: g.appendAllEntities(nil, room)})
 }
 
-// sends information on all characters to the specified 'conn'
-func (g *game) sendAllCharacters(room *room, conn net.Conn) (err error) {
+// appends all characters, monsters and the vendor in the room to dst.
+func (g *game) appendAllEntities(dst []byte, room *room) []byte {
 	for _, user := range g.users {
 		if user.c.RoomNum != room.r.RoomNumber {
 			continue
 		}
-		_, _ = conn.Write(lurk.Marshal(user.c))
+		dst = lurk.AppendMarshal(dst, user.c)
 	}
-	return
-}
 
-// Takes a user object and sends it to conn. Used for notifying other users of a user's status.
-// The message will be sent if the the recipient isn't allowed to know what room the user is
-// moving to.
-func (g *game) sendCharacterUpdate(user *lurk.Character, conn net.Conn, recipient string, message string) error {
-	if _, err := conn.Write(lurk.Marshal(user)); err != nil {
-		return err
+	for _, npc := range g.monsters {
+		if npc.RoomNum != room.r.RoomNumber {
+			continue
+		}
+		dst = lurk.AppendMarshal(dst, npc)
 	}
-
-	if message == "" {
-		return nil
+	if v, ok := g.vendors[room.r.RoomNumber]; ok {
+		dst = lurk.AppendMarshal(dst, v.c)
 	}
-
-	_, err := conn.Write(lurk.Marshal(&lurk.Message{
-		Type:      lurk.TypeMessage,
-		Recipient: recipient,
-		Sender:    narrator,
-		Text:      message,
-		Narration: true,
-	}))
-	return err
+	return dst
 }
 
-func (g *game) sendConnections(room *room, player string, conn net.Conn) (err error) {
+func (g *game) sendConnections(room *room, player string, conn sender) (err error) {
 	for _, connection := range room.connections {
 		if !g.users[player].allowedRoom[connection.RoomNumber] {
 			continue
 		}
-		if _, err = conn.Write(lurk.Marshal(connection)); err != nil {
+		if err = conn.Send(connection); err != nil {
 			return err
 		}
 	}
 	return
 }
-
-func (g *game) sendAccept(conn net.Conn, action lurk.MessageType) error {
-	accept := &lurk.Accept{
-		Type:   lurk.TypeAccept,
-		Action: action,
-	}
-	_, err := conn.Write(lurk.Marshal(accept))
-	return err
-}
-
-func (g *game) sendError(conn net.Conn, code cross.ErrCode, msg string) error {
-	_, err := conn.Write(lurk.Marshal(&lurk.Error{
-		Type:       lurk.TypeError,
-		ErrCode:    code,
-		ErrMessage: msg,
-	}))
-	return err
-}
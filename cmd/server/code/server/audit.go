@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/data"
+)
+
+// auditEntry is one line of the audit log, written for every direct message and every message
+// sent under someone else's name.
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Player    string    `json:"player"`
+	Sender    string    `json:"sender"`
+	Recipient string    `json:"recipient"`
+	Text      string    `json:"text"`
+	// Rejected says why the message wasn't delivered.
+	Rejected string `json:"rejected,omitempty"`
+}
+
+// auditBuffer is how many entries can wait for the disk. Past it the oldest are dropped, and
+// logged, rather than holding up the game.
+const auditBuffer = 1024
+
+// openAudit writes the audit log to w from then on until closeAudit.
+func (g *game) openAudit(w io.Writer) {
+	g.audits = data.NewQueueWithOverflow[*auditEntry](auditBuffer, data.DropOldest)
+	g.audited = make(chan struct{})
+	go g.writeAudit(json.NewEncoder(w), g.audits)
+}
+
+// record hands an entry to writeAudit, if there is an audit log. Must hold g.mu.
+func (g *game) record(entry *auditEntry) {
+	if g.audits == nil {
+		return
+	}
+	entry.Time = time.Now()
+	if err := g.audits.Enqueue(entry); err != nil {
+		g.log.Printf("%v: could not write to the audit log", err.Error())
+	}
+}
+
+// writeAudit writes entries away from g.mu, so players don't wait on the disk.
+func (g *game) writeAudit(enc *json.Encoder, entries *data.Queue[*auditEntry]) {
+	defer close(g.audited)
+	var dropped uint64
+	for {
+		entry, err := entries.Dequeue(context.Background())
+		if err != nil {
+			return
+		}
+		if n := entries.Dropped(); n > dropped {
+			g.log.Printf("The audit log fell behind and lost %d entries, %d in all", n-dropped, n)
+			dropped = n
+		}
+		if err := enc.Encode(entry); err != nil {
+			g.log.Printf("%v: could not write to the audit log", err.Error())
+		}
+	}
+}
+
+// closeAudit stops recording and waits for the entries already recorded to be written.
+func (g *game) closeAudit() {
+	g.mu.Lock()
+	audits := g.audits
+	g.audits = nil
+	g.mu.Unlock()
+	if audits == nil {
+		return
+	}
+	audits.Close()
+	<-g.audited
+}
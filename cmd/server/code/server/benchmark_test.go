@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// discardConn counts what is written to it and throws it away.
+type discardConn struct {
+	net.Conn
+	written atomic.Int64
+}
+
+func (c *discardConn) Write(ba []byte) (int, error) {
+	c.written.Add(int64(len(ba)))
+	return len(ba), nil
+}
+
+func (c *discardConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *discardConn) Close() error {
+	return nil
+}
+
+const benchUsers = 50
+
+// newCrowdedGame returns a game with benchUsers players all standing in the battle room and
+// subscribed to it, and their connections.
+func newCrowdedGame() (*game, *room, []*discardConn) {
+	g := newGame(defaultWorld(), log.Default())
+	conns := make([]*discardConn, 0, benchUsers)
+	for i := range benchUsers {
+		name := fmt.Sprintf("player %d", i)
+		u := &user{
+			c: &lurk.Character{
+				Type:       lurk.TypeCharacter,
+				Name:       name,
+				Flags:      map[string]bool{lurk.Alive: true, lurk.Started: true, lurk.Ready: true},
+				Attack:     30,
+				Defense:    30,
+				Regen:      30,
+				Health:     initialHealth,
+				RoomNum:    battleSchoolBattleRoom,
+				PlayerDesc: "Benchmark player",
+			},
+			conn:        newOutbox(g, name),
+			allowedRoom: map[uint16]bool{},
+		}
+		g.users[name] = u
+		conn := &discardConn{}
+		conns = append(conns, conn)
+		g.subscribe(u, lurk.NewConn(conn))
+		u.follow(0, battleSchoolBattleRoom)
+	}
+	return g, g.rooms[battleSchoolBattleRoom], conns
+}
+
+// roomBytes is how much one update of everyone in the room is.
+func roomBytes(g *game, room *room) int64 {
+	var n int64
+	for _, u := range g.users {
+		n += int64(len(lurk.Marshal(u.c)))
+	}
+	for _, npc := range g.monsters {
+		if npc.RoomNum == room.r.RoomNumber {
+			n += int64(len(lurk.Marshal(npc)))
+		}
+	}
+	return n
+}
+
+// waitWritten waits until every connection has had n bytes written to it.
+func waitWritten(conns []*discardConn, n int64) bool {
+	deadline := time.Now().Add(time.Second)
+	for _, conn := range conns {
+		for conn.written.Load() < n {
+			if time.Now().After(deadline) {
+				return false
+			}
+			runtime.Gosched()
+		}
+	}
+	return true
+}
+
+// BenchmarkFightBroadcastNaive marshals every entity again for every recipient, which is how
+// fight updates used to be sent.
+func BenchmarkFightBroadcastNaive(b *testing.B) {
+	g, room, conns := newCrowdedGame()
+	b.ReportAllocs()
+	for range b.N {
+		for _, conn := range conns {
+			for _, other := range g.users {
+				_, _ = conn.Write(lurk.Marshal(other.c))
+			}
+			for _, npc := range g.monsters {
+				if npc.RoomNum == room.r.RoomNumber {
+					_, _ = conn.Write(lurk.Marshal(npc))
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkFightBroadcast marshals the room once per fight and publishes it to everyone, each
+// iteration waits for the writes.
+func BenchmarkFightBroadcast(b *testing.B) {
+	g, room, conns := newCrowdedGame()
+	size := roomBytes(g, room)
+	b.ReportAllocs()
+	for i := range b.N {
+		g.sendAllEntitiesToAll(room)
+		waitWritten(conns, int64(i+1)*size)
+	}
+}
+
+func TestSendAllEntitiesToAll(t *testing.T) {
+	a := assert.New(t)
+	g, room, conns := newCrowdedGame()
+	g.sendAllEntitiesToAll(room)
+	a.True(waitWritten(conns, roomBytes(g, room)))
+}
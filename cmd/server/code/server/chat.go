@@ -0,0 +1,55 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// maxMessageLength keeps one player from flooding everyone else's screen.
+const maxMessageLength = 500
+
+// chatChannel reports whether recipient names a chat channel rather than a player, and
+// returns the channel's proper name. There are no parties yet, so no party channel.
+func chatChannel(recipient string) (string, bool) {
+	for _, channel := range []string{lurk.RoomChannel, lurk.GlobalChannel} {
+		if strings.EqualFold(recipient, channel) {
+			return channel, true
+		}
+	}
+	return "", false
+}
+
+// isReservedName is true for names the server uses as recipients, which no player can have.
+func isReservedName(name string) bool {
+	_, ok := chatChannel(name)
+	return ok || strings.EqualFold(name, narrator)
+}
+
+// handleChat sends msg to everyone on the channel but the sender. Must hold g.mu.
+func (g *game) handleChat(msg *lurk.Message, conn sender, player, channel string) error {
+	user, ok := g.users[player]
+	if !ok {
+		return cross.ErrUserNotInServer
+	}
+	if strings.TrimSpace(msg.Text) == "" {
+		return conn.SendError(cross.Other, "Say something first")
+	}
+
+	topic := globalTopic
+	if channel == lurk.RoomChannel {
+		topic = roomTopic(user.c.RoomNum)
+	}
+	g.publish(topic, &event{
+		frames: lurk.AppendMarshal(nil, &lurk.Message{
+			Type:      lurk.TypeMessage,
+			Recipient: channel,
+			Sender:    player,
+			Text:      msg.Text,
+		}),
+		skip: player,
+	})
+	g.log.Printf("%s sent message to %s\n", player, channel)
+	return conn.SendAccept(lurk.TypeMessage)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/data"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// Topics on the game's event bus. Every playing user is subscribed to their own topic, the
+// global topic and the room they are standing in. Everything sent to them goes over the bus,
+// so replies and events reach them in the order they happened.
+const globalTopic = "global"
+
+func roomTopic(room uint16) string {
+	return fmt.Sprintf("room:%d", room)
+}
+
+func playerTopic(name string) string {
+	return "player:" + name
+}
+
+// eventBuffer is how many events a user can fall behind by. Past it they have missed game
+// state, so they are disconnected instead of holding up the game or playing out of step.
+const eventBuffer = 1024
+
+// event is something to send: a reply to one user, or news for a room or everyone. frames are
+// marshaled once, when the event is published, and written as is to every subscriber.
+type event struct {
+	frames []byte
+	// narration is sent after frames, addressed to the subscriber.
+	narration string
+	// skip is a user who doesn't get the event, usually the one who caused it.
+	skip string
+}
+
+func characterEvent(c *lurk.Character, narration string) *event {
+	return &event{frames: lurk.AppendMarshal(nil, c), narration: narration}
+}
+
+func (g *game) publish(topic string, e *event) {
+	if err := g.events.Publish(context.Background(), topic, e); err != nil {
+		g.log.Printf("%v: could not publish to %s", err.Error(), topic)
+	}
+}
+
+// sender is where messages for one user go: a connection, or an outbox once they play.
+type sender interface {
+	Send(lm lurk.LurkMessage) error
+	SendEncoded(frames []byte) error
+	SendError(code cross.ErrCode, msg string) error
+	SendAccept(action lurk.MessageType) error
+}
+
+// outbox publishes to a user's own topic. Sending never waits on their connection, forward
+// writes to it.
+type outbox struct {
+	bus   *data.Bus[*event]
+	topic string
+}
+
+func newOutbox(g *game, name string) *outbox {
+	return &outbox{bus: g.events, topic: playerTopic(name)}
+}
+
+func (o *outbox) Send(lm lurk.LurkMessage) error {
+	frames := lurk.AppendMarshal(nil, lm)
+	if len(frames) == 0 {
+		return cross.ErrInvalidMessageType
+	}
+	return o.bus.Publish(context.Background(), o.topic, &event{frames: frames})
+}
+
+// SendEncoded copies frames, the caller may reuse them.
+func (o *outbox) SendEncoded(frames []byte) error {
+	return o.bus.Publish(context.Background(), o.topic, &event{frames: bytes.Clone(frames)})
+}
+
+func (o *outbox) SendError(code cross.ErrCode, msg string) error {
+	return o.Send(&lurk.Error{Type: lurk.TypeError, ErrCode: code, ErrMessage: msg})
+}
+
+func (o *outbox) SendAccept(action lurk.MessageType) error {
+	return o.Send(&lurk.Accept{Type: lurk.TypeAccept, Action: action})
+}
+
+// subscribe starts delivering the user's events to conn. From then on nothing is written to
+// conn directly. Must hold g.mu.
+func (g *game) subscribe(u *user, conn *lurk.Conn) {
+	u.events = g.events.Subscribe(eventBuffer, data.DropOldest, playerTopic(u.c.Name), globalTopic)
+	go g.forward(u.c.Name, conn, u.events)
+}
+
+// forward sends the user everything published to them until they unsubscribe. When the
+// connection can't keep up it is closed, and the user leaves as if they had disconnected.
+func (g *game) forward(name string, conn *lurk.Conn, events *data.Subscription[*event]) {
+	for {
+		e, err := events.Next(context.Background())
+		if err != nil {
+			return
+		}
+		if events.Dropped() != 0 {
+			g.log.Printf("%s fell more than %d events behind and was disconnected", name, eventBuffer)
+			_ = conn.Close()
+			return
+		}
+		if err := deliver(name, conn, e); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				g.log.Printf("%v: could not send to %s", err.Error(), name)
+			}
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+// deliver writes an event to one user, unless they caused it.
+func deliver(name string, conn *lurk.Conn, e *event) error {
+	if e.skip == name {
+		return nil
+	}
+	if len(e.frames) != 0 {
+		if err := conn.SendEncoded(e.frames); err != nil {
+			return err
+		}
+	}
+	if e.narration != "" {
+		return conn.Send(narration(name, e.narration))
+	}
+	return nil
+}
+
+// follow moves the user's room subscription. Zero stands for no room.
+func (u *user) follow(from, to uint16) {
+	if u.events == nil {
+		return
+	}
+	if from != 0 {
+		u.events.Remove(roomTopic(from))
+	}
+	if to != 0 {
+		u.events.Add(roomTopic(to))
+	}
+}
+
+// announceLeaving takes the user off the bus and tells their room they are gone. Must hold
+// g.mu.
+func (g *game) announceLeaving(u *user) {
+	if u.events != nil {
+		u.events.Unsubscribe()
+	}
+	oldRoom := u.c.RoomNum
+	u.c.RoomNum = 0
+	g.publish(roomTopic(oldRoom), characterEvent(u.c, fmt.Sprintf("%s left the server!", u.c.Name)))
+}
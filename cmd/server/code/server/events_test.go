@@ -0,0 +1,65 @@
+package server
+
+import (
+	"log"
+	"net"
+	"testing"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func TestEvents(t *testing.T) {
+	a := assert.New(t)
+
+	g := newGame(defaultWorld(), log.Default())
+	u := newTestUser(g, &lurk.Character{Name: "Listener", RoomNum: battleSchool})
+	u.conn = newOutbox(g, u.c.Name)
+	// Writes to a pipe wait for the reader, like a client that isn't reading yet.
+	server, client := net.Pipe()
+	defer cross.LogOnErr(client.Close)
+	g.subscribe(u, lurk.NewConn(server))
+	u.follow(0, battleSchool)
+	reader := lurk.NewConn(client)
+
+	t.Run("TestOrder", func(_ *testing.T) {
+		a.NoError(u.conn.SendAccept(lurk.TypeChangeRoom))
+		g.publish(roomTopic(battleSchool), &event{narration: "room news"})
+		a.NoError(u.conn.SendError(cross.Other, "a reply"))
+		g.publish(globalTopic, &event{narration: "global news"})
+		g.publish(roomTopic(battleSchool), &event{narration: "not for you", skip: u.c.Name})
+		g.publish(roomTopic(battleSchool+1), &event{narration: "another room"})
+		a.NoError(u.conn.SendAccept(lurk.TypeMessage))
+
+		want := []string{"ACCEPT", "room news", "a reply", "global news", "ACCEPT"}
+		for _, w := range want {
+			lm, err := reader.Receive()
+			a.NoError(err)
+			switch msg := lm.(type) {
+			case *lurk.Accept:
+				a.True(w == "ACCEPT")
+			case *lurk.Message:
+				a.True(msg.Text == w && msg.Recipient == u.c.Name)
+			case *lurk.Error:
+				a.True(msg.ErrMessage == w)
+			default:
+				a.True(false)
+			}
+		}
+	})
+	t.Run("TestFallingBehind", func(_ *testing.T) {
+		for range eventBuffer + 2 {
+			g.publish(globalTopic, &event{narration: "spam"})
+		}
+		// At most what was already being written arrives, then the connection is closed.
+		received := 0
+		for {
+			if _, err := reader.Receive(); err != nil {
+				break
+			}
+			received++
+		}
+		a.True(received < eventBuffer)
+	})
+}
@@ -4,12 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"maps"
-	"net"
 	"sync"
 	"time"
 
 	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/data"
 	"github.com/Clayal10/enders_game/pkg/lurk"
 )
 
@@ -22,23 +21,44 @@ type game struct {
 	monsters map[string]*lurk.Character
 	// key is room number. Need to be careful about multithreading this
 	rooms map[uint16]*room
+	// key is room number, there is at most one vendor in a room.
+	vendors map[uint16]*vendor
 
 	game    *lurk.Game
 	version *lurk.Version
+	world   *World
+	// key is monster name.
+	monsterDefs map[string]*MonsterDef
+	// key is item name.
+	items map[string]*ItemDef
+	log   *log.Logger
 
 	mu           sync.Mutex
 	lastActivity map[string]time.Time
 	healTimer    map[string]*time.Timer
+	// events carries notifications to users, see events.go.
+	events *data.Bus[*event]
+	// audits holds direct messages for moderation until they are written, audited is closed
+	// once the last one is. Nil when there is no audit file.
+	audits  *data.Queue[*auditEntry]
+	audited chan struct{}
+	// progress is every player's quest progress by name. With a quest file, snapshots of it
+	// go through saves and saved is closed once the last one is written.
+	progress map[string]*progress
+	saves    chan []byte
+	saved    chan struct{}
 }
 
 type user struct {
-	c    *lurk.Character
-	conn net.Conn
+	c      *lurk.Character
+	conn   sender
+	events *data.Subscription[*event]
 	// Key is room number. For conditional rooms. Users won't be able to see or access these rooms until true.
 	allowedRoom map[uint16]bool
-	killedQueen bool
-	killedFleet bool
-	terminated  bool
+	// items counts what the user carries by name, equipped is what they wear by slot.
+	items      map[string]int
+	equipped   map[string]*ItemDef
+	terminated bool
 }
 
 type room struct {
@@ -46,22 +66,25 @@ type room struct {
 	connections []*lurk.Connection
 }
 
-const (
-	initialHealth = 100
-	// gold values to unlock certain areas
-	erosGold = 100
-)
+const initialHealth = 100
 
 var errDisconnect = errors.New("disconnect")
 
 // when creating a new game, we need to initialize the rooms and all entities.
-func newGame() *game {
+func newGame(w *World, logger *log.Logger) *game {
 	g := &game{
 		users:        make(map[string]*user),
-		monsters:     make(map[string]*lurk.Character),
-		rooms:        make(map[uint16]*room),
+		monsters:     w.createMonsters(),
+		rooms:        w.createRooms(),
+		vendors:      w.createVendors(),
+		world:        w,
+		monsterDefs:  make(map[string]*MonsterDef),
+		items:        make(map[string]*ItemDef),
+		log:          logger,
 		lastActivity: make(map[string]time.Time),
 		healTimer:    make(map[string]*time.Timer),
+		progress:     make(map[string]*progress),
+		events:       data.NewBus[*event](),
 		version: &lurk.Version{
 			Type:  lurk.TypeVersion,
 			Major: 2,
@@ -70,41 +93,40 @@ func newGame() *game {
 
 		game: &lurk.Game{
 			Type:          lurk.TypeGame,
-			InitialPoints: initialPoints,
-			StatLimit:     statLimit,
-			GameDesc:      gameDescription,
+			InitialPoints: w.InitialPoints,
+			StatLimit:     w.StatLimit,
+			GameDesc:      w.Description,
 		},
 	}
-
-	g.createRooms()
-	g.createMonsters()
+	for _, m := range w.Monsters {
+		g.monsterDefs[m.Name] = m
+	}
+	for _, item := range w.Items {
+		g.items[item.Name] = item
+	}
 
 	return g
 }
 
-func (g *game) registerPlayer(conn net.Conn) (string, error) {
+func (g *game) registerPlayer(conn *lurk.Conn) (string, error) {
 	id, err := g.addUser(conn)
 	if err != nil {
 		return id, err
 	}
-	log.Printf("Added user %v", id)
+	g.log.Printf("Added user %v", id)
 
 	for {
-		buffer, _, err := lurk.ReadSingleMessage(conn) // accept START
-		if err != nil {
-			return id, err
-		}
-		msg, err := lurk.Unmarshal(buffer)
+		msg, err := conn.Receive() // accept START
 		if err != nil {
 			return id, err
 		}
 		if msg.GetType() == lurk.TypeStart {
-			if err = g.sendAccept(conn, lurk.TypeStart); err != nil { // accepted START
+			if err = conn.SendAccept(lurk.TypeStart); err != nil { // accepted START
 				return id, err
 			}
 			break
 		}
-		if err = g.sendError(conn, cross.NotReady, "Please send a [START] message"); err != nil {
+		if err = conn.SendError(cross.NotReady, "Please send a [START] message"); err != nil {
 			return id, err
 		}
 	}
@@ -112,21 +134,16 @@ func (g *game) registerPlayer(conn net.Conn) (string, error) {
 	return id, nil
 }
 
-func (g *game) addUser(conn net.Conn) (characterID string, err error) {
+func (g *game) addUser(conn *lurk.Conn) (characterID string, err error) {
 	// In this loop, we get the character and send it back after checking the validity of it.
 	for {
-		buffer, n, err := lurk.ReadSingleMessage(conn) // accept CHARACTER
-		if err != nil {
-			_ = g.sendError(conn, cross.Other, "Bad message, terminating connection.")
-			return characterID, err
-		}
-
-		msg, err := lurk.Unmarshal(buffer[:n])
+		msg, err := conn.Receive() // accept CHARACTER
 		if err != nil {
+			_ = conn.SendError(cross.Other, "Bad message, terminating connection.")
 			return characterID, err
 		}
 		if msg.GetType() != lurk.TypeCharacter {
-			if err := g.sendError(conn, cross.Other, "You must send a [CHARACTER] type."); err != nil {
+			if err := conn.SendError(cross.Other, "You must send a [CHARACTER] type."); err != nil {
 				return characterID, err
 			}
 			continue
@@ -136,20 +153,20 @@ func (g *game) addUser(conn net.Conn) (characterID string, err error) {
 		character := msg.(*lurk.Character)
 		if e := g.validateCharacter(character); e != cross.NoError {
 			g.mu.Unlock()
-			if err := g.sendError(conn, e, "Your [CHARACTER] has invalid stats"); err != nil {
+			if err := conn.SendError(e, "Your [CHARACTER] has invalid stats"); err != nil {
 				return characterID, err
 			}
 			continue
 		}
 
-		characterID = g.createUser(character, conn)
+		characterID = g.createUser(character)
 		g.mu.Unlock()
 
-		if _, err = conn.Write(lurk.Marshal(character)); err != nil {
+		if err = conn.Send(character); err != nil {
 			return characterID, err
 		}
 
-		if err = g.sendAccept(conn, lurk.TypeCharacter); err != nil { // accepted CHARACTER
+		if err = conn.SendAccept(lurk.TypeCharacter); err != nil { // accepted CHARACTER
 			return characterID, err
 		}
 
@@ -158,43 +175,37 @@ func (g *game) addUser(conn net.Conn) (characterID string, err error) {
 	return characterID, err
 }
 
-func (g *game) createUser(character *lurk.Character, conn net.Conn) string {
+func (g *game) createUser(character *lurk.Character) string {
 	// Character is good at this point, flip flag and wait for their start.
 	character.Flags[lurk.Ready] = true
 	character.Flags[lurk.Monster] = false
 	character.Flags[lurk.Alive] = true
 	character.Flags[lurk.Started] = true
 
-	character.RoomNum = battleSchool
 	character.Health = initialHealth
 	character.Gold = 0
-	character.RoomNum = battleSchool
+	character.RoomNum = g.world.StartRoom
 	u := &user{
 		c:           character,
-		conn:        conn,
+		conn:        newOutbox(g, character.Name),
 		allowedRoom: make(map[uint16]bool),
 	}
-	for room := battleSchool; room <= rotterdam; room++ {
-		u.allowedRoom[room] = true
-	}
-	for room := eros; room <= formicHomeWorld; room++ {
-		if character.Name == "Beans Shumaker" {
-			u.allowedRoom[room] = true
-		} else {
-			u.allowedRoom[room] = false
-		}
+	for _, room := range g.world.Rooms {
+		u.allowedRoom[room.Number] = !room.Hidden || character.Name == "Beans Shumaker"
 	}
+	g.unlockRooms(u)
 
 	g.users[character.Name] = u
 	return character.Name
 }
 
 func (g *game) validateCharacter(c *lurk.Character) cross.ErrCode {
-	if c.Attack+c.Defense+c.Regen > g.game.InitialPoints {
+	// In int, so large stats can't wrap around to a small sum.
+	if int(c.Attack)+int(c.Defense)+int(c.Regen) > int(g.game.InitialPoints) {
 		return cross.StatError
 	}
 
-	if _, ok := g.users[c.Name]; ok {
+	if _, ok := g.users[c.Name]; ok || isReservedName(c.Name) || g.isNPCName(c.Name) {
 		return cross.PlayerAlreadyExists
 	}
 
@@ -202,10 +213,19 @@ func (g *game) validateCharacter(c *lurk.Character) cross.ErrCode {
 }
 
 // An error returned from here results in termination of the client.
-func (g *game) startGameplay(player string, conn net.Conn) error {
-	// First, send the user information on their current room.
+func (g *game) startGameplay(player string, conn *lurk.Conn) error {
+	// Events only start now, so they can't come between the replies to CHARACTER and START.
+	// Everything from here on goes out through the user's outbox.
 	g.mu.Lock()
-	if err := g.sendRoom(g.rooms[battleSchool], player, conn); err != nil {
+	u, ok := g.users[player]
+	if !ok {
+		g.mu.Unlock()
+		return cross.ErrUserNotInServer
+	}
+	g.subscribe(u, conn)
+	out := u.conn
+	// First, send the user information on their current room.
+	if err := g.sendRoom(g.rooms[g.world.StartRoom], player, out); err != nil {
 		g.mu.Unlock()
 		return err
 	}
@@ -223,29 +243,23 @@ func (g *game) startGameplay(player string, conn net.Conn) error {
 			return nil
 		}
 
-		buffer, n, err := lurk.ReadSingleMessage(conn) // accept MESSAGE || CHARACTER || LEAVE
-		if err != nil {
-			_ = g.sendError(conn, cross.Other, "Bad message, try again.")
-			return err
-		}
-
-		lm, err := lurk.Unmarshal(buffer[:n])
+		lm, err := conn.Receive() // accept MESSAGE || CHARACTER || LEAVE
 		if err != nil {
-			_ = g.sendError(conn, cross.Other, "Bad message, try again.")
+			_ = out.SendError(cross.Other, "Bad message, try again.")
 			return err
 		}
 
-		if err, ok := g.messageSelection(lm, player, conn); err != nil {
+		if err, ok := g.messageSelection(lm, player, out); err != nil {
 			return err
 		} else if ok {
-			if err := g.checkStatusChange(user, conn); err != nil {
+			if err := g.checkStatusChange(user); err != nil {
 				return err
 			}
 			continue
 		}
 
 		// The message did not have proper fields for the message type.
-		if err = g.sendError(conn, cross.Other, fmt.Sprintf("Message contains invalid fields for type %d", lm.GetType())); err != nil {
+		if err = out.SendError(cross.Other, fmt.Sprintf("Message contains invalid fields for type %d", lm.GetType())); err != nil {
 			return err
 		}
 	}
@@ -257,70 +271,46 @@ func (g *game) notifyNewArrival(newbie string) error {
 		return cross.ErrUserNotInServer
 	}
 
-	for _, otherUser := range g.users {
-		if otherUser.c.RoomNum != battleSchool || otherUser.c.Name == newUser.c.Name {
-			continue
-		}
-		if err := g.sendCharacterUpdate(newUser.c, otherUser.conn, otherUser.c.Name,
-			fmt.Sprintf("%s joined battle school!", newUser.c.Name)); err != nil {
-			log.Printf("Could not send message to %s", otherUser.c.Name)
-		}
-	}
-
+	start := g.rooms[g.world.StartRoom]
+	arrival := characterEvent(newUser.c, fmt.Sprintf("%s joined %s!", newUser.c.Name, start.r.RoomName))
+	arrival.skip = newUser.c.Name
+	g.publish(roomTopic(start.r.RoomNumber), arrival)
+	newUser.follow(0, start.r.RoomNumber)
 	return nil
 }
 
-const upgradeCost = 50
-
-// A chance to update character stats after each action.
-func (g *game) checkStatusChange(user *user, conn net.Conn) error {
+// A chance to update character stats after each action. Gold objectives are checked here since
+// gold changes in so many ways.
+func (g *game) checkStatusChange(user *user) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	if err := askForUpgrade(user); err != nil {
+	if err := g.askForUpgrade(user); err != nil {
 		return err
 	}
-
-	status := map[uint16]bool{}
-	maps.Copy(status, user.allowedRoom)
-	if user.c.Gold > erosGold {
-		user.allowedRoom[eros] = true
-	}
-	if user.killedQueen {
-		user.allowedRoom[earth] = true
-		user.allowedRoom[shakespeare] = true
-	}
-	if user.killedFleet {
-		user.allowedRoom[formicHomeWorld] = true
-	}
-
-	if user.allowedRoom[eros] == status[eros] && // no change, don't send update
-		user.allowedRoom[formicHomeWorld] == status[formicHomeWorld] &&
-		user.allowedRoom[earth] == status[earth] &&
-		user.allowedRoom[shakespeare] == status[shakespeare] {
-		return nil
-	}
-	return g.sendConnections(g.rooms[user.c.RoomNum], user.c.Name, conn)
+	return g.advance(user, func(o *Objective) bool {
+		return o.Gold != 0 && user.c.Gold >= o.Gold
+	})
 }
 
-func askForUpgrade(user *user) (err error) {
-	if user.c.Gold >= upgradeCost && user.c.RoomNum == battleSchoolBarracks {
-		_, err = user.conn.Write(lurk.Marshal(&lurk.Message{
+func (g *game) askForUpgrade(user *user) (err error) {
+	if user.c.Gold >= upgradeCost && g.world.UpgradeRoom != 0 && user.c.RoomNum == g.world.UpgradeRoom {
+		err = user.conn.Send(&lurk.Message{
 			Recipient: user.c.Name,
 			Sender:    narrator,
 			Text: fmt.Sprintf(
-				"Looks like some of your hard work is paying off, spend %d gold to upgrade your stats. (Message %s to increase all stats by 5 points)",
-				upgradeCost, narrator),
+				"Looks like some of your hard work is paying off, spend %d gold for every %d points to upgrade your stats. (Message %s \"upgrade attack 5\" or any other stat and points)",
+				upgradeCost, upgradePoints, narrator),
 			Narration: true,
-		}))
+		})
 	}
 	return
 }
 
-func (g *game) messageSelection(lm lurk.LurkMessage, player string, conn net.Conn) (err error, _ bool) {
+func (g *game) messageSelection(lm lurk.LurkMessage, player string, conn sender) (err error, _ bool) {
 	switch lm.GetType() {
 	case lurk.TypeMessage:
 		msg := lm.(*lurk.Message)
-		err = g.handleMessage(msg, conn)
+		err = g.handleMessage(msg, conn, player)
 	case lurk.TypeChangeRoom:
 		msg := lm.(*lurk.ChangeRoom)
 		err = g.handleChangeRoom(msg, conn, player)
@@ -361,34 +351,7 @@ func (g *game) healMonster(monster *lurk.Character) {
 	if idle < monsterHealTime {
 		return
 	}
-	monster.Health = monsterHealth[monster.Name]
+	monster.Health = g.monsterDefs[monster.Name].maxHealth()
 	monster.Flags[lurk.Alive] = true
-	for _, user := range g.users {
-		if user.c.RoomNum != monster.RoomNum {
-			continue
-		}
-		if err := g.sendCharacterUpdate(monster, user.conn, user.c.Name, ""); err != nil {
-			log.Printf("%s: could not update user %v with updated monster health", err.Error(), user.c.Name)
-		}
-	}
-}
-
-func (g *game) upgradeStats(user *user, conn net.Conn) error {
-	if user.c.Gold < upgradeCost || user.c.RoomNum != battleSchoolBarracks {
-		return g.sendError(conn, cross.StatError, fmt.Sprintf(
-			"You must be in the Battle School Barracks with at least %d gold to upgrade your stats", upgradeCost))
-	}
-	if totalStats := user.c.Attack + user.c.Defense + user.c.Regen; totalStats-15 > statLimit {
-		return g.sendError(conn, cross.StatError, fmt.Sprintf(
-			"Your stat sum of %d is too high to upgrade any further", totalStats))
-	}
-	user.c.Attack += 5
-	user.c.Defense += 5
-	user.c.Regen += 5
-	user.c.Gold -= upgradeCost
-
-	for _, u := range g.users {
-		_, _ = u.conn.Write(lurk.Marshal(user.c))
-	}
-	return nil
+	g.publish(roomTopic(monster.RoomNum), characterEvent(monster, ""))
 }
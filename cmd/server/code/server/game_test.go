@@ -2,8 +2,13 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math/rand/v2"
 	"net"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +19,24 @@ import (
 
 const bufferLength = 128
 
+// Names and room numbers in the built in world.
+const (
+	hiveQueenCocoon = "Hive Queen Cacoon"
+
+	battleSchool           uint16 = 1 // Central hub / hallways / entrance / exit for battle school.
+	battleSchoolBarracks   uint16 = 2
+	battleSchoolGameRoom   uint16 = 3
+	battleSchoolBattleRoom uint16 = 4
+	formicStarSystem       uint16 = 5
+	rotterdam              uint16 = 6
+
+	// Hidden until a quest unlocks them, see the world file.
+	eros            uint16 = 11
+	shakespeare     uint16 = 12
+	earth           uint16 = 13
+	formicHomeWorld uint16 = 14
+)
+
 func TestReadAll(t *testing.T) {
 	a := assert.New(t)
 	t.Run("TestExtendedMessage", func(_ *testing.T) {
@@ -37,7 +60,7 @@ func TestReadAll(t *testing.T) {
 					ba := lurk.Marshal(&lurk.Character{ // should overflow the 128 default buffer
 						Type:       lurk.TypeCharacter,
 						Name:       "Verryyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyyy long name",
-						PlayerDesc: gameDescription + gameDescription + gameDescription,
+						PlayerDesc: strings.Repeat(defaultWorld().Description, 3),
 					})
 					n, err := c.Write(ba)
 					a.NoError(err)
@@ -61,7 +84,251 @@ func TestReadAll(t *testing.T) {
 		a.True(ok)
 
 		a.True(len(character.Name) == 32)
-		a.True(character.PlayerDesc == gameDescription+gameDescription+gameDescription)
+		a.True(character.PlayerDesc == strings.Repeat(defaultWorld().Description, 3))
+
+	})
+}
+
+// newTestUser adds a living player with the character c to g. What is sent to them is thrown
+// away.
+func newTestUser(g *game, c *lurk.Character) *user {
+	c.Type = lurk.TypeCharacter
+	c.Flags = map[string]bool{lurk.Alive: true}
+	u := &user{
+		c:           c,
+		conn:        lurk.NewConn(&discardConn{}),
+		allowedRoom: map[uint16]bool{},
+	}
+	g.users[c.Name] = u
+	return u
+}
+
+// isNarratorError takes what a narrator command returns, so isNarratorError(g.buy(u, args))
+// says whether the command was refused.
+func isNarratorError(_ string, err error) bool {
+	var ne *narratorError
+	return errors.As(err, &ne)
+}
+
+func TestUpgradeStats(t *testing.T) {
+	a := assert.New(t)
+
+	g := newGame(defaultWorld(), log.Default())
+	g.game.StatLimit = 150
+	u := newTestUser(g, &lurk.Character{
+		Name:    "Upgrader",
+		Attack:  40,
+		Defense: 30,
+		Regen:   20,
+		Gold:    60,
+		RoomNum: battleSchoolBarracks,
+	})
+
+	statError := func(args ...string) bool {
+		_, err := g.upgradeStats(u, args)
+		var ne *narratorError
+		return errors.As(err, &ne) && ne.code == cross.StatError
+	}
+	a.True(statError())
+	a.True(statError("attack"))
+	a.True(statError("luck", "5"))
+	a.True(statError("attack", "-5"))
+	a.True(statError("attack", "61"))             // over the per stat cap
+	a.True(statError("defense", "40", "r", "21")) // over the stat limit
+	a.True(statError("regen", "21"))              // over the gold
+
+	reply, err := g.upgradeStats(u, []string{"a", "10", "regen", "4", "attack", "1"})
+	a.NoError(err)
+	a.True(strings.Contains(reply, "attack 51"))
+	a.True(u.c.Attack == 51 && u.c.Defense == 30 && u.c.Regen == 24 && u.c.Gold == 10)
+	// Fewer points than a full upgrade cost their share, rounded up.
+	a.True(pointsCost(15) == 50 && pointsCost(3) == 10 && pointsCost(1) == 4)
+
+	// Stats that could wrap around a uint16 are refused.
+	u.c.Attack, u.c.Gold = 65535, 65535
+	a.True(statError("defense", "1"))
+}
+
+func TestInventory(t *testing.T) {
+	a := assert.New(t)
+
+	g := newGame(defaultWorld(), log.Default())
+	u := newTestUser(g, &lurk.Character{
+		Name:    "Collector",
+		Attack:  40,
+		Defense: 30,
+		Regen:   20,
+		Health:  50,
+		Gold:    200,
+		RoomNum: battleSchoolBarracks,
+	})
+
+	roll := 0.4
+	dropRoll = func() float64 { return roll }
+	defer func() { dropRoll = rand.Float64 }()
+	a.NoError(g.dropItems(u, g.monsterDefs["Bonito de Madrid"], u.conn))
+	a.True(u.items["Flash Suit"] == 1 && u.items["Ration Bar"] == 1)
+	roll = 0.6
+	a.NoError(g.dropItems(u, g.monsterDefs["Bonito de Madrid"], u.conn))
+	a.True(u.items["Flash Suit"] == 1 && u.items["Ration Bar"] == 2)
+	u.give("Flash Gun", 1)
+
+	a.True(isNarratorError(g.equip(u, []string{"ration"})))
+	a.True(isNarratorError(g.equip(u, []string{"flash"}))) // Gun or Suit?
+	a.True(isNarratorError(g.equip(u, []string{"hook"})))
+	_, err := g.equip(u, []string{"flash", "s"})
+	a.NoError(err)
+	_, err = g.equip(u, []string{"FLASH GUN"})
+	a.NoError(err)
+	a.True(u.c.Attack == 50 && u.c.Defense == 40 && u.c.Regen == 20)
+	a.True(len(u.items) == 1 && len(u.equipped) == 2)
+
+	// Equipment doesn't count toward the upgrade caps.
+	a.True(isNarratorError(g.upgradeStats(u, []string{"attack", "61"})))
+	_, err = g.upgradeStats(u, []string{"attack", "60"})
+	a.NoError(err)
+	a.True(u.c.Attack == 110 && u.c.Gold == 0)
 
+	reply, err := narrateInventory(g, u, nil)
+	a.NoError(err)
+	a.True(strings.Contains(reply, "2 Ration Bar") && strings.Contains(reply, "weapon: Flash Gun"))
+	reply, err = narrateStats(g, u, nil)
+	a.NoError(err)
+	a.True(strings.Contains(reply, "Attack: 110 (+10 from equipment)"))
+
+	_, err = g.unequip(u, []string{"weapon"})
+	a.NoError(err)
+	_, err = g.unequip(u, []string{"flash", "suit"})
+	a.NoError(err)
+	a.True(isNarratorError(g.unequip(u, []string{"hook"})))
+	a.True(u.c.Attack == 100 && u.c.Defense == 30 && len(u.equipped) == 0 && u.items["Flash Gun"] == 1)
+
+	a.True(isNarratorError(g.use(u, []string{"flash", "gun"})))
+	_, err = g.use(u, []string{"ration", "bar"})
+	a.NoError(err)
+	a.True(u.c.Health == 75 && u.items["Ration Bar"] == 1)
+	_, err = g.use(u, []string{"r"})
+	a.NoError(err)
+	a.True(u.c.Health == initialHealth && u.items["Ration Bar"] == 0)
+	a.True(isNarratorError(g.use(u, []string{"ration"})))
+}
+
+func TestShop(t *testing.T) {
+	a := assert.New(t)
+
+	g := newGame(defaultWorld(), log.Default())
+	u := newTestUser(g, &lurk.Character{
+		Name:    "Shopper",
+		Gold:    250,
+		RoomNum: battleSchool,
 	})
+
+	a.True(atVendor(g, u) != nil)
+	u.c.RoomNum = battleSchoolGameRoom
+	v := g.vendors[battleSchoolGameRoom]
+
+	_, err := g.buy(u, []string{"flash", "gun"})
+	a.NoError(err)
+	a.True(u.c.Gold == 150 && u.items["Flash Gun"] == 1 && v.stock["Flash Gun"] == 0)
+	a.True(isNarratorError(g.buy(u, []string{"flash", "gun"}))) // sold out
+	a.True(isNarratorError(g.buy(u, []string{"drink"})))        // never stocked
+	u.c.Gold = 79
+	a.True(isNarratorError(g.buy(u, []string{"flash", "suit"}))) // costs 80
+
+	// Each Ration Bar costs more as the stock runs down.
+	u.c.Gold = 1000
+	for range 5 {
+		_, err = g.buy(u, []string{"ration"})
+		a.NoError(err)
+	}
+	a.True(u.c.Gold == 1000-15-16-18-19-21 && v.buyPrice(g.items["Ration Bar"]) == 22)
+
+	// The vendor pays half, and less for what it already has too much of.
+	u.give("Giant's Drink", 2)
+	_, err = g.sell(u, []string{"giant's", "drink"})
+	a.NoError(err)
+	a.True(u.c.Gold == 911+60 && v.stock["Giant's Drink"] == 1)
+	reply, err := narrateShop(g, u, nil)
+	a.NoError(err)
+	a.True(strings.Contains(reply, "Giant's Drink - 120 gold, 1 left") && strings.Contains(reply, "Giant's Drink - 30 gold"))
+	a.True(isNarratorError(g.sell(u, []string{"hook"})))
+
+	// Sold out stock comes back once the restock time has passed.
+	v.restocked = v.restocked.Add(-time.Duration(v.def.RestockSeconds) * time.Second)
+	reply, err = narrateShop(g, u, nil)
+	a.NoError(err)
+	a.True(v.stock["Flash Gun"] == 1 && v.stock["Ration Bar"] == 10 && v.stock["Giant's Drink"] == 1)
+	a.True(strings.Contains(reply, "Flash Gun - 100 gold, 1 left"))
+}
+
+func TestQuests(t *testing.T) {
+	a := assert.New(t)
+
+	newPlayer := func(g *game) *user {
+		name := g.createUser(&lurk.Character{
+			Type:  lurk.TypeCharacter,
+			Name:  "Wiggin",
+			Flags: map[string]bool{},
+		})
+		return g.users[name]
+	}
+	g := newGame(defaultWorld(), log.Default())
+	questFile := filepath.Join(t.TempDir(), "quests.json")
+	a.NoError(g.loadProgress(questFile))
+	u := newPlayer(g)
+	a.True(!u.allowedRoom[eros] && !u.allowedRoom[formicHomeWorld])
+	// Joining, asking about quests and moving without meeting an objective keeps nothing.
+	_, err := narrateQuests(g, u, nil)
+	a.NoError(err)
+	a.NoError(g.advance(u, reached(battleSchoolBarracks)))
+	a.True(len(g.progress) == 0)
+
+	u.c.Gold = 101
+	a.NoError(g.checkStatusChange(u))
+	a.True(u.allowedRoom[eros])
+
+	// Objectives can be met in any order, but only once the quest has started.
+	a.NoError(g.advance(u, killed("Hive Queen")))
+	a.NoError(g.talk(u, g.monsters["Mazer Rackham"], u.conn))
+	reply, err := narrateQuests(g, u, nil)
+	a.NoError(err)
+	a.True(strings.Contains(reply, "[x] Talk to Mazer Rackham") && strings.Contains(reply, "[ ] Reach Eros"))
+	a.True(strings.Contains(reply, "Done: Command School") && !strings.Contains(reply, "Xenocide"))
+	a.NoError(g.advance(u, reached(eros)))
+	a.True(u.c.Gold == 151 && u.items["Giant's Drink"] == 1)
+
+	a.NoError(g.advance(u, killed("Formic Fleet")))
+	a.True(u.allowedRoom[formicHomeWorld] && !u.allowedRoom[earth])
+	a.NoError(g.advance(u, killed("Hive Queen")))
+	a.True(u.allowedRoom[earth] && u.allowedRoom[shakespeare])
+
+	// Players can't take a monster's or vendor's name, since it is how they are talked to.
+	a.True(g.validateCharacter(&lurk.Character{Name: "mazer rackham"}) == cross.PlayerAlreadyExists)
+	a.True(g.validateCharacter(&lurk.Character{Name: "Dink Meeker"}) == cross.PlayerAlreadyExists)
+
+	// A restarted server lets the player back into what they unlocked, without rewarding them
+	// again.
+	g.closeProgress()
+	g2 := newGame(defaultWorld(), log.Default())
+	a.NoError(g2.loadProgress(questFile))
+	defer g2.closeProgress()
+	u = newPlayer(g2)
+	a.True(u.allowedRoom[eros] && u.allowedRoom[earth] && u.c.Gold == 0)
+	a.NoError(g2.advance(u, killed("Hive Queen")))
+	a.True(len(u.items) == 0)
+}
+
+func TestWhoHidesLockedRooms(t *testing.T) {
+	a := assert.New(t)
+
+	g := newGame(defaultWorld(), log.Default())
+	asker := newTestUser(g, &lurk.Character{Name: "Asker", RoomNum: battleSchool})
+	asker.allowedRoom[battleSchool] = true
+	newTestUser(g, &lurk.Character{Name: "Explorer", RoomNum: formicHomeWorld})
+
+	reply, err := narrateWho(g, asker, nil)
+	a.NoError(err)
+	a.True(strings.Contains(reply, "Asker in Battle School"))
+	a.True(strings.Contains(reply, "Explorer in somewhere unknown"))
+	a.False(strings.Contains(reply, "Formic Home World"))
 }
@@ -2,485 +2,73 @@ package server
 
 import (
 	"fmt"
-	"log"
-	"net"
 	"time"
 
 	"github.com/Clayal10/enders_game/pkg/cross"
 	"github.com/Clayal10/enders_game/pkg/lurk"
 )
 
-// Descriptions / long text.
-const (
-	gameDescription = ` 
- ____  __ _  ____  ____  ____  _ ____     ___   __   _  _  ____ 
-(  __)(  ( \(    \(  __)(  _ \(// ___)   / __) / _\ ( \/ )(  __)
- ) _) /    / ) D ( ) _)  )   /  \___ \  ( (_ \/    \/ \/ \ ) _) 
-(____)\_)__)(____/(____)(__\_)  (____/   \___/\_/\_/\_)(_/(____)
-
-The world has been ravaged by the most feared and despised being known to man, the formic. When it comes down to preventing their second massacre, will you be the one to step up and destroy them?`
-	battleSchoolDesc           = "A place where young children play a game. At least, that is what the media says. The reality is that they will manipulate and contort their lives just to see what we can handle."
-	battleSchoolBarracksDesc   = "The room filled with small children, most of them scared, but none of them trying to show their weakness."
-	battleSchoolGameRoomDesc   = "Many older boys are hunched over the game table, just trying to show off to each other. You may be able to gain some experience if someone would give you the chance."
-	battleSchoolBattleRoomDesc = "A room, 100 cubic meters in size, defying the laws of gravity. With a gate on either side of the room, the children are able to wage war against each other for honor, all the while practicing zero G movement."
-	formicStarSystemDesc       = "Out here in the cold, dark vastness of space, a world filled with billions of alien life forms lay idle."
-	formicHomeWorldDesc        = "In all of the universe, one could not find a more perfect machine working under the surface of this planet. The queen instructs, and the workers follow. Flawlessly. To see this creature is to be in awe and trembling fear at the same time."
-	erosDesc                   = "The secret base for International Fleet Command operations. The surface is blacked out, covered in solar panels. The inhabitants stay below the surface in the smooth tunnels crafted by the formic race many years ago."
-	earthDesc                  = "A world doomed. A planet that needs a savior. To go back now is to let the wretched Formics win."
-	shakespeareDesc            = "The next frontier for human expansion. With the buggers eliminated, we can take their land and breed the next generation of humans and crops."
-	rotterdamDesc              = "A city of ruins. The streets are filled with starved children fighting to the death."
-)
-
-// Entity names.
-const (
-	// Friends
-	colonelGraph = "Colonel Graph"
-	bean         = "Bean"
-	mazer        = "Mazer Rackham"
-	petra        = "Petra Arkanian"
-	// Enemies
-	bonzo       = "Bonito de Madrid"
-	formicFleet = "Formic Fleet"
-	achilles    = "Achilles de Flandres"
-	peter       = "Peter Wiggin"
-	hiveQueen   = "Hive Queen"
-	// both
-	hiveQueenCocoon = "Hive Queen Cacoon"
-)
-
-// Room Numbers
-const (
-	battleSchool           uint16 = 1 // Central hub / hallways / entrance / exit for battle school.
-	battleSchoolBarracks   uint16 = 2
-	battleSchoolGameRoom   uint16 = 3
-	battleSchoolBattleRoom uint16 = 4
-	formicStarSystem       uint16 = 5
-	rotterdam              uint16 = 6
-
-	eros            uint16 = 11 // Hidden until defeating bonzo
-	shakespeare     uint16 = 12 // Hidden until defeating formics.
-	earth           uint16 = 13 // Hidden until defeating or losing to bonzo.
-	formicHomeWorld uint16 = 14
-)
-
-func (g *game) createRooms() {
-	g.rooms = map[uint16]*room{
-		battleSchool: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: battleSchool,
-				RoomName:   "Battle School",
-				RoomDesc:   battleSchoolDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: battleSchoolBarracks,
-					RoomName:   "The Barracks",
-					RoomDesc:   battleSchoolBarracksDesc,
-				},
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: battleSchoolGameRoom,
-					RoomName:   "The Game Room",
-					RoomDesc:   battleSchoolGameRoomDesc,
-				},
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: battleSchoolBattleRoom,
-					RoomName:   "The Battle Room",
-					RoomDesc:   battleSchoolBattleRoomDesc,
-				},
-				{ // secret.
-					Type:       lurk.TypeConnection,
-					RoomNumber: eros,
-					RoomName:   "Eros",
-					RoomDesc:   erosDesc,
-				},
-				{ // secret.
-					Type:       lurk.TypeConnection,
-					RoomNumber: earth,
-					RoomName:   "Earth",
-					RoomDesc:   earthDesc,
-				},
-			},
-		},
-		battleSchoolBarracks: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: battleSchoolBarracks,
-				RoomName:   "The Barracks",
-				RoomDesc:   battleSchoolBarracksDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: battleSchool,
-					RoomName:   "Battle School",
-					RoomDesc:   battleSchoolDesc,
-				},
-			},
-		},
-		battleSchoolGameRoom: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: battleSchoolGameRoom,
-				RoomName:   "The Game Room",
-				RoomDesc:   battleSchoolGameRoomDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: battleSchool,
-					RoomName:   "Battle School",
-					RoomDesc:   battleSchoolDesc,
-				},
-			},
-		},
-		battleSchoolBattleRoom: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: battleSchoolBattleRoom,
-				RoomName:   "The Battle Room",
-				RoomDesc:   battleSchoolBattleRoomDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: battleSchool,
-					RoomName:   "Battle School",
-					RoomDesc:   battleSchoolDesc,
-				},
-			},
-		},
-		formicStarSystem: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: formicStarSystem,
-				RoomName:   "Formic Star System",
-				RoomDesc:   formicStarSystemDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: formicHomeWorld,
-					RoomName:   "Formic Home World",
-					RoomDesc:   formicHomeWorldDesc,
-				},
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: shakespeare,
-					RoomName:   "Shakespeare Colony",
-					RoomDesc:   shakespeareDesc,
-				},
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: eros,
-					RoomName:   "Eros",
-					RoomDesc:   erosDesc,
-				},
-			},
-		},
-		eros: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: eros,
-				RoomName:   "Eros",
-				RoomDesc:   erosDesc,
-			},
-			connections: []*lurk.Connection{ // secret.
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: shakespeare,
-					RoomName:   "Shakespeare Colony",
-					RoomDesc:   shakespeareDesc,
-				},
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: formicStarSystem,
-					RoomName:   "Formic Star System",
-					RoomDesc:   formicStarSystemDesc,
-				},
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: battleSchool,
-					RoomName:   "Battle School",
-					RoomDesc:   battleSchoolDesc,
-				},
-			},
-		},
-		earth: { // No escape.
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: earth,
-				RoomName:   "Earth",
-				RoomDesc:   earthDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: rotterdam,
-					RoomName:   "Rotterdam, The Netherlands",
-					RoomDesc:   rotterdamDesc,
-				},
-			},
-		},
-		rotterdam: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: rotterdam,
-				RoomName:   "Rotterdam, The Netherlands",
-				RoomDesc:   rotterdamDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: earth,
-					RoomName:   "Earth",
-					RoomDesc:   earthDesc,
-				},
-			},
-		},
-		shakespeare: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: shakespeare,
-				RoomName:   "Shakespeare Colony",
-				RoomDesc:   shakespeareDesc,
-			},
-		},
-		formicHomeWorld: {
-			r: &lurk.Room{
-				Type:       lurk.TypeRoom,
-				RoomNumber: formicHomeWorld,
-				RoomName:   "Formic Home World",
-				RoomDesc:   formicHomeWorldDesc,
-			},
-			connections: []*lurk.Connection{
-				{
-					Type:       lurk.TypeConnection,
-					RoomNumber: formicStarSystem,
-					RoomName:   "Formic Star System",
-					RoomDesc:   formicStarSystemDesc,
-				},
-			},
-		},
-	}
-}
-
-var monsterHealth = map[string]int16{
-	colonelGraph:    50,
-	bean:            100,
-	petra:           100,
-	mazer:           100,
-	bonzo:           75,
-	formicFleet:     10000,
-	hiveQueen:       1000,
-	achilles:        1000,
-	peter:           1000,
-	hiveQueenCocoon: 1,
-}
-
-// The amount of gold you gain by defeating each monster
-var monsterGold = map[string]uint16{
-	colonelGraph:    10,
-	bean:            15,
-	petra:           20,
-	mazer:           100,
-	bonzo:           50,
-	formicFleet:     1000,
-	hiveQueen:       1000,
-	achilles:        1000,
-	peter:           1000,
-	hiveQueenCocoon: 64535,
-}
-
-func (g *game) createMonsters() {
-	g.monsters = map[string]*lurk.Character{
-		colonelGraph: {
-			Type: lurk.TypeCharacter,
-			Name: colonelGraph,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     20,
-			Defense:    100,
-			Regen:      100,
-			Health:     50,
-			Gold:       0,
-			RoomNum:    battleSchool,
-			PlayerDesc: "An older man, starting to let himself go, but sturdy non the less.",
-		},
-		bean: {
-			Type: lurk.TypeCharacter,
-			Name: bean,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     10,
-			Defense:    100,
-			Regen:      100,
-			Health:     100,
-			Gold:       0,
-			RoomNum:    battleSchoolBattleRoom,
-			PlayerDesc: "The littlest one in battle school. You would be mistaken to think that is an indication of his power, though.",
-		},
-		petra: {
-			Type: lurk.TypeCharacter,
-			Name: petra,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     20,
-			Defense:    80,
-			Regen:      100,
-			Health:     100,
-			Gold:       0,
-			RoomNum:    battleSchoolGameRoom,
-			PlayerDesc: "The only girl in battle school, but she can be more dangerous that most of the boys. She could be an important teacher at this point.",
-		},
-		mazer: {
-			Type: lurk.TypeCharacter,
-			Name: mazer,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     100,
-			Defense:    100,
-			Regen:      0,
-			Health:     100,
-			Gold:       0,
-			RoomNum:    eros,
-			PlayerDesc: "Once believed to be dead, the greatest commander in all of history has shown up again. It seems his only intention is to train the next great commander of history. He will accomplish his goal or kill someone in the process.",
-		},
-		bonzo: {
-			Type: lurk.TypeCharacter,
-			Name: bonzo,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     100,
-			Defense:    50,
-			Regen:      50,
-			Health:     75,
-			Gold:       0,
-			RoomNum:    battleSchoolBattleRoom,
-			PlayerDesc: "Benito de Madrid; pretty boy. He will fight till the death for his families honor. To cross Bonzo is to can be the worst mistake you will make in your potentially short life.",
-		},
-		formicFleet: {
-			Type: lurk.TypeCharacter,
-			Name: formicFleet,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     50,
-			Defense:    50,
-			Regen:      0,
-			Health:     1000,
-			Gold:       0,
-			RoomNum:    formicStarSystem,
-			PlayerDesc: "A fleet of not thousands, or tens of thousands, but millions of individual formic creatures. They seems to move as if instructed by a single mind, perhaps a queen.",
-		},
-		hiveQueen: {
-			Type: lurk.TypeCharacter,
-			Name: hiveQueen,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     0,
-			Defense:    0,
-			Regen:      0,
-			Health:     1000,
-			Gold:       0,
-			RoomNum:    formicHomeWorld,
-			PlayerDesc: "The epitome of beauty and horror. There isn't a more terrifying creature imaginable by man. All the propaganda back on earth does not do justice to the fear that this creature invokes in one's heart. At the same time though, there is nothing more beautiful. You can feel her presence in your own, her mind in yours. To kill this creature is to kill your own self.",
-		},
-		achilles: {
-			Type: lurk.TypeCharacter,
-			Name: achilles,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     100,
-			Defense:    100,
-			Regen:      50,
-			Health:     1000,
-			Gold:       0,
-			RoomNum:    rotterdam,
-			PlayerDesc: "This boy seems to have taken control of the streets. Starving children cling to him as their papa. However, few claim he is must more than that...",
-		},
-		peter: {
-			Type: lurk.TypeCharacter,
-			Name: peter,
-			Flags: map[string]bool{
-				lurk.Alive:   true,
-				lurk.Monster: true,
-			},
-			Attack:     100,
-			Defense:    100,
-			Regen:      50,
-			Health:     1000,
-			Gold:       0,
-			RoomNum:    earth,
-			PlayerDesc: "The boy who will take over the world. Peter will gain control of all those in his grasp, will you be his enemy or foe?",
-		},
-		hiveQueenCocoon: {
-			Type: lurk.TypeCharacter,
-			Name: hiveQueenCocoon,
-			Flags: map[string]bool{
-				lurk.Alive: true,
-			},
-			Attack:     0,
-			Defense:    0,
-			Regen:      0,
-			Health:     1,
-			Gold:       0,
-			RoomNum:    shakespeare,
-			PlayerDesc: "The next hive queen. Will you restore their race?",
-		},
-	}
-}
-
-const defaultWriteTimeout = time.Second
-
-func (g *game) handleMessage(msg *lurk.Message, conn net.Conn) error {
+func (g *game) handleMessage(msg *lurk.Message, conn sender, player string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	// Messages can only come from the player on the connection, never from another player or
+	// the narrator.
+	if msg.Sender != player {
+		g.record(&auditEntry{
+			Player:    player,
+			Sender:    msg.Sender,
+			Recipient: msg.Recipient,
+			Text:      msg.Text,
+			Rejected:  "sender is not the player",
+		})
+		g.log.Printf("%s tried to send a message as %s\n", player, msg.Sender)
+		return conn.SendError(cross.Other, fmt.Sprintf("You can only send messages as %s", player))
+	}
+
 	if msg.Recipient == narrator {
-		user, ok := g.users[msg.Sender]
+		user, ok := g.users[player]
 		if !ok {
 			return cross.ErrUserNotInServer
 		}
-		return g.upgradeStats(user, conn)
+		return g.handleNarratorCommand(user, msg.Text, conn)
 	}
 
-	recipient, ok := g.users[msg.Recipient]
-	if !ok {
-		return g.sendError(conn, cross.Other, fmt.Sprintf("User %s is not in the server", msg.Recipient))
+	if len(msg.Text) > maxMessageLength {
+		return conn.SendError(cross.Other, fmt.Sprintf("Messages can be at most %d bytes long", maxMessageLength))
 	}
-	defer func() {
-		_ = recipient.conn.SetWriteDeadline(time.Time{})
-	}()
-	if err := recipient.conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout)); err != nil {
-		return g.sendError(conn, cross.Other, fmt.Sprintf("FAILED to send message from %s to %s\n", msg.Sender, msg.Recipient))
+	if channel, ok := chatChannel(msg.Recipient); ok {
+		return g.handleChat(msg, conn, player, channel)
 	}
-	if _, err := recipient.conn.Write(lurk.Marshal(msg)); err != nil {
-		return g.sendError(conn, cross.Other, fmt.Sprintf("FAILED to send message from %s to %s\n", msg.Sender, msg.Recipient))
+
+	if user, ok := g.users[player]; ok {
+		if npc := g.npcIn(user.c.RoomNum, msg.Recipient); npc != nil {
+			return g.talk(user, npc, conn)
+		}
 	}
-	log.Printf("%s sent message to %s\n", msg.Sender, msg.Recipient)
-	return g.sendAccept(conn, lurk.TypeMessage)
+
+	recipient, ok := g.users[msg.Recipient]
+	if !ok {
+		return conn.SendError(cross.Other, fmt.Sprintf("User %s is not in the server", msg.Recipient))
+	}
+	entry := &auditEntry{Player: player, Sender: player, Recipient: recipient.c.Name, Text: msg.Text}
+	// Only the server narrates.
+	if err := recipient.conn.Send(&lurk.Message{
+		Type:      lurk.TypeMessage,
+		Recipient: recipient.c.Name,
+		Sender:    player,
+		Text:      msg.Text,
+	}); err != nil {
+		entry.Rejected = err.Error()
+		g.record(entry)
+		return conn.SendError(cross.Other, fmt.Sprintf("FAILED to send message from %s to %s\n", player, msg.Recipient))
+	}
+	g.record(entry)
+	g.log.Printf("%s sent message to %s\n", player, msg.Recipient)
+	return conn.SendAccept(lurk.TypeMessage)
 }
 
-func (g *game) handleChangeRoom(changeRoom *lurk.ChangeRoom, conn net.Conn, player string) error {
+func (g *game) handleChangeRoom(changeRoom *lurk.ChangeRoom, conn sender, player string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	user, ok := g.users[player]
@@ -499,16 +87,16 @@ func (g *game) handleChangeRoom(changeRoom *lurk.ChangeRoom, conn net.Conn, play
 	}
 
 	if !hasConnection {
-		return g.sendError(conn, cross.BadRoom, fmt.Sprintf("%v: error in changing room", cross.ErrRoomsNotConnected.Error()))
+		return conn.SendError(cross.BadRoom, fmt.Sprintf("%v: error in changing room", cross.ErrRoomsNotConnected.Error()))
 	}
 
 	newRoom, ok := g.rooms[changeRoom.RoomNumber]
 	if !ok {
-		return g.sendError(conn, cross.BadRoom, fmt.Sprintf("%v: error in changing room", cross.ErrInvalidRoomNumber.Error()))
+		return conn.SendError(cross.BadRoom, fmt.Sprintf("%v: error in changing room", cross.ErrInvalidRoomNumber.Error()))
 	}
 
 	// Send new room to user.
-	if user.c.RoomNum = newRoom.r.RoomNumber; user.c.RoomNum == battleSchoolBarracks {
+	if user.c.RoomNum = newRoom.r.RoomNumber; user.c.RoomNum == g.world.respawnRoom() {
 		user.c.Flags[lurk.Alive] = true
 		user.c.Health = initialHealth
 	}
@@ -517,28 +105,21 @@ func (g *game) handleChangeRoom(changeRoom *lurk.ChangeRoom, conn net.Conn, play
 		return err
 	}
 
-	// Message others in the room that they have left and those in the room they are going to.
+	// Tell others in the room that they have left and those in the room they are going to.
+	user.follow(currentRoom.r.RoomNumber, newRoom.r.RoomNumber)
+	g.publish(roomTopic(currentRoom.r.RoomNumber), characterEvent(user.c, ""))
 	for name, u := range g.users {
-		msg := ""
-		if rn := currentRoom.r.RoomNumber; u.c.RoomNum == rn {
-			if !u.allowedRoom[newRoom.r.RoomNumber] {
-				msg = fmt.Sprintf("%s has been sent orders out of here.", user.c.Name)
-			}
-			if err := g.sendCharacterUpdate(user.c, u.conn, name, msg); err != nil {
-				log.Printf("%s: error when sending character updates to %s", err, name)
-			}
-			// NOTE: This will send an updated character to the user.
-		} else if rn := newRoom.r.RoomNumber; u.c.RoomNum == rn {
-			if err := g.sendCharacterUpdate(user.c, u.conn, name, ""); err != nil {
-				log.Printf("%s: error when sending character updates to %s", err, name)
-			}
+		if u.c.RoomNum == currentRoom.r.RoomNumber && !u.allowedRoom[newRoom.r.RoomNumber] {
+			g.publish(playerTopic(name), &event{narration: fmt.Sprintf("%s has been sent orders out of here.", user.c.Name)})
 		}
 	}
+	// NOTE: This will send an updated character to the user.
+	g.publish(roomTopic(newRoom.r.RoomNumber), characterEvent(user.c, ""))
 
-	return nil
+	return g.advance(user, reached(newRoom.r.RoomNumber))
 }
 
-func (g *game) handleFight(conn net.Conn, player string) error {
+func (g *game) handleFight(conn sender, player string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	user, ok := g.users[player]
@@ -546,18 +127,20 @@ func (g *game) handleFight(conn net.Conn, player string) error {
 		return cross.ErrUserNotInServer
 	}
 	if !user.c.Flags[lurk.Alive] {
-		return g.sendError(conn, cross.NoFight, player+", you cannot fight when you are dead")
+		return conn.SendError(cross.NoFight, player+", you cannot fight when you are dead")
 	}
 
 	currentRoom := g.rooms[user.c.RoomNum]
 
 	var fights uint16 = 0
+	pvpOnly := ""
 	for _, monster := range g.monsters {
 		if monster.RoomNum != user.c.RoomNum || !monster.Flags[lurk.Alive] {
 			continue
 		}
-		if monster.Name == hiveQueenCocoon {
-			return g.sendError(conn, cross.Other, "If you wish to destroy the next hive queen, you must PVP fight.")
+		if !monster.Flags[lurk.Monster] {
+			pvpOnly = monster.Name
+			continue
 		}
 
 		g.lastActivity[monster.Name] = time.Now()
@@ -565,20 +148,22 @@ func (g *game) handleFight(conn net.Conn, player string) error {
 		fights++
 
 		if user.c.Flags[lurk.Alive] {
-			user.c.Gold += monsterGold[monster.Name]
+			user.c.Gold += g.monsterDefs[monster.Name].GoldReward
+			if !monster.Flags[lurk.Alive] {
+				if err := g.dropItems(user, g.monsterDefs[monster.Name], conn); err != nil {
+					return err
+				}
+			}
 		}
 
-		if monster.Name == hiveQueen && !monster.Flags[lurk.Alive] {
-			user.killedQueen = true
-		}
-		if monster.Name == formicFleet && !monster.Flags[lurk.Alive] {
-			user.killedFleet = true
+		if !monster.Flags[lurk.Alive] {
+			if err := g.defeated(user, monster, conn); err != nil {
+				return err
+			}
 		}
 
 		g.startHealTimer(monster)
-		if err := g.sendAllEntitiesToAll(currentRoom); err != nil {
-			return err
-		}
+		g.sendAllEntitiesToAll(currentRoom)
 	}
 
 	for _, u := range g.users {
@@ -592,13 +177,14 @@ func (g *game) handleFight(conn net.Conn, player string) error {
 		if user.c.Flags[lurk.Alive] {
 			user.c.Gold += 10
 		}
-		if err := g.sendAllEntitiesToAll(currentRoom); err != nil {
-			return err
-		}
+		g.sendAllEntitiesToAll(currentRoom)
 	}
 
+	if fights == 0 && pvpOnly != "" {
+		return conn.SendError(cross.Other, fmt.Sprintf("If you wish to fight %s, you must PVP fight.", pvpOnly))
+	}
 	if fights == 0 {
-		return g.sendError(conn, cross.NoFight, fmt.Sprintf(
+		return conn.SendError(cross.NoFight, fmt.Sprintf(
 			"No live monsters to fight in the room %v", currentRoom.r.RoomName,
 		))
 	}
@@ -606,42 +192,48 @@ func (g *game) handleFight(conn net.Conn, player string) error {
 	if user.c.Flags[lurk.Alive] {
 		return nil
 	}
-	log.Printf("%v died in a fight", user.c.Name)
+	g.log.Printf("%v died in a fight", user.c.Name)
 
-	_, err := conn.Write(lurk.Marshal(&lurk.Message{
+	err := conn.Send(&lurk.Message{
 		Recipient: player,
 		Sender:    narrator,
 		Narration: true,
 		Text:      "You have lost in battle. Regenerate your health to fight again.",
-	}))
+	})
 
 	return err
 }
 
-// Is only called in thread safe function.
-func (g *game) handleHiveQueenFight(user *user, conn net.Conn) error {
-	hq := g.monsters[hiveQueenCocoon]
-	if user.c.RoomNum != shakespeare {
-		return g.sendError(conn, cross.NoFight, fmt.Sprintf("user %s is not in the same room as you", hq.Name))
+// handleMonsterPVP fights a character without the monster flag, which only PVPFIGHT can.
+// Must hold g.mu.
+func (g *game) handleMonsterPVP(user *user, monster *lurk.Character, conn sender) error {
+	if user.c.RoomNum != monster.RoomNum {
+		return conn.SendError(cross.NoFight, fmt.Sprintf("user %s is not in the same room as you", monster.Name))
 	}
 
-	lurk.CalculateFight(user.c, hq)
-	if !hq.Flags[lurk.Alive] {
-		log.Printf("%s killed the hive queen cocoon\n", user.c.Name)
-		// Add more gameplay here.
-		if _, err := conn.Write(lurk.Marshal(&lurk.Message{
-			Recipient: user.c.Name,
-			Sender:    narrator,
-			Narration: true,
-			Text:      "You have committed true Xenocide.",
-		})); err != nil {
+	lurk.CalculateFight(user.c, monster)
+	if !monster.Flags[lurk.Alive] {
+		if err := g.defeated(user, monster, conn); err != nil {
+			return err
+		}
+	}
+	g.sendAllEntitiesToAll(g.rooms[user.c.RoomNum])
+	return nil
+}
+
+// defeated tells the user what the world has to say about the monster they killed and counts
+// it toward their quests. Must hold g.mu.
+func (g *game) defeated(user *user, monster *lurk.Character, conn sender) error {
+	g.log.Printf("%s killed %s", user.c.Name, monster.Name)
+	if text := g.monsterDefs[monster.Name].Defeat; text != "" {
+		if err := conn.Send(narration(user.c.Name, text)); err != nil {
 			return err
 		}
 	}
-	return g.sendAllEntitiesToAll(g.rooms[user.c.RoomNum])
+	return g.advance(user, killed(monster.Name))
 }
 
-func (g *game) handlePVPFight(pvp *lurk.PVPFight, conn net.Conn, player string) (err error) {
+func (g *game) handlePVPFight(pvp *lurk.PVPFight, conn sender, player string) (err error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	user, ok := g.users[player]
@@ -649,67 +241,65 @@ func (g *game) handlePVPFight(pvp *lurk.PVPFight, conn net.Conn, player string)
 		return cross.ErrUserNotInServer
 	}
 
-	if pvp.TargetName == hiveQueenCocoon {
-		return g.handleHiveQueenFight(user, conn)
+	if monster, ok := g.monsters[pvp.TargetName]; ok && !monster.Flags[lurk.Monster] {
+		return g.handleMonsterPVP(user, monster, conn)
 	}
 
 	target, ok := g.users[pvp.TargetName]
 	if !ok {
-		return g.sendError(conn, cross.Other, fmt.Sprintf("%v: error in PVP fighting", cross.ErrUserNotInServer.Error()))
+		return conn.SendError(cross.Other, fmt.Sprintf("%v: error in PVP fighting", cross.ErrUserNotInServer.Error()))
 	}
 
 	if user.c.RoomNum != target.c.RoomNum {
-		return g.sendError(conn, cross.NoFight, fmt.Sprintf("user %s is not in the same room as you", target.c.Name))
+		return conn.SendError(cross.NoFight, fmt.Sprintf("user %s is not in the same room as you", target.c.Name))
 	}
 
 	if !user.c.Flags[lurk.Alive] {
-		return g.sendError(conn, cross.NoFight, player+", you cannot fight when you are dead")
+		return conn.SendError(cross.NoFight, player+", you cannot fight when you are dead")
 	}
 	if !target.c.Flags[lurk.Alive] {
-		return g.sendError(conn, cross.NoFight, target.c.Name+" is already dead!")
+		return conn.SendError(cross.NoFight, target.c.Name+" is already dead!")
 	}
 
-	if _, err = target.conn.Write(lurk.Marshal(&lurk.Message{
+	if err = target.conn.Send(&lurk.Message{
 		Recipient: target.c.Name,
 		Sender:    narrator,
 		Text:      fmt.Sprintf("You have been engaged in combat by %v!", user.c.Name),
 		Narration: true,
-	})); err != nil {
+	}); err != nil {
 		return err
 	}
 
 	lurk.CalculateFight(user.c, target.c)
 
-	if err = g.sendAllEntitiesToAll(g.rooms[user.c.RoomNum]); err != nil {
-		return err
-	}
+	g.sendAllEntitiesToAll(g.rooms[user.c.RoomNum])
 
 	if !user.c.Flags[lurk.Alive] {
-		log.Printf("%v died in a fight", user.c.Name)
+		g.log.Printf("%v died in a fight", user.c.Name)
 
-		_, err = conn.Write(lurk.Marshal(&lurk.Message{
+		err = conn.Send(&lurk.Message{
 			Recipient: player,
 			Sender:    narrator,
 			Narration: true,
 			Text:      "You have lost in battle. Regenerate your health to fight again.",
-		}))
+		})
 	}
 
 	if !target.c.Flags[lurk.Alive] {
-		log.Printf("%v died in a fight", target.c.Name)
+		g.log.Printf("%v died in a fight", target.c.Name)
 
-		_, err = target.conn.Write(lurk.Marshal(&lurk.Message{
+		err = target.conn.Send(&lurk.Message{
 			Recipient: player,
 			Sender:    narrator,
 			Narration: true,
 			Text:      "You have lost in battle. Regenerate your health to fight again.",
-		}))
+		})
 	}
 
 	return err
 }
 
-func (g *game) handleLoot(conn net.Conn, loot *lurk.Loot, player string) error {
+func (g *game) handleLoot(conn sender, loot *lurk.Loot, player string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	user, ok := g.users[player]
@@ -719,16 +309,17 @@ func (g *game) handleLoot(conn net.Conn, loot *lurk.Loot, player string) error {
 
 	target, ok := g.users[loot.TargetName]
 	if !ok {
-		return g.sendError(conn, cross.NoTarget, cross.ErrUserNotInServer.Error())
+		return conn.SendError(cross.NoTarget, cross.ErrUserNotInServer.Error())
 	}
 
 	if target.c.Flags[lurk.Alive] || !user.c.Flags[lurk.Alive] || target.c.RoomNum != user.c.RoomNum {
-		return g.sendError(conn, cross.Other, "Invalid loot conditions!")
+		return conn.SendError(cross.Other, "Invalid loot conditions!")
 	}
 	lootedGold := target.c.Gold / 5
 	user.c.Gold += lootedGold
 	target.c.Gold /= 2
-	return g.sendAllEntitiesToAll(g.rooms[user.c.RoomNum])
+	g.sendAllEntitiesToAll(g.rooms[user.c.RoomNum])
+	return nil
 }
 
 func (g *game) handleLeave(player string) {
@@ -741,16 +332,5 @@ func (g *game) handleLeave(player string) {
 		return
 	}
 	user.terminated = true
-
-	oldRoom := user.c.RoomNum
-	user.c.RoomNum = 0
-
-	for _, other := range g.users {
-		if other.c.RoomNum != oldRoom {
-			continue
-		}
-		if err := g.sendCharacterUpdate(user.c, other.conn, other.c.Name, fmt.Sprintf("%s left the server!", player)); err != nil {
-			log.Printf("%v: error when updating others of leaving the server", err.Error())
-		}
-	}
+	g.announceLeaving(user)
 }
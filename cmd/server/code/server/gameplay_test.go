@@ -2,8 +2,12 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -47,17 +51,17 @@ func TestGameActions(t *testing.T) {
 		c, err := net.Dial("tcp", fmt.Sprintf("localhost:%v", port))
 		a.NoError(err)
 
-		g := newGame()
+		g := newGame(defaultWorld(), log.Default())
 
 		// No player
 		a.Error(g.handleChangeRoom(&lurk.ChangeRoom{
 			Type:       lurk.TypeChangeRoom,
 			RoomNumber: 100,
-		}, c, "Test"))
+		}, lurk.NewConn(c), "Test"))
 
 		testName := "test name"
 		g.users[testName] = &user{
-			conn: c,
+			conn: lurk.NewConn(c),
 			c: &lurk.Character{
 				Type:    lurk.TypeCharacter,
 				Name:    testName,
@@ -68,7 +72,7 @@ func TestGameActions(t *testing.T) {
 		a.NoError(g.handleChangeRoom(&lurk.ChangeRoom{
 			Type:       lurk.TypeChangeRoom,
 			RoomNumber: 100, // doesn't exist.
-		}, c, testName))
+		}, lurk.NewConn(c), testName))
 	})
 	t.Run("TestPVPFight", func(_ *testing.T) {
 		port := cross.GetFreePort()
@@ -293,7 +297,7 @@ func TestGameActions(t *testing.T) {
 		_, err = conn.Write(lurk.Marshal(&lurk.Message{
 			Recipient: narrator,
 			Sender:    "Test Guy",
-			Text:      " ",
+			Text:      "upgrade attack 5",
 		}))
 		a.NoError(err)
 		a.Eventually(func() bool {
@@ -311,7 +315,7 @@ func TestGameActions(t *testing.T) {
 		_, err = conn.Write(lurk.Marshal(&lurk.Message{
 			Recipient: narrator,
 			Sender:    "Test Guy",
-			Text:      " ",
+			Text:      "upgrade attack 15", // 50 gold, more than is left
 		}))
 		a.NoError(err)
 		a.Eventually(func() bool {
@@ -327,4 +331,229 @@ func TestGameActions(t *testing.T) {
 			return e.ErrCode == cross.StatError
 		}, time.Second, time.Millisecond)
 	})
+	t.Run("TestChatChannels", func(_ *testing.T) {
+		port := cross.GetFreePort()
+		cfg := &Config{
+			Port: port,
+		}
+
+		cfs, err := New(cfg)
+		a.NoError(err)
+		defer func() {
+			for _, cf := range cfs {
+				cf()
+			}
+		}()
+
+		player := func(name string) net.Conn {
+			return startClientConnection(a, cfg, &lurk.Character{
+				Name:       name,
+				Flags:      map[string]bool{lurk.Alive: true},
+				Attack:     10,
+				PlayerDesc: "Chatty",
+			})
+		}
+		// readChat skips everything but chat, returning nil if none comes.
+		readChat := func(conn net.Conn) *lurk.Message {
+			for {
+				_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+				ba, _, err := lurk.ReadSingleMessage(conn)
+				if err != nil {
+					return nil
+				}
+				lm, err := lurk.Unmarshal(ba)
+				a.NoError(err)
+				if msg, ok := lm.(*lurk.Message); ok && !msg.Narration {
+					return msg
+				}
+			}
+		}
+		speaker, listener, faraway := player("Speaker"), player("Listener"), player("Faraway")
+		_, err = faraway.Write(lurk.Marshal(&lurk.ChangeRoom{RoomNumber: battleSchoolBarracks}))
+		a.NoError(err)
+		a.Eventually(func() bool {
+			room := readUntil(a, lurk.TypeRoom, faraway)
+			return room != nil && room.(*lurk.Room).RoomNumber == battleSchoolBarracks
+		}, time.Second, time.Millisecond)
+
+		_, err = speaker.Write(lurk.Marshal(&lurk.Message{
+			Recipient: "room",
+			Sender:    "Speaker",
+			Text:      "Anyone here?",
+		}))
+		a.NoError(err)
+		msg := readChat(listener)
+		a.True(msg != nil && msg.Text == "Anyone here?" && msg.Sender == "Speaker" && msg.Recipient == lurk.RoomChannel)
+		a.True(readChat(faraway) == nil)
+
+		_, err = speaker.Write(lurk.Marshal(&lurk.Message{
+			Recipient: lurk.GlobalChannel,
+			Sender:    "Speaker",
+			Text:      "Hello everyone!",
+		}))
+		a.NoError(err)
+		for _, conn := range []net.Conn{listener, faraway} {
+			msg = readChat(conn)
+			a.True(msg != nil && msg.Text == "Hello everyone!" && msg.Recipient == lurk.GlobalChannel)
+		}
+		a.True(readChat(speaker) == nil)
+
+		_, err = speaker.Write(lurk.Marshal(&lurk.Message{
+			Recipient: lurk.GlobalChannel,
+			Sender:    "Speaker",
+			Text:      strings.Repeat("a", maxMessageLength+1),
+		}))
+		a.NoError(err)
+		e := readUntil(a, lurk.TypeError, speaker)
+		a.True(e != nil && strings.Contains(e.(*lurk.Error).ErrMessage, "at most"))
+
+		conn, err := net.Dial("tcp", fmt.Sprintf(":%v", cfg.Port))
+		a.NoError(err)
+		defer cross.LogOnErr(conn.Close)
+		_, err = conn.Write(lurk.Marshal(&lurk.Character{Name: "Everyone", PlayerDesc: "Impostor"}))
+		a.NoError(err)
+		e = readUntil(a, lurk.TypeError, conn)
+		a.True(e != nil && e.(*lurk.Error).ErrCode == cross.PlayerAlreadyExists)
+
+		for _, conn := range []net.Conn{speaker, listener, faraway} {
+			a.NoError(conn.Close())
+		}
+	})
+	t.Run("TestSenderSpoofing", func(_ *testing.T) {
+		port := cross.GetFreePort()
+		cfg := &Config{
+			Port:      port,
+			AuditFile: filepath.Join(t.TempDir(), "audit.jsonl"),
+		}
+
+		cfs, err := New(cfg)
+		a.NoError(err)
+		defer func() {
+			for _, cf := range cfs {
+				cf()
+			}
+		}()
+
+		player := func(name string) net.Conn {
+			return startClientConnection(a, cfg, &lurk.Character{
+				Name:       name,
+				Flags:      map[string]bool{lurk.Alive: true},
+				Attack:     10,
+				PlayerDesc: "Suspicious",
+			})
+		}
+		mallory, alice := player("Mallory"), player("Alice")
+		defer cross.LogOnErr(mallory.Close)
+		defer cross.LogOnErr(alice.Close)
+
+		for _, sender := range []string{"Alice", narrator} {
+			_, err = mallory.Write(lurk.Marshal(&lurk.Message{
+				Recipient: "Alice",
+				Sender:    sender,
+				Text:      "Give me your gold",
+				Narration: sender == narrator,
+			}))
+			a.NoError(err)
+			e := readUntil(a, lurk.TypeError, mallory)
+			a.True(e != nil && strings.Contains(e.(*lurk.Error).ErrMessage, "only send messages as Mallory"))
+		}
+
+		// Players can't narrate either.
+		_, err = mallory.Write(lurk.Marshal(&lurk.Message{
+			Recipient: "Alice",
+			Sender:    "Mallory",
+			Text:      "Hi",
+			Narration: true,
+		}))
+		a.NoError(err)
+		a.True(readUntil(a, lurk.TypeAccept, mallory) != nil)
+		var msg *lurk.Message
+		a.Eventually(func() bool {
+			lm := readUntil(a, lurk.TypeMessage, alice)
+			msg, _ = lm.(*lurk.Message)
+			return msg != nil && msg.Text == "Hi"
+		}, time.Second, time.Millisecond)
+		a.True(msg.Sender == "Mallory" && !msg.Narration)
+
+		// The audit log is written in the background.
+		var lines []string
+		a.Eventually(func() bool {
+			ba, err := os.ReadFile(cfg.AuditFile)
+			lines = strings.Split(strings.TrimSpace(string(ba)), "\n")
+			return err == nil && len(lines) == 3
+		}, time.Second, time.Millisecond)
+		entries := make([]auditEntry, len(lines))
+		for i, line := range lines {
+			a.NoError(json.Unmarshal([]byte(line), &entries[i]))
+			a.True(entries[i].Player == "Mallory" && entries[i].Recipient == "Alice")
+		}
+		a.True(entries[0].Sender == "Alice" && entries[0].Rejected != "")
+		a.True(entries[1].Sender == narrator && entries[1].Rejected != "")
+		a.True(entries[2].Sender == "Mallory" && entries[2].Text == "Hi" && entries[2].Rejected == "")
+	})
+	t.Run("TestNarratorCommands", func(_ *testing.T) {
+		port := cross.GetFreePort()
+		cfg := &Config{
+			Port: port,
+		}
+
+		cfs, err := New(cfg)
+		a.NoError(err)
+		defer func() {
+			for _, cf := range cfs {
+				cf()
+			}
+		}()
+
+		conn := startClientConnection(a, cfg, &lurk.Character{
+			Name:       "Curious",
+			Flags:      map[string]bool{lurk.Alive: true},
+			Attack:     10,
+			PlayerDesc: "Asks a lot of questions",
+		})
+		defer cross.LogOnErr(conn.Close)
+
+		ask := func(text string) lurk.LurkMessage {
+			_, err := conn.Write(lurk.Marshal(&lurk.Message{
+				Recipient: narrator,
+				Sender:    "Curious",
+				Text:      text,
+			}))
+			a.NoError(err)
+			for {
+				lm := readUntil(a, lurk.TypeMessage, conn)
+				if lm == nil {
+					return nil
+				}
+				msg := lm.(*lurk.Message)
+				// Skip the arrival and upgrade hints.
+				if msg.Sender == narrator && !strings.Contains(msg.Text, "joined") {
+					return msg
+				}
+			}
+		}
+		narration := func(text string) string {
+			lm := ask(text)
+			a.True(lm != nil)
+			return lm.(*lurk.Message).Text
+		}
+
+		help := narration("")
+		a.True(strings.Contains(help, "who - lists everyone playing"))
+		a.True(strings.Contains(help, upgradeUsage+" - ") && strings.Contains(help, "only do that in The Barracks"))
+		a.True(strings.Contains(narration("who"), "Curious in Battle School"))
+		where := narration("WHERE")
+		a.True(strings.Contains(where, "You are in Battle School (1)") && strings.Contains(where, "2 The Barracks"))
+		mapText := narration("map")
+		a.True(strings.Contains(mapText, "1 Battle School -> 2, 3, 4 (you are here)"))
+		a.False(strings.Contains(mapText, "Eros"))
+		a.True(strings.Contains(narration("stats"), "Attack: 10"))
+
+		for _, text := range []string{"upgrade", "dance"} {
+			_, err := conn.Write(lurk.Marshal(&lurk.Message{Recipient: narrator, Sender: "Curious", Text: text}))
+			a.NoError(err)
+			e := readUntil(a, lurk.TypeError, conn)
+			a.True(e != nil)
+		}
+	})
 }
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+	"github.com/Clayal10/enders_game/pkg/ws"
+)
+
+// The gateway lets browsers play without the client backend. Each WebSocket message is one
+// LURK message in its JSON form, such as {"type":"CHANGEROOM","roomNumber":2}, in both
+// directions. Binary WebSocket messages are passed through as raw LURK bytes.
+const gatewayPath = "/lurk"
+
+type gateway struct {
+	server   *http.Server
+	listener net.Listener
+	rec      *receiver
+}
+
+func newGateway(cfg *Config, rec *receiver, tlsCfg *tls.Config) (*gateway, error) {
+	l, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%v", cfg.WebSocketPort))
+	if err != nil {
+		rec.log.Printf("Could not listen on WebSocket port %v", cfg.WebSocketPort)
+		return nil, err
+	}
+	if tlsCfg != nil {
+		l = tls.NewListener(l, tlsCfg)
+	}
+
+	gw := &gateway{
+		listener: l,
+		rec:      rec,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+gatewayPath, gw.handleWebSocket)
+	gw.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: handshakeTimeout,
+	}
+	return gw, nil
+}
+
+func (gw *gateway) start() {
+	go func() {
+		if err := gw.server.Serve(gw.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			gw.rec.log.Printf("%v: WebSocket gateway stopped", err.Error())
+		}
+	}()
+}
+
+func (gw *gateway) stop() {
+	cross.LogOnErr(gw.server.Close)
+}
+
+func (gw *gateway) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		gw.rec.log.Printf("%v: could not upgrade %v", err.Error(), r.RemoteAddr)
+		return
+	}
+	// The connection has been hijacked, the player is handled just like a TCP client.
+	gw.rec.registerUser(&jsonConn{ws: conn})
+}
+
+// jsonConn is a net.Conn that translates between LURK bytes on the game side and JSON
+// WebSocket messages on the browser side.
+type jsonConn struct {
+	ws *ws.Conn
+	// pending holds LURK bytes from the browser which the game hasn't read yet.
+	pending []byte
+	// out holds the start of a LURK message written by the game until the rest arrives.
+	out []byte
+}
+
+func (c *jsonConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		mt, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if mt == ws.BinaryMessage {
+			c.pending = append(c.pending, data...)
+			continue
+		}
+
+		lm, err := lurk.DecodeJSON(data)
+		if err != nil {
+			// The game never sees the bad message, so answer it here.
+			if err = c.writeJSON(&lurk.Error{
+				Type:       lurk.TypeError,
+				ErrCode:    cross.Other,
+				ErrMessage: fmt.Sprintf("%v: bad JSON message", err),
+			}); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		c.pending = lurk.AppendMarshal(c.pending, lm)
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write is only called by a lurk.Conn, which never writes from two goroutines at once.
+func (c *jsonConn) Write(p []byte) (int, error) {
+	c.out = append(c.out, p...)
+	rest := c.out
+	for len(rest) > 0 {
+		n, err := lurk.FrameLength(rest)
+		if errors.Is(err, cross.ErrFrameTooSmall) || (err == nil && n > len(rest)) {
+			break
+		}
+		if err != nil {
+			c.out = c.out[:0]
+			return 0, err
+		}
+
+		lm, err := lurk.Unmarshal(rest[:n])
+		rest = rest[n:]
+		if err != nil {
+			c.out = append(c.out[:0], rest...)
+			return 0, err
+		}
+		if err = c.writeJSON(lm); err != nil {
+			c.out = append(c.out[:0], rest...)
+			return 0, err
+		}
+	}
+	c.out = append(c.out[:0], rest...)
+	return len(p), nil
+}
+
+func (c *jsonConn) writeJSON(lm lurk.LurkMessage) error {
+	ba, err := json.Marshal(lm)
+	if err != nil {
+		return err
+	}
+	return c.ws.WriteMessage(ws.TextMessage, ba)
+}
+
+func (c *jsonConn) Close() error {
+	return c.ws.Close()
+}
+
+func (c *jsonConn) LocalAddr() net.Addr {
+	return c.ws.NetConn().LocalAddr()
+}
+
+func (c *jsonConn) RemoteAddr() net.Addr {
+	return c.ws.NetConn().RemoteAddr()
+}
+
+func (c *jsonConn) SetDeadline(t time.Time) error {
+	return c.ws.NetConn().SetDeadline(t)
+}
+
+func (c *jsonConn) SetReadDeadline(t time.Time) error {
+	return c.ws.NetConn().SetReadDeadline(t)
+}
+
+func (c *jsonConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.NetConn().SetWriteDeadline(t)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+	"github.com/Clayal10/enders_game/pkg/ws"
+)
+
+func TestWebSocketGateway(t *testing.T) {
+	a := assert.New(t)
+	cfg := &Config{
+		Port:          cross.GetFreePort(),
+		WebSocketPort: cross.GetFreePort(),
+	}
+	cfs, err := New(cfg)
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+
+	conn, err := ws.Dial(fmt.Sprintf("ws://localhost:%v%v", cfg.WebSocketPort, gatewayPath), nil)
+	a.NoError(err)
+	defer cross.LogOnErr(conn.Close)
+	a.NoError(conn.NetConn().SetReadDeadline(time.Now().Add(2 * time.Second)))
+
+	receive := func() lurk.LurkMessage {
+		mt, data, err := conn.ReadMessage()
+		a.NoError(err)
+		a.True(mt == ws.TextMessage)
+		lm, err := lurk.DecodeJSON(data)
+		a.NoError(err)
+		return lm
+	}
+	send := func(text string) {
+		a.NoError(conn.WriteMessage(ws.TextMessage, []byte(text)))
+	}
+
+	a.True(receive().GetType() == lurk.TypeVersion)
+	a.True(receive().GetType() == lurk.TypeGame)
+
+	send(`{"type": "CHARACTER", "name": "Browser`)
+	e, ok := receive().(*lurk.Error)
+	a.True(ok)
+	a.True(strings.Contains(e.ErrMessage, "bad JSON message"))
+
+	send(`{"type": "CHARACTER", "name": "Browser", "attack": 30, "defense": 30, "regen": 30, "flags": {"Ready": true}}`)
+	char, ok := receive().(*lurk.Character)
+	a.True(ok)
+	a.True(char.Name == "Browser")
+	a.True(receive().GetType() == lurk.TypeAccept)
+
+	send(`{"type": "START"}`)
+	a.True(receive().GetType() == lurk.TypeAccept)
+	room, ok := receive().(*lurk.Room)
+	a.True(ok)
+	a.True(room.RoomNumber == battleSchool)
+
+	// Native clients see the browser player.
+	tcp := startClientConnection(a, cfg, &lurk.Character{
+		Name:    "Native",
+		Attack:  10,
+		Defense: 10,
+		Regen:   10,
+		Flags:   map[string]bool{lurk.Ready: true},
+	})
+	defer cross.LogOnErr(tcp.Close)
+	a.Eventually(func() bool {
+		lm := readUntil(a, lurk.TypeCharacter, tcp)
+		c, ok := lm.(*lurk.Character)
+		return ok && c.Name == "Browser"
+	}, time.Second, 10*time.Millisecond)
+
+	// The server hangs up after LEAVE.
+	send(`{"type": "LEAVE"}`)
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	a.True(errors.Is(err, io.EOF))
+}
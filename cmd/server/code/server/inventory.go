@@ -0,0 +1,250 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// dropRoll decides whether a monster's drop happens. Tests replace it.
+var dropRoll = rand.Float64
+
+// give adds count of the item to what the user carries.
+func (u *user) give(name string, count int) {
+	if u.items == nil {
+		u.items = make(map[string]int)
+	}
+	u.items[name] += count
+}
+
+// take removes one of the item.
+func (u *user) take(name string) {
+	if u.items[name]--; u.items[name] <= 0 {
+		delete(u.items, name)
+	}
+}
+
+// bonus is what the user's equipment adds to each stat.
+func (u *user) bonus() map[string]int {
+	b := map[string]int{}
+	for _, item := range u.equipped {
+		b["attack"] += int(item.Attack)
+		b["defense"] += int(item.Defense)
+		b["regen"] += int(item.Regen)
+	}
+	return b
+}
+
+// dropItems gives the user whatever the defeated monster leaves behind. Must hold g.mu.
+func (g *game) dropItems(u *user, def *MonsterDef, conn sender) error {
+	for _, drop := range def.Drops {
+		if drop.Chance != 0 && dropRoll() >= drop.Chance {
+			continue
+		}
+		u.give(drop.Item, 1)
+		if err := conn.Send(narration(u.c.Name, fmt.Sprintf(
+			"%s dropped %s. Message %s \"inventory\" to see what you carry.", def.Name, drop.Item, narrator))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// showCharacter sends the user's changed stats to them and everyone in their room. Must hold
+// g.mu.
+func (g *game) showCharacter(u *user) error {
+	if err := u.conn.Send(u.c); err != nil {
+		return err
+	}
+	g.publish(roomTopic(u.c.RoomNum), &event{frames: lurk.AppendMarshal(nil, u.c), skip: u.c.Name})
+	return nil
+}
+
+func narrateInventory(g *game, u *user, _ []string) (string, error) {
+	if len(u.items) == 0 && len(u.equipped) == 0 {
+		return "You aren't carrying anything.", nil
+	}
+	lines := []string{"You carry:"}
+	for _, name := range sortedKeys(u.items) {
+		lines = append(lines, fmt.Sprintf("  %d %s - %s", u.items[name], name, describeItem(g.items[name])))
+	}
+	if len(u.equipped) != 0 {
+		lines = append(lines, "Equipped:")
+	}
+	for _, slot := range sortedKeys(u.equipped) {
+		item := u.equipped[slot]
+		lines = append(lines, fmt.Sprintf("  %s: %s - %s", slot, item.Name, describeItem(item)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func describeItem(item *ItemDef) string {
+	if item == nil {
+		return "gone from this world"
+	}
+	var effects []string
+	for _, stat := range []struct {
+		name  string
+		value uint16
+	}{{"attack", item.Attack}, {"defense", item.Defense}, {"regen", item.Regen}} {
+		if stat.value != 0 {
+			effects = append(effects, fmt.Sprintf("+%d %s", stat.value, stat.name))
+		}
+	}
+	if item.Heal != 0 {
+		effects = append(effects, fmt.Sprintf("heals %d", item.Heal))
+	}
+	if item.Slot != "" {
+		effects = append(effects, "worn as "+item.Slot)
+	}
+	if len(effects) == 0 {
+		return item.Description
+	}
+	return fmt.Sprintf("%s (%s)", item.Description, strings.Join(effects, ", "))
+}
+
+func (g *game) equip(u *user, args []string) (string, error) {
+	name, err := findName(strings.Join(args, " "), sortedKeys(u.items))
+	if err != nil {
+		return "", err
+	}
+	item := g.items[name]
+	if item == nil || item.Slot == "" {
+		return "", &narratorError{cross.Other, name + " can't be equipped"}
+	}
+
+	old := u.equipped[item.Slot]
+	if err := wear(u.c, old, item); err != nil {
+		return "", err
+	}
+	u.take(name)
+	if u.equipped == nil {
+		u.equipped = make(map[string]*ItemDef)
+	}
+	u.equipped[item.Slot] = item
+	reply := fmt.Sprintf("You equip %s.", name)
+	if old != nil {
+		u.give(old.Name, 1)
+		reply = fmt.Sprintf("You put away %s and equip %s.", old.Name, name)
+	}
+	return reply, g.showCharacter(u)
+}
+
+// unequip takes the name of an equipped item or its slot.
+func (g *game) unequip(u *user, args []string) (string, error) {
+	typed := strings.Join(args, " ")
+	var names []string
+	for _, item := range u.equipped {
+		names = append(names, item.Name)
+	}
+	sort.Strings(names)
+	if item, ok := u.equipped[strings.ToLower(typed)]; ok {
+		typed = item.Name
+	}
+	name, err := findName(typed, names)
+	if err != nil {
+		return "", err
+	}
+	for slot, item := range u.equipped {
+		if item.Name != name {
+			continue
+		}
+		if err := wear(u.c, item, nil); err != nil {
+			return "", err
+		}
+		delete(u.equipped, slot)
+		u.give(name, 1)
+	}
+	return fmt.Sprintf("You put away %s.", name), g.showCharacter(u)
+}
+
+func (g *game) use(u *user, args []string) (string, error) {
+	name, err := findName(strings.Join(args, " "), sortedKeys(u.items))
+	if err != nil {
+		return "", err
+	}
+	item := g.items[name]
+	if item == nil || item.Heal == 0 {
+		return "", &narratorError{cross.Other, name + " can't be used"}
+	}
+	if !u.c.Flags[lurk.Alive] {
+		return "", &narratorError{cross.Other, "You can't use anything while you are dead"}
+	}
+	// Healing never takes away health regen has given beyond the usual maximum.
+	u.c.Health = max(u.c.Health, min(u.c.Health+item.Heal, initialHealth))
+	u.take(name)
+	return fmt.Sprintf("You use %s and have %d health.", name, u.c.Health), g.showCharacter(u)
+}
+
+// wear swaps the stats of the old item, if any, for those of the new one. Nothing changes if
+// a stat would go out of range.
+func wear(c *lurk.Character, old, item *ItemDef) error {
+	stats := []*uint16{&c.Attack, &c.Defense, &c.Regen}
+	next := make([]int, len(stats))
+	for i, stat := range stats {
+		next[i] = int(*stat)
+	}
+	if old != nil {
+		next[0] -= int(old.Attack)
+		next[1] -= int(old.Defense)
+		next[2] -= int(old.Regen)
+	}
+	if item != nil {
+		next[0] += int(item.Attack)
+		next[1] += int(item.Defense)
+		next[2] += int(item.Regen)
+	}
+	for _, n := range next {
+		if n < 0 || n > math.MaxUint16 {
+			return &narratorError{cross.StatError, "That would put your stats out of range"}
+		}
+	}
+	for i, stat := range stats {
+		*stat = uint16(next[i])
+	}
+	return nil
+}
+
+// findName matches what the player typed against the names of what they carry, ignoring
+// case. A prefix that only matches one name is enough.
+func findName(typed string, names []string) (string, error) {
+	return matchName(typed, names, "You don't have %q")
+}
+
+// matchName is findName with the error to give, formatted with what was typed, when nothing
+// matches.
+func matchName(typed string, names []string, missing string) (string, error) {
+	if typed == "" {
+		return "", &narratorError{cross.Other, "Which item?"}
+	}
+	var found []string
+	for _, name := range names {
+		if strings.EqualFold(name, typed) {
+			return name, nil
+		}
+		if len(name) >= len(typed) && strings.EqualFold(name[:len(typed)], typed) {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", &narratorError{cross.Other, fmt.Sprintf(missing, typed)}
+	case 1:
+		return found[0], nil
+	}
+	return "", &narratorError{cross.Other, fmt.Sprintf("Did you mean %s?", strings.Join(found, " or "))}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// narratorCommand is something players can ask for by messaging the narrator, such as "who".
+type narratorCommand struct {
+	name  string
+	usage string
+	help  string
+	// gate, when set, says why the player can't use the command right now.
+	gate func(g *game, u *user) *narratorError
+	// run returns the narration sent back to the player.
+	run func(g *game, u *user, args []string) (string, error)
+}
+
+// narratorError is sent back as an ERROR instead of narration.
+type narratorError struct {
+	code cross.ErrCode
+	text string
+}
+
+func (e *narratorError) Error() string {
+	return e.text
+}
+
+var narratorCommands []*narratorCommand
+
+func init() {
+	narratorCommands = []*narratorCommand{
+		{"help", "help", "lists what the narrator can do for you", nil, narrateHelp},
+		{"who", "who", "lists everyone playing", nil, narrateWho},
+		{"where", "where", "describes your room and its exits", nil, narrateWhere},
+		{"stats", "stats", "shows your stats and gold", nil, narrateStats},
+		{"map", "map", "shows every room you know of and where it leads", nil, narrateMap},
+		{"quests", "quests", "shows the quests you are on and what is left to do", nil, narrateQuests},
+		{"inventory", "inventory", "lists what you carry and wear", nil, narrateInventory},
+		{"equip", "equip <item>", "wears an item, putting away what was in its slot", nil, (*game).equip},
+		{"unequip", "unequip <item or slot>", "puts an item away", nil, (*game).unequip},
+		{"use", "use <item>", "uses up an item, such as food", nil, (*game).use},
+		{"shop", "shop", "lists what the vendor here sells, and what they would pay for your items", atVendor, narrateShop},
+		{"buy", "buy <item>", "buys an item from the vendor here", atVendor, (*game).buy},
+		{"sell", "sell <item>", "sells an item you carry to the vendor here", atVendor, (*game).sell},
+		{"upgrade", upgradeUsage, fmt.Sprintf("spends %d gold for every %d points of attack, defense or regen", upgradeCost, upgradePoints),
+			inUpgradeRoom, (*game).upgradeStats},
+	}
+}
+
+// inUpgradeRoom only lets players use a command in the world's upgrade room.
+func inUpgradeRoom(g *game, u *user) *narratorError {
+	switch {
+	case g.world.UpgradeRoom == 0:
+		return &narratorError{cross.Other, "There is nowhere to upgrade in this world"}
+	case u.c.RoomNum != g.world.UpgradeRoom:
+		return &narratorError{cross.Other, "You can only do that in " + g.roomName(g.world.UpgradeRoom)}
+	}
+	return nil
+}
+
+// handleNarratorCommand runs what the player asked the narrator for. The first word of text
+// names the command, an empty message asks for help. Must hold g.mu.
+func (g *game) handleNarratorCommand(u *user, text string, conn sender) error {
+	args := strings.Fields(text)
+	name := "help"
+	if len(args) > 0 {
+		name, args = strings.ToLower(args[0]), args[1:]
+	}
+
+	var reply string
+	var err error = &narratorError{cross.Other, fmt.Sprintf("The narrator doesn't know how to %q, ask for help", name)}
+	for _, cmd := range narratorCommands {
+		if cmd.name == name {
+			reply, err = cmd.use(g, u, args)
+			break
+		}
+	}
+
+	var ne *narratorError
+	if errors.As(err, &ne) {
+		return conn.SendError(ne.code, ne.text)
+	}
+	if err != nil || reply == "" {
+		return err
+	}
+	return conn.Send(narration(u.c.Name, reply))
+}
+
+func narration(recipient, text string) *lurk.Message {
+	return &lurk.Message{
+		Type:      lurk.TypeMessage,
+		Recipient: recipient,
+		Sender:    narrator,
+		Text:      text,
+		Narration: true,
+	}
+}
+
+func (cmd *narratorCommand) use(g *game, u *user, args []string) (string, error) {
+	if cmd.gate != nil {
+		if err := cmd.gate(g, u); err != nil {
+			return "", err
+		}
+	}
+	return cmd.run(g, u, args)
+}
+
+func narrateHelp(g *game, u *user, _ []string) (string, error) {
+	lines := []string{fmt.Sprintf("Message %s with one of these:", narrator)}
+	for _, cmd := range narratorCommands {
+		line := fmt.Sprintf("  %s - %s", cmd.usage, cmd.help)
+		if cmd.gate != nil {
+			if err := cmd.gate(g, u); err != nil {
+				line += " (" + err.text + ")"
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// narrateWho doesn't name rooms the player hasn't unlocked, like narrateMap.
+func narrateWho(g *game, u *user, _ []string) (string, error) {
+	names := make([]string, 0, len(g.users))
+	for name := range g.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{fmt.Sprintf("%d playing:", len(names))}
+	for _, name := range names {
+		where := "somewhere unknown"
+		if room := g.users[name].c.RoomNum; u.allowedRoom[room] {
+			where = g.roomName(room)
+		}
+		lines = append(lines, fmt.Sprintf("  %s in %s", name, where))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func narrateWhere(g *game, u *user, _ []string) (string, error) {
+	room, ok := g.rooms[u.c.RoomNum]
+	if !ok {
+		return "You are nowhere at all.", nil
+	}
+	lines := []string{fmt.Sprintf("You are in %s (%d). %s", room.r.RoomName, room.r.RoomNumber, room.r.RoomDesc), "Exits:"}
+	for _, c := range room.connections {
+		if u.allowedRoom[c.RoomNumber] {
+			lines = append(lines, fmt.Sprintf("  %d %s", c.RoomNumber, c.RoomName))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func narrateStats(_ *game, u *user, _ []string) (string, error) {
+	bonus := u.bonus()
+	stat := func(name string, value uint16) string {
+		if bonus[name] == 0 {
+			return fmt.Sprint(value)
+		}
+		return fmt.Sprintf("%d (+%d from equipment)", value, bonus[name])
+	}
+	return fmt.Sprintf("Attack: %s\nDefense: %s\nRegen: %s\nHealth: %d\nGold: %d",
+		stat("attack", u.c.Attack), stat("defense", u.c.Defense), stat("regen", u.c.Regen), u.c.Health, u.c.Gold), nil
+}
+
+// narrateMap only shows the rooms the player has unlocked.
+func narrateMap(g *game, u *user, _ []string) (string, error) {
+	lines := []string{"Rooms you know of, and where they lead:"}
+	for _, def := range g.world.Rooms {
+		if !u.allowedRoom[def.Number] {
+			continue
+		}
+		exits := []string{}
+		for _, number := range def.Connections {
+			if u.allowedRoom[number] {
+				exits = append(exits, fmt.Sprint(number))
+			}
+		}
+		line := fmt.Sprintf("  %d %s -> %s", def.Number, def.Name, strings.Join(exits, ", "))
+		if def.Number == u.c.RoomNum {
+			line += " (you are here)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (g *game) roomName(number uint16) string {
+	if room, ok := g.rooms[number]; ok {
+		return room.r.RoomName
+	}
+	return "nowhere"
+}
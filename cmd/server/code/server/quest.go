@@ -0,0 +1,356 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// progress is how far one player is through the world's quests. It is kept by character name,
+// so it outlasts the connection and, with a quest file, the server.
+type progress struct {
+	Done map[string]bool `json:"done"`
+	// Met is, by quest name, which objectives of an unfinished quest are met.
+	Met map[string][]bool `json:"met,omitempty"`
+}
+
+// progressOf is the user's progress. Players who haven't met an objective yet get an empty one
+// that isn't kept, so only players who made progress end up in the quest file. Must hold g.mu.
+func (g *game) progressOf(u *user) *progress {
+	if p, ok := g.progress[u.c.Name]; ok {
+		return p
+	}
+	return &progress{}
+}
+
+// started is true once every quest q requires is done.
+func (p *progress) started(q *QuestDef) bool {
+	for _, name := range q.Requires {
+		if !p.Done[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *progress) met(q *QuestDef, i int) bool {
+	return i < len(p.Met[q.Name]) && p.Met[q.Name][i]
+}
+
+// pending calls fn with every objective the user still has to meet in quests they have started.
+func (g *game) pending(p *progress, fn func(q *QuestDef, i int, o *Objective)) {
+	for _, q := range g.world.Quests {
+		if p.Done[q.Name] || !p.started(q) {
+			continue
+		}
+		for i, o := range q.Objectives {
+			if !p.met(q, i) {
+				fn(q, i, o)
+			}
+		}
+	}
+}
+
+// advance marks the pending objectives that done says are met and rewards every quest that
+// is finished by it. Finishing a quest can start others, which are checked too. Must hold g.mu.
+func (g *game) advance(u *user, done func(o *Objective) bool) error {
+	p := g.progressOf(u)
+	// Either can be missing, for a new player or from a quest file.
+	if p.Done == nil {
+		p.Done = map[string]bool{}
+	}
+	if p.Met == nil {
+		p.Met = map[string][]bool{}
+	}
+	var finished []*QuestDef
+	progressed := false
+	for changed := true; changed; {
+		changed = false
+		g.pending(p, func(q *QuestDef, i int, o *Objective) {
+			if !done(o) {
+				return
+			}
+			met := p.Met[q.Name]
+			if len(met) < len(q.Objectives) {
+				met = append(met, make([]bool, len(q.Objectives)-len(met))...)
+			}
+			met[i], changed = true, true
+			p.Met[q.Name] = met
+		})
+		for _, q := range g.world.Quests {
+			if !p.Done[q.Name] && p.started(q) && allMet(p, q) {
+				p.Done[q.Name] = true
+				delete(p.Met, q.Name)
+				finished, changed = append(finished, q), true
+			}
+		}
+		progressed = progressed || changed
+	}
+	if !progressed {
+		return nil
+	}
+	g.progress[u.c.Name] = p
+	g.saveProgress()
+	if len(finished) == 0 {
+		return nil
+	}
+	return g.reward(u, finished)
+}
+
+func allMet(p *progress, q *QuestDef) bool {
+	for i := range q.Objectives {
+		if !p.met(q, i) {
+			return false
+		}
+	}
+	return true
+}
+
+// reward gives the user what the finished quests promise and tells them about it.
+func (g *game) reward(u *user, finished []*QuestDef) error {
+	unlocked, changed := false, false
+	for _, q := range finished {
+		for _, number := range q.Reward.Rooms {
+			unlocked = unlocked || !u.allowedRoom[number]
+			u.allowedRoom[number] = true
+		}
+		if q.Reward.Gold != 0 {
+			u.c.Gold = uint16(min(int(u.c.Gold)+int(q.Reward.Gold), math.MaxUint16))
+			changed = true
+		}
+		for _, item := range q.Reward.Items {
+			u.give(item, 1)
+			changed = true
+		}
+		text := q.Reward.Narration
+		if text == "" {
+			text = fmt.Sprintf("You finished %s.", q.Name)
+		}
+		if err := u.conn.Send(narration(u.c.Name, text)); err != nil {
+			return err
+		}
+	}
+	if changed {
+		if err := g.showCharacter(u); err != nil {
+			return err
+		}
+	}
+	if unlocked {
+		return g.sendConnections(g.rooms[u.c.RoomNum], u.c.Name, u.conn)
+	}
+	return nil
+}
+
+// unlockRooms lets a returning player back into the rooms their finished quests unlocked.
+func (g *game) unlockRooms(u *user) {
+	p := g.progressOf(u)
+	for _, q := range g.world.Quests {
+		if p.Done[q.Name] {
+			for _, number := range q.Reward.Rooms {
+				u.allowedRoom[number] = true
+			}
+		}
+	}
+}
+
+// Objectives that are met by something happening, rather than by the player's state.
+func killed(name string) func(*Objective) bool {
+	return func(o *Objective) bool { return o.Kill == name }
+}
+
+func reached(room uint16) func(*Objective) bool {
+	return func(o *Objective) bool { return o.Reach == room }
+}
+
+// npcIn finds a monster or vendor in the room by name, ignoring case. Must hold g.mu.
+func (g *game) npcIn(room uint16, name string) *lurk.Character {
+	if v, ok := g.vendors[room]; ok && strings.EqualFold(v.c.Name, name) {
+		return v.c
+	}
+	for _, m := range g.monsters {
+		if m.RoomNum == room && strings.EqualFold(m.Name, name) {
+			return m
+		}
+	}
+	return nil
+}
+
+// talk answers a message to a monster or vendor. They say their part of a quest the player is
+// on, or describe themselves otherwise. Must hold g.mu.
+func (g *game) talk(u *user, npc *lurk.Character, conn sender) error {
+	spokenTo := func(o *Objective) bool { return o.Talk == npc.Name }
+	reply := npc.PlayerDesc
+	g.pending(g.progressOf(u), func(_ *QuestDef, _ int, o *Objective) {
+		if spokenTo(o) && o.Say != "" {
+			reply = o.Say
+		}
+	})
+	if err := conn.Send(&lurk.Message{
+		Type:      lurk.TypeMessage,
+		Recipient: u.c.Name,
+		Sender:    npc.Name,
+		Text:      reply,
+	}); err != nil {
+		return err
+	}
+	if err := g.advance(u, spokenTo); err != nil {
+		return err
+	}
+	return conn.SendAccept(lurk.TypeMessage)
+}
+
+func narrateQuests(g *game, u *user, _ []string) (string, error) {
+	p := g.progressOf(u)
+	var lines, done []string
+	for _, q := range g.world.Quests {
+		switch {
+		case p.Done[q.Name]:
+			done = append(done, q.Name)
+		case p.started(q):
+			lines = append(lines, fmt.Sprintf("%s - %s", q.Name, q.Description))
+			for i, o := range q.Objectives {
+				mark := " "
+				if p.met(q, i) {
+					mark = "x"
+				}
+				lines = append(lines, fmt.Sprintf("  [%s] %s", mark, g.describeObjective(o)))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "You have no quests right now.")
+	}
+	if len(done) != 0 {
+		lines = append(lines, "Done: "+strings.Join(done, ", "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (g *game) describeObjective(o *Objective) string {
+	switch {
+	case o.Kill != "":
+		return "Defeat " + o.Kill
+	case o.Reach != 0:
+		return "Reach " + g.roomName(o.Reach)
+	case o.Gold != 0:
+		return fmt.Sprintf("Hold %d gold", o.Gold)
+	}
+	return fmt.Sprintf("Talk to %s (message them in %s)", o.Talk, g.roomName(g.npcRoom(o.Talk)))
+}
+
+// isNPCName is true when a monster or vendor goes by name, ignoring case like npcIn. Players
+// can't take these names, messages to them would go to the NPC.
+func (g *game) isNPCName(name string) bool {
+	for _, m := range g.monsters {
+		if strings.EqualFold(m.Name, name) {
+			return true
+		}
+	}
+	for _, v := range g.vendors {
+		if strings.EqualFold(v.c.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *game) npcRoom(name string) uint16 {
+	if m, ok := g.monsters[name]; ok {
+		return m.RoomNum
+	}
+	for room, v := range g.vendors {
+		if v.c.Name == name {
+			return room
+		}
+	}
+	return 0
+}
+
+// loadProgress reads the quest file, if there is one yet, and saves to it from then on until
+// closeProgress.
+func (g *game) loadProgress(filename string) error {
+	ba, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(ba, &g.progress); err != nil {
+			return err
+		}
+		if g.progress == nil {
+			g.progress = make(map[string]*progress)
+		}
+	}
+	g.saves = make(chan []byte, 1)
+	g.saved = make(chan struct{})
+	go g.writeProgress(filename, g.saves)
+	return nil
+}
+
+// saveProgress hands a snapshot of every player's progress to writeProgress, if there is a
+// quest file. A snapshot still waiting to be written is replaced, it is out of date. Must hold
+// g.mu.
+func (g *game) saveProgress() {
+	if g.saves == nil {
+		return
+	}
+	ba, err := json.MarshalIndent(g.progress, "", "\t")
+	if err != nil {
+		g.log.Printf("%v: could not save quest progress", err.Error())
+		return
+	}
+	// Only one sender at a time holds g.mu, so there is room once the old snapshot is gone.
+	select {
+	case <-g.saves:
+	default:
+	}
+	g.saves <- ba
+}
+
+// writeProgress writes snapshots to the quest file away from g.mu, so players don't wait on
+// the disk. The file is replaced whole so a crash can't leave half of it.
+func (g *game) writeProgress(filename string, saves <-chan []byte) {
+	defer close(g.saved)
+	for ba := range saves {
+		if err := writeFileSynced(filename, ba); err != nil {
+			g.log.Printf("%v: could not save quest progress", err.Error())
+		}
+	}
+}
+
+func writeFileSynced(filename string, ba []byte) error {
+	tmp := filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(ba)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// closeProgress stops saving and waits for the last snapshot to be written.
+func (g *game) closeProgress() {
+	g.mu.Lock()
+	saves := g.saves
+	g.saves = nil
+	g.mu.Unlock()
+	if saves == nil {
+		return
+	}
+	close(saves)
+	<-g.saved
+}
@@ -1,37 +1,50 @@
 package server
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
 	"net"
+	"sync"
+	"time"
 
-	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
 )
 
 type receiver struct {
-	listener *net.TCPListener
-	// queue for messages
-	shouldRun bool
+	listener net.Listener
 	*game
+
+	statsMu sync.Mutex
+	// open holds the connections being served, closed is the traffic of the ones that are gone.
+	open        map[*lurk.Conn]struct{}
+	closed      lurk.ConnStats
+	connections uint64
+	// stopped turns new connections away. serving lets stop wait for the open ones to finish.
+	stopped bool
+	serving sync.WaitGroup
 }
 
-func newReceiver(cfg *Config, game *game) (*receiver, error) {
+func newReceiver(cfg *Config, game *game, tlsCfg *tls.Config) (*receiver, error) {
 	address := fmt.Sprintf("0.0.0.0:%v", cfg.Port)
 
 	// Won't fail with the preset localhost and "tcp".
 	tcpAddr, _ := net.ResolveTCPAddr("tcp", address)
 
-	l, err := net.ListenTCP("tcp", tcpAddr)
+	tcpListener, err := net.ListenTCP("tcp", tcpAddr)
 	if err != nil {
-		log.Printf("Could not listen on port %v", cfg.Port)
+		game.log.Printf("Could not listen on port %v", cfg.Port)
 		return nil, err
 	}
+	var l net.Listener = tcpListener
+	if tlsCfg != nil {
+		l = tls.NewListener(l, tlsCfg)
+	}
 
 	return &receiver{
-		listener:  l,
-		shouldRun: true,
-		game:      game,
+		listener: l,
+		game:     game,
+		open:     make(map[*lurk.Conn]struct{}),
 	}, nil
 }
 
@@ -40,44 +53,128 @@ func (rec *receiver) start() {
 }
 
 func (rec *receiver) run() {
-	defer cross.LogOnErr(rec.listener.Close)
-	for rec.shouldRun {
+	for {
 		conn, err := rec.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
-			log.Printf("%v: error accepting connection", err.Error())
+			rec.log.Printf("%v: error accepting connection", err.Error())
 			continue
 		}
 		go rec.registerUser(conn)
 	}
 }
 
+// A client that stops reading for this long is disconnected, rather than filling up its
+// events.
+const defaultWriteTimeout = time.Second
+
 // The 'conn' object will simply get passed through to different functions.
-func (rec *receiver) registerUser(conn net.Conn) {
-	defer cross.LogOnErr(conn.Close)
+func (rec *receiver) registerUser(netConn net.Conn) {
+	conn := lurk.NewConn(netConn)
+	conn.SetWriteTimeout(defaultWriteTimeout)
+	defer rec.closeConn(conn)
+	if !rec.track(conn) {
+		return
+	}
+	defer rec.untrack(conn)
+
+	if err := handshake(netConn); err != nil {
+		rec.log.Printf("%v: TLS handshake failed with %v", err.Error(), netConn.RemoteAddr())
+		return
+	}
 
 	if err := rec.sendStart(conn); err != nil {
-		log.Printf("%v: error starting the game", err.Error())
+		rec.log.Printf("%v: error starting the game", err.Error())
 		return
 	}
 
 	player, err := rec.registerPlayer(conn)
 	defer rec.cleanup(player)
 	if err != nil {
-		log.Printf("%v: error registering player", err.Error())
+		rec.log.Printf("%v: error registering player", err.Error())
 		return
 	}
 
 	if err := rec.startGameplay(player, conn); err != nil && !errors.Is(err, errDisconnect) {
-		log.Printf("%v: error during gameplay", err.Error())
+		rec.log.Printf("%v: error during gameplay", err.Error())
 		return
 	}
-	log.Printf("%v left.", player)
+	rec.log.Printf("%v left.", player)
 }
 
-func (rec *receiver) stop() {
+// track is false once the receiver has stopped, the connection is refused then.
+func (rec *receiver) track(conn *lurk.Conn) bool {
+	rec.statsMu.Lock()
+	defer rec.statsMu.Unlock()
+	if rec.stopped {
+		return false
+	}
+	rec.connections++
+	rec.open[conn] = struct{}{}
+	rec.serving.Add(1)
+	return true
+}
+
+func (rec *receiver) untrack(conn *lurk.Conn) {
+	rec.statsMu.Lock()
+	defer rec.statsMu.Unlock()
+	delete(rec.open, conn)
+	rec.closed = addStats(rec.closed, conn.Stats())
+	rec.serving.Done()
+}
+
+// closeConn closes a connection that stop may have closed already.
+func (rec *receiver) closeConn(conn *lurk.Conn) {
+	if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		rec.log.Printf("%v: error closing connection", err.Error())
+	}
+}
+
+// metrics reports the traffic of every connection this receiver has served.
+func (rec *receiver) metrics() Metrics {
+	rec.statsMu.Lock()
+	total := rec.closed
+	for conn := range rec.open {
+		total = addStats(total, conn.Stats())
+	}
+	m := Metrics{
+		Connections:     rec.connections,
+		OpenConnections: len(rec.open),
+		Traffic:         total,
+	}
+	rec.statsMu.Unlock()
+
 	rec.mu.Lock()
-	defer rec.mu.Unlock()
-	rec.shouldRun = false
+	m.Players = len(rec.users)
+	rec.mu.Unlock()
+	return m
+}
+
+func addStats(a, b lurk.ConnStats) lurk.ConnStats {
+	return lurk.ConnStats{
+		BytesSent:        a.BytesSent + b.BytesSent,
+		BytesReceived:    a.BytesReceived + b.BytesReceived,
+		MessagesSent:     a.MessagesSent + b.MessagesSent,
+		MessagesReceived: a.MessagesReceived + b.MessagesReceived,
+	}
+}
+
+// stop closes the listener and every open connection, then waits for the players to be
+// cleaned up as if they had disconnected.
+func (rec *receiver) stop() {
+	// Unblocks Accept, so run returns right away.
+	if err := rec.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		rec.log.Printf("%v: error closing listener", err.Error())
+	}
+	rec.statsMu.Lock()
+	rec.stopped = true
+	for conn := range rec.open {
+		rec.closeConn(conn)
+	}
+	rec.statsMu.Unlock()
+	rec.serving.Wait()
 }
 
 func (rec *receiver) cleanup(player string) {
@@ -87,16 +184,6 @@ func (rec *receiver) cleanup(player string) {
 	if !ok || user.terminated {
 		return
 	}
-	oldRoom := user.c.RoomNum
-	user.c.RoomNum = 0
-	for _, u := range rec.users {
-		if u.c.RoomNum != oldRoom || u.c.Name == user.c.Name {
-			continue
-		}
-		if err := rec.sendCharacterUpdate(user.c, u.conn, u.c.Name, fmt.Sprintf("%s left the server!", player)); err != nil {
-			log.Printf("%v: error when updating others of leaving the server", err.Error())
-		}
-	}
-
+	rec.announceLeaving(user)
 	delete(rec.users, player)
 }
@@ -1,23 +1,262 @@
 package server
 
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
 type Config struct {
+	// Name tells instances apart in logs and metrics. It defaults to the port.
+	Name string `json:"Name,omitempty"`
 	Port uint16 `json:"ServerPort"`
+	// WebSocketPort serves the JSON WebSocket gateway for browsers. Zero turns it off.
+	WebSocketPort uint16 `json:"WebSocketPort,omitempty"`
+	// TLS is optional, the server listens for plain TCP when it is nil. It applies to the
+	// WebSocket gateway as well.
+	TLS *TLSConfig `json:"TLS,omitempty"`
+	// WorldFile is a JSON world definition. The built in Ender's Game world is used when empty.
+	WorldFile string `json:"WorldFile,omitempty"`
+	// LogFile receives this instance's logs instead of the standard logger's output.
+	LogFile string `json:"LogFile,omitempty"`
+	// AuditFile receives a JSON line for every direct message and every message sent under
+	// someone else's name, for moderation. Empty turns it off.
+	AuditFile string `json:"AuditFile,omitempty"`
+	// QuestFile keeps each player's quest progress, by character name, across restarts. Empty
+	// only keeps it until the server stops.
+	QuestFile string `json:"QuestFile,omitempty"`
+}
+
+// MultiConfig runs several independent games from one process.
+type MultiConfig struct {
+	Instances []*Config `json:"Instances"`
+	// MetricsPort serves GET /metrics with the Metrics of every instance. Zero turns it off.
+	MetricsPort uint16 `json:"MetricsPort,omitempty"`
 }
 
+// Metrics is a snapshot of one instance.
+type Metrics struct {
+	// Connections is every connection accepted since the instance started.
+	Connections     uint64         `json:"connections"`
+	OpenConnections int            `json:"openConnections"`
+	Players         int            `json:"players"`
+	Traffic         lurk.ConnStats `json:"traffic"`
+}
+
+var (
+	errNoInstances   = errors.New("no server instances configured")
+	errDuplicateName = errors.New("instance name used more than once")
+	errDuplicatePort = errors.New("port used more than once")
+)
+
+const metricsPath = "/metrics"
+
 // New will create a new server instance that starts all necessary processes
 // for the server. The function will return a list of functions that should be called
 // for the termination of the server
 func New(cfg *Config) ([]func(), error) {
-	game := newGame()
+	return NewMulti(&MultiConfig{Instances: []*Config{cfg}})
+}
 
-	rec, err := newReceiver(cfg, game)
-	if err != nil {
+// NewMulti starts every configured instance, each with its own world, state and logs. If
+// any instance fails to start, the ones already running are stopped. The returned functions
+// shut everything down.
+func NewMulti(cfg *MultiConfig) ([]func(), error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	var instances []*instance
+	stopAll := func() {
+		for _, inst := range instances {
+			inst.stop()
+		}
+	}
+	for _, instCfg := range cfg.Instances {
+		inst, err := newInstance(instCfg)
+		if err != nil {
+			stopAll()
+			return nil, err
+		}
+		instances = append(instances, inst)
+	}
+
+	cancelFunctions := []func(){stopAll}
+	if cfg.MetricsPort != 0 {
+		stopMetrics, err := serveMetrics(cfg.MetricsPort, instances)
+		if err != nil {
+			stopAll()
+			return nil, err
+		}
+		cancelFunctions = append(cancelFunctions, stopMetrics)
+	}
+	return cancelFunctions, nil
+}
+
+func (cfg *MultiConfig) validate() error {
+	if len(cfg.Instances) == 0 {
+		return errNoInstances
+	}
+	names := map[string]bool{}
+	ports := map[uint16]bool{}
+	for _, inst := range cfg.Instances {
+		if names[inst.name()] {
+			return fmt.Errorf("%w: %s", errDuplicateName, inst.name())
+		}
+		names[inst.name()] = true
+		for _, port := range []uint16{inst.Port, inst.WebSocketPort} {
+			// Zero picks a free port, so it can't clash.
+			if port == 0 {
+				continue
+			}
+			if ports[port] || port == cfg.MetricsPort {
+				return fmt.Errorf("%w: %d", errDuplicatePort, port)
+			}
+			ports[port] = true
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) name() string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	return fmt.Sprint(cfg.Port)
+}
+
+// instance is one game with its listeners.
+type instance struct {
+	name      string
+	log       *log.Logger
+	logFile   io.Closer
+	auditFile io.Closer
+	game      *game
+	rec       *receiver
+	gw        *gateway
+}
+
+func newInstance(cfg *Config) (*instance, error) {
+	inst := &instance{name: cfg.name()}
+	if err := inst.start(cfg); err != nil {
+		inst.stop()
 		return nil, err
 	}
+	return inst, nil
+}
+
+func (inst *instance) start(cfg *Config) error {
+	out := log.Writer()
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		out, inst.logFile = f, f
+	}
+	inst.log = log.New(out, "["+inst.name+"] ", log.LstdFlags|log.Lmsgprefix)
+
+	world := defaultWorld()
+	if cfg.WorldFile != "" {
+		var err error
+		if world, err = LoadWorld(cfg.WorldFile); err != nil {
+			inst.log.Printf("Could not load world %s", cfg.WorldFile)
+			return err
+		}
+	}
+	game := newGame(world, inst.log)
+	inst.game = game
+	if cfg.AuditFile != "" {
+		f, err := os.OpenFile(cfg.AuditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			inst.log.Printf("Could not open the audit file %s", cfg.AuditFile)
+			return err
+		}
+		inst.auditFile = f
+		game.openAudit(f)
+	}
+	if cfg.QuestFile != "" {
+		if err := game.loadProgress(cfg.QuestFile); err != nil {
+			inst.log.Printf("Could not load quest progress from %s", cfg.QuestFile)
+			return err
+		}
+	}
+
+	var tlsCfg *tls.Config
+	if cfg.TLS != nil {
+		var err error
+		if tlsCfg, err = cfg.TLS.load(); err != nil {
+			inst.log.Printf("Could not load the TLS certificate")
+			return err
+		}
+	}
+
+	var err error
+	if inst.rec, err = newReceiver(cfg, game, tlsCfg); err != nil {
+		return err
+	}
+	if cfg.WebSocketPort != 0 {
+		if inst.gw, err = newGateway(cfg, inst.rec, tlsCfg); err != nil {
+			return err
+		}
+		inst.gw.start()
+	}
+	inst.rec.start()
+
+	inst.log.Printf("Serving %s on port %v", world.Name, cfg.Port)
+	return nil
+}
 
-	rec.start()
+func (inst *instance) stop() {
+	if inst.rec != nil {
+		inst.rec.stop()
+	}
+	if inst.gw != nil {
+		inst.gw.stop()
+	}
+	if inst.game != nil {
+		inst.game.closeProgress()
+		inst.game.closeAudit()
+	}
+	if inst.auditFile != nil {
+		cross.LogOnErr(inst.auditFile.Close)
+	}
+	if inst.logFile != nil {
+		cross.LogOnErr(inst.logFile.Close)
+	}
+}
+
+func serveMetrics(port uint16, instances []*instance) (func(), error) {
+	l, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%v", port))
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+metricsPath, func(w http.ResponseWriter, _ *http.Request) {
+		metrics := make(map[string]Metrics, len(instances))
+		for _, inst := range instances {
+			metrics[inst.name] = inst.rec.metrics()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(metrics); err != nil {
+			log.Printf("%v: could not write metrics", err)
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: handshakeTimeout}
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%v: metrics server stopped", err)
+		}
+	}()
 
-	return []func(){
-		rec.stop,
-	}, nil
+	return func() { cross.LogOnErr(srv.Close) }, nil
 }
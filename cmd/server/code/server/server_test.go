@@ -2,10 +2,16 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -335,6 +341,39 @@ func TestServerStartupErrors(t *testing.T) {
 			cf()
 		}
 	})
+	t.Run("TestTLSWithoutCertificate", func(_ *testing.T) {
+		_, err := New(&Config{
+			Port: cross.GetFreePort(),
+			TLS:  &TLSConfig{},
+		})
+		a.ErrorIs(errNoCertificate, err)
+
+		_, err = New(&Config{
+			Port: cross.GetFreePort(),
+			TLS:  &TLSConfig{CertFile: "missing.pem", KeyFile: "missing.pem"},
+		})
+		a.Error(err)
+	})
+	t.Run("TestSelfSigned", func(_ *testing.T) {
+		cfg := &Config{
+			Port: cross.GetFreePort(),
+			TLS:  &TLSConfig{SelfSigned: true},
+		}
+		cfs, err := New(cfg)
+		a.NoError(err)
+		defer func() {
+			for _, cf := range cfs {
+				cf()
+			}
+		}()
+
+		//nolint:gosec // The certificate is self signed.
+		conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%v", cfg.Port), &tls.Config{InsecureSkipVerify: true})
+		a.NoError(err)
+		defer cross.LogOnErr(conn.Close)
+
+		a.True(readUntil(a, lurk.TypeVersion, conn) != nil)
+	})
 }
 
 func sendLeave(conn net.Conn, a *assert.Assert) {
@@ -425,3 +464,110 @@ func startClientConnection(a *assert.Assert, cfg *Config, char *lurk.Character)
 
 	return conn
 }
+
+func TestMultiServer(t *testing.T) {
+	a := assert.New(t)
+
+	log.SetOutput(&buf)
+	dir := t.TempDir()
+	worldFile := filepath.Join(dir, "tiny.json")
+	a.NoError(os.WriteFile(worldFile, []byte(tinyWorld), 0o600))
+	logFile := filepath.Join(dir, "tiny.log")
+
+	enders := &Config{Name: "enders", Port: cross.GetFreePort()}
+	tiny := &Config{Name: "tiny", Port: cross.GetFreePort(), WorldFile: worldFile, LogFile: logFile}
+	metricsPort := cross.GetFreePort()
+	cfs, err := NewMulti(&MultiConfig{
+		Instances:   []*Config{enders, tiny},
+		MetricsPort: metricsPort,
+	})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+
+	t.Run("TestSeparateWorlds", func(_ *testing.T) {
+		conn := startClientConnection(a, enders, &lurk.Character{Name: "Multi", Attack: 50, Defense: 25, Regen: 25})
+		defer cross.LogOnErr(conn.Close)
+		conn2 := startClientConnection(a, tiny, &lurk.Character{Name: "Multi", Attack: 50, Defense: 25, Regen: 25})
+		defer cross.LogOnErr(conn2.Close)
+
+		room, ok := readUntil(a, lurk.TypeRoom, conn2).(*lurk.Room)
+		a.True(ok)
+		a.True(room.RoomName == "Cellar")
+
+		a.Eventually(func() bool {
+			ba, err := os.ReadFile(logFile)
+			return err == nil && strings.Contains(string(ba), "[tiny] Added user Multi")
+		}, time.Second, 5*time.Millisecond)
+		a.True(strings.Contains(buf.String(), "[enders] Added user Multi"))
+	})
+	t.Run("TestMetrics", func(_ *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%v%v", metricsPort, metricsPath))
+		a.NoError(err)
+		defer func() { _ = resp.Body.Close() }()
+
+		metrics := map[string]Metrics{}
+		a.NoError(json.NewDecoder(resp.Body).Decode(&metrics))
+		a.True(len(metrics) == 2)
+		a.True(metrics["tiny"].Connections >= 1)
+		a.True(metrics["tiny"].Traffic.MessagesReceived != 0)
+		a.True(metrics["enders"].Traffic.BytesSent != 0)
+	})
+	t.Run("TestBadConfigs", func(_ *testing.T) {
+		_, err := NewMulti(&MultiConfig{})
+		a.True(errors.Is(err, errNoInstances))
+
+		port := cross.GetFreePort()
+		_, err = NewMulti(&MultiConfig{Instances: []*Config{{Name: "a", Port: port}, {Name: "b", Port: port}}})
+		a.True(errors.Is(err, errDuplicatePort))
+
+		_, err = NewMulti(&MultiConfig{Instances: []*Config{{Name: "a", Port: port}, {Name: "a"}}})
+		a.True(errors.Is(err, errDuplicateName))
+
+		// The first instance starts, the second fails and takes the first down with it.
+		_, err = NewMulti(&MultiConfig{Instances: []*Config{
+			{Name: "a", Port: port},
+			{Name: "b", Port: cross.GetFreePort(), WorldFile: filepath.Join(dir, "missing.json")},
+		}})
+		a.Error(err)
+		cfs, err := New(&Config{Port: port})
+		a.NoError(err)
+		for _, cf := range cfs {
+			cf()
+		}
+	})
+	t.Run("TestStopDisconnectsPlayers", func(_ *testing.T) {
+		cfg := &Config{Port: cross.GetFreePort()}
+		cfs, err := New(cfg)
+		a.NoError(err)
+		conn := startClientConnection(a, cfg, &lurk.Character{Name: "Lingerer", Attack: 50, Defense: 25, Regen: 25})
+		defer cross.LogOnErr(conn.Close)
+
+		for _, cf := range cfs {
+			cf()
+		}
+		// Everything still buffered is read, then the server's close shows up.
+		a.NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+		_, err = io.Copy(io.Discard, conn)
+		a.NoError(err)
+	})
+}
+
+const tinyWorld = `{
+	"name": "Tiny",
+	"description": "A very small world.",
+	"initialPoints": 100,
+	"statLimit": 65535,
+	"startRoom": 1,
+	"rooms": [
+		{"number": 1, "name": "Cellar", "description": "Damp.", "connections": [2]},
+		{"number": 2, "name": "Attic", "description": "Dusty.", "connections": [1]}
+	],
+	"monsters": [
+		{"name": "Rat", "description": "A rat.", "attack": 1, "defense": 1, "regen": 1, "health": 10,
+		 "goldReward": 1, "room": 2, "monster": true}
+	]
+}`
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// vendor is a shopkeeper and what they have on hand.
+type vendor struct {
+	def *VendorDef
+	c   *lurk.Character
+	// stock counts what the vendor has by item name, full is what a restock brings it back to.
+	stock     map[string]int
+	full      map[string]int
+	restocked time.Time
+}
+
+func newVendor(def *VendorDef) *vendor {
+	v := &vendor{
+		def: def,
+		c: &lurk.Character{
+			Type:       lurk.TypeCharacter,
+			Name:       def.Name,
+			Flags:      map[string]bool{lurk.Alive: true},
+			Health:     initialHealth,
+			RoomNum:    def.Room,
+			PlayerDesc: def.Description,
+		},
+		stock:     make(map[string]int),
+		full:      make(map[string]int),
+		restocked: time.Now(),
+	}
+	for _, s := range def.Stock {
+		v.full[s.Item] += s.Quantity
+		v.stock[s.Item] += s.Quantity
+	}
+	return v
+}
+
+// restock refills anything that has run low once the restock time has passed. What players
+// sold beyond the usual stock is kept.
+func (v *vendor) restock(now time.Time) {
+	period := time.Duration(v.def.RestockSeconds) * time.Second
+	if period == 0 || now.Sub(v.restocked) < period {
+		return
+	}
+	for name, n := range v.full {
+		v.stock[name] = max(v.stock[name], n)
+	}
+	v.restocked = now
+}
+
+// buyPrice is what a player pays. It climbs to double the item's price as the stock runs out,
+// so one player can't buy everything cheaply.
+func (v *vendor) buyPrice(item *ItemDef) int {
+	price, full, left := int(item.Price), v.full[item.Name], v.stock[item.Name]
+	if full == 0 || left >= full {
+		return price
+	}
+	return price + price*(full-left)/full
+}
+
+// sellPrice is what the vendor pays, half the item's price and less again for every one held
+// beyond the usual stock. Selling many of a drop floods the market instead of printing gold.
+func (v *vendor) sellPrice(item *ItemDef) int {
+	if item.Price == 0 {
+		return 0
+	}
+	full := max(v.full[item.Name], 1)
+	surplus := max(v.stock[item.Name]-v.full[item.Name], 0)
+	return max(int(item.Price)*full/(2*(full+surplus)), 1)
+}
+
+// atVendor only lets players use a command in a room with a vendor.
+func atVendor(g *game, u *user) *narratorError {
+	if _, ok := g.vendors[u.c.RoomNum]; ok {
+		return nil
+	}
+	return &narratorError{cross.Other, "There is nobody to trade with here"}
+}
+
+// vendorHere is the vendor in the user's room, restocked if it is time.
+func (g *game) vendorHere(u *user) *vendor {
+	v := g.vendors[u.c.RoomNum]
+	v.restock(time.Now())
+	return v
+}
+
+func narrateShop(g *game, u *user, _ []string) (string, error) {
+	v := g.vendorHere(u)
+	lines := []string{v.def.Name + " sells:"}
+	for _, name := range sortedKeys(v.stock) {
+		if v.stock[name] > 0 {
+			lines = append(lines, fmt.Sprintf("  %s - %d gold, %d left - %s",
+				name, v.buyPrice(g.items[name]), v.stock[name], describeItem(g.items[name])))
+		}
+	}
+	if len(lines) == 1 {
+		lines = append(lines, "  nothing right now")
+	}
+	offers := []string{}
+	for _, name := range sortedKeys(u.items) {
+		if item := g.items[name]; item != nil && item.Price != 0 {
+			offers = append(offers, fmt.Sprintf("  %s - %d gold", name, v.sellPrice(item)))
+		}
+	}
+	if len(offers) != 0 {
+		lines = append(lines, "and would buy your:")
+		lines = append(lines, offers...)
+	}
+	lines = append(lines, fmt.Sprintf("You have %d gold.", u.c.Gold))
+	return strings.Join(lines, "\n"), nil
+}
+
+func (g *game) buy(u *user, args []string) (string, error) {
+	v := g.vendorHere(u)
+	var names []string
+	for _, name := range sortedKeys(v.stock) {
+		if v.stock[name] > 0 {
+			names = append(names, name)
+		}
+	}
+	name, err := matchName(strings.Join(args, " "), names, v.def.Name+" has no %q to sell")
+	if err != nil {
+		return "", err
+	}
+	price := v.buyPrice(g.items[name])
+	if int(u.c.Gold) < price {
+		return "", &narratorError{cross.Other, fmt.Sprintf("%s costs %d gold and you have %d", name, price, u.c.Gold)}
+	}
+
+	v.stock[name]--
+	u.c.Gold -= uint16(price)
+	u.give(name, 1)
+	return fmt.Sprintf("%s sells you %s for %d gold. You have %d gold left.", v.def.Name, name, price, u.c.Gold),
+		g.showCharacter(u)
+}
+
+// sell only takes what the user carries, equipment has to be put away first.
+func (g *game) sell(u *user, args []string) (string, error) {
+	v := g.vendorHere(u)
+	name, err := findName(strings.Join(args, " "), sortedKeys(u.items))
+	if err != nil {
+		return "", err
+	}
+	item := g.items[name]
+	if item == nil || item.Price == 0 {
+		return "", &narratorError{cross.Other, fmt.Sprintf("%s doesn't want %s", v.def.Name, name)}
+	}
+
+	price := v.sellPrice(item)
+	u.take(name)
+	v.stock[name]++
+	u.c.Gold = uint16(min(int(u.c.Gold)+price, math.MaxUint16))
+	return fmt.Sprintf("%s buys %s for %d gold. You have %d gold.", v.def.Name, name, price, u.c.Gold),
+		g.showCharacter(u)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+// TLSConfig turns on TLS for the game port. Either give a certificate and key, or set
+// SelfSigned to generate a throwaway certificate for development.
+type TLSConfig struct {
+	CertFile string `json:"CertFile"`
+	KeyFile  string `json:"KeyFile"`
+	// SelfSigned is only used when no certificate files are given. Clients will need to skip
+	// verification to connect.
+	SelfSigned bool `json:"SelfSigned"`
+}
+
+var errNoCertificate = errors.New("TLS needs a certificate and key file or self signed mode")
+
+// A client that never finishes the handshake should not hold a goroutine forever.
+const handshakeTimeout = 5 * time.Second
+
+func (cfg *TLSConfig) load() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case cfg.CertFile != "" && cfg.KeyFile != "":
+		cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	case cfg.SelfSigned:
+		log.Println("Using a self signed certificate, do not use this in production")
+		hosts := []string{"localhost", "127.0.0.1", "::1"}
+		if name, err := os.Hostname(); err == nil {
+			hosts = append(hosts, name)
+		}
+		cert, err = cross.SelfSignedCertificate(hosts...)
+	default:
+		return nil, errNoCertificate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// handshake finishes the TLS handshake up front so a bad client is dropped before it is
+// sent anything. Plain connections are left alone.
+func handshake(conn net.Conn) error {
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	ctx, cf := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cf()
+	return tc.HandshakeContext(ctx)
+}
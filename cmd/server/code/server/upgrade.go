@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// Stat points are bought in the Barracks at the rate upgrades have always had, upgradeCost
+// gold for upgradePoints points. Fewer points cost their share, rounded up.
+const (
+	upgradeCost   = 50
+	upgradePoints = 15
+	// maxStat is the most any one stat can be raised to.
+	maxStat = 100
+
+	upgradeUsage = "upgrade <attack|defense|regen> <points> ..."
+)
+
+var statNames = []string{"attack", "defense", "regen"}
+
+// upgradeStats spends the player's gold on the points they asked for, like "attack 5 regen 2".
+// Nothing is bought unless all of it fits within the caps and the player's gold.
+func (g *game) upgradeStats(user *user, args []string) (string, error) {
+	points, err := parseUpgrade(args)
+	if err != nil {
+		return "", err
+	}
+	stats := map[string]*uint16{
+		"attack":  &user.c.Attack,
+		"defense": &user.c.Defense,
+		"regen":   &user.c.Regen,
+	}
+
+	// In int, so large stats can't wrap around. Equipment doesn't count toward the limits.
+	bonus := user.bonus()
+	total, bought := 0, 0
+	for _, name := range statNames {
+		current := int(*stats[name]) - bonus[name]
+		if points[name] > 0 && current+points[name] > maxStat {
+			return "", &narratorError{cross.StatError, fmt.Sprintf(
+				"Your %s is %d and can't go above %d", name, current, maxStat)}
+		}
+		total += current
+		bought += points[name]
+	}
+	if limit := int(g.game.StatLimit); total+bought > limit {
+		return "", &narratorError{cross.StatError, fmt.Sprintf(
+			"Your stats add up to %d and can't go above %d", total, limit)}
+	}
+	cost := pointsCost(bought)
+	if int(user.c.Gold) < cost {
+		return "", &narratorError{cross.StatError, fmt.Sprintf(
+			"%d points cost %d gold and you have %d", bought, cost, user.c.Gold)}
+	}
+
+	for _, name := range statNames {
+		*stats[name] += uint16(points[name])
+	}
+	user.c.Gold -= uint16(cost)
+
+	if err := user.conn.Send(user.c); err != nil {
+		return "", err
+	}
+	g.publish(globalTopic, &event{frames: lurk.AppendMarshal(nil, user.c), skip: user.c.Name})
+	return fmt.Sprintf("Your training pays off: attack %d, defense %d, regen %d. You have %d gold left.",
+		user.c.Attack, user.c.Defense, user.c.Regen, user.c.Gold), nil
+}
+
+func pointsCost(points int) int {
+	return (points*upgradeCost + upgradePoints - 1) / upgradePoints
+}
+
+// parseUpgrade reads pairs of a stat and points. Stats can be shortened, so "a 5 d 2" works.
+func parseUpgrade(args []string) (map[string]int, error) {
+	usage := &narratorError{cross.StatError, "Tell me what to upgrade: " + upgradeUsage}
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, usage
+	}
+	points := map[string]int{}
+	for i := 0; i < len(args); i += 2 {
+		name := ""
+		for _, stat := range statNames {
+			if strings.HasPrefix(stat, strings.ToLower(args[i])) {
+				name = stat
+				break
+			}
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if name == "" || err != nil || n <= 0 || n > maxStat {
+			return nil, usage
+		}
+		points[name] += n
+	}
+	return points, nil
+}
@@ -0,0 +1,384 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// World is everything that makes up a game: its rooms, how they connect and who lives in them.
+// Worlds are loaded from JSON so several can be hosted from one binary.
+type World struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	InitialPoints uint16 `json:"initialPoints"`
+	StatLimit     uint16 `json:"statLimit"`
+	// StartRoom is where new players arrive.
+	StartRoom uint16 `json:"startRoom"`
+	// RespawnRoom brings dead players back to life with full health when they enter it. It
+	// defaults to StartRoom.
+	RespawnRoom uint16 `json:"respawnRoom,omitempty"`
+	// UpgradeRoom is where gold can be spent on stats. Without one there are no upgrades.
+	UpgradeRoom uint16        `json:"upgradeRoom,omitempty"`
+	Rooms       []*RoomDef    `json:"rooms"`
+	Monsters    []*MonsterDef `json:"monsters"`
+	Items       []*ItemDef    `json:"items,omitempty"`
+	Vendors     []*VendorDef  `json:"vendors,omitempty"`
+	// Quests unlock hidden rooms, among other rewards.
+	Quests []*QuestDef `json:"quests,omitempty"`
+}
+
+type RoomDef struct {
+	Number      uint16   `json:"number"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Connections []uint16 `json:"connections"`
+	// Hidden rooms can't be entered or seen as a connection until they are unlocked.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+type MonsterDef struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Attack      uint16 `json:"attack"`
+	Defense     uint16 `json:"defense"`
+	Regen       uint16 `json:"regen"`
+	Health      int16  `json:"health"`
+	// MaxHealth is what the monster heals back to. It defaults to Health.
+	MaxHealth int16 `json:"maxHealth,omitempty"`
+	// GoldReward is given to whoever defeats the monster.
+	GoldReward uint16 `json:"goldReward"`
+	Room       uint16 `json:"room"`
+	// Monster sets the monster flag. Characters without it can only be fought with PVPFIGHT.
+	Monster bool `json:"monster"`
+	// Defeat is told to whoever defeats the monster.
+	Defeat string `json:"defeat,omitempty"`
+	// Drops are items the monster may leave to whoever defeats it.
+	Drops []*Drop `json:"drops,omitempty"`
+}
+
+// ItemDef is something players can carry. Items with a slot are equipment, which adds to the
+// wearer's stats while equipped. Items that heal are used up.
+type ItemDef struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Slot is where the item is equipped, such as "weapon". Only one item fits in each slot.
+	Slot    string `json:"slot,omitempty"`
+	Attack  uint16 `json:"attack,omitempty"`
+	Defense uint16 `json:"defense,omitempty"`
+	Regen   uint16 `json:"regen,omitempty"`
+	// Heal is the health given back when the item is used.
+	Heal int16 `json:"heal,omitempty"`
+	// Price is what the item is worth to vendors. Items without one can't be sold.
+	Price uint16 `json:"price,omitempty"`
+}
+
+// VendorDef is a character who buys and sells items. There is at most one in a room.
+type VendorDef struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Room        uint16 `json:"room"`
+	// Stock is what the vendor has for sale when the game starts, and after each restock.
+	Stock []*StockDef `json:"stock"`
+	// RestockSeconds is how often the stock is refilled. Zero never refills it.
+	RestockSeconds int `json:"restockSeconds,omitempty"`
+}
+
+type StockDef struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+type Drop struct {
+	Item string `json:"item"`
+	// Chance is how likely the drop is, from 0 to 1. It defaults to always.
+	Chance float64 `json:"chance,omitempty"`
+}
+
+// QuestDef is a goal players work toward. A quest starts once every quest it requires is
+// done, and is done once all of its objectives are met, in any order.
+type QuestDef struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Requires names quests listed before this one.
+	Requires   []string     `json:"requires,omitempty"`
+	Objectives []*Objective `json:"objectives"`
+	Reward     Reward       `json:"reward"`
+}
+
+// Objective is one thing to do. Exactly one of Kill, Reach, Gold and Talk is set.
+type Objective struct {
+	// Kill names a monster to defeat.
+	Kill string `json:"kill,omitempty"`
+	// Reach is a room to enter.
+	Reach uint16 `json:"reach,omitempty"`
+	// Gold is how much gold to hold at once.
+	Gold uint16 `json:"gold,omitempty"`
+	// Talk names a monster or vendor to message in their room. They answer with Say.
+	Talk string `json:"talk,omitempty"`
+	Say  string `json:"say,omitempty"`
+}
+
+type Reward struct {
+	// Rooms are hidden rooms the player can enter from then on.
+	Rooms []uint16 `json:"rooms,omitempty"`
+	Gold  uint16   `json:"gold,omitempty"`
+	Items []string `json:"items,omitempty"`
+	// Narration is told to the player when the quest is done.
+	Narration string `json:"narration,omitempty"`
+}
+
+//go:embed worlds/endersgame.json
+var defaultWorldJSON []byte
+
+var (
+	errNoRooms        = errors.New("world has no rooms")
+	errDuplicateRoom  = errors.New("room number used more than once")
+	errUnknownRoom    = errors.New("room does not exist")
+	errDuplicateActor = errors.New("monster name used more than once")
+	errDuplicateItem  = errors.New("item name used more than once")
+	errUnknownItem    = errors.New("item does not exist")
+	errBadItem        = errors.New("item is both equipment and used up")
+	errDuplicateShop  = errors.New("room has more than one vendor")
+	errDuplicateQuest = errors.New("quest name used more than once")
+	errUnknownQuest   = errors.New("quest is not listed before the quest requiring it")
+	errBadObjective   = errors.New("objective needs exactly one thing to do")
+	errUnknownActor   = errors.New("monster or vendor does not exist")
+)
+
+// defaultWorld returns the built in Ender's Game world.
+func defaultWorld() *World {
+	w, err := parseWorld(defaultWorldJSON)
+	if err != nil {
+		panic(fmt.Sprintf("%v: built in world is invalid", err))
+	}
+	return w
+}
+
+// LoadWorld reads a world definition from a JSON file.
+func LoadWorld(filename string) (*World, error) {
+	ba, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	w, err := parseWorld(ba)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, filename)
+	}
+	return w, nil
+}
+
+func parseWorld(ba []byte) (*World, error) {
+	w := &World{}
+	if err := json.Unmarshal(ba, w); err != nil {
+		return nil, err
+	}
+	return w, w.validate()
+}
+
+func (w *World) validate() error {
+	if len(w.Rooms) == 0 {
+		return errNoRooms
+	}
+	rooms := map[uint16]bool{}
+	for _, r := range w.Rooms {
+		if rooms[r.Number] {
+			return fmt.Errorf("%w: %d", errDuplicateRoom, r.Number)
+		}
+		rooms[r.Number] = true
+	}
+	if !rooms[w.StartRoom] {
+		return fmt.Errorf("%w: start room %d", errUnknownRoom, w.StartRoom)
+	}
+	if w.RespawnRoom != 0 && !rooms[w.RespawnRoom] {
+		return fmt.Errorf("%w: respawn room %d", errUnknownRoom, w.RespawnRoom)
+	}
+	if w.UpgradeRoom != 0 && !rooms[w.UpgradeRoom] {
+		return fmt.Errorf("%w: upgrade room %d", errUnknownRoom, w.UpgradeRoom)
+	}
+	for _, r := range w.Rooms {
+		for _, c := range r.Connections {
+			if !rooms[c] {
+				return fmt.Errorf("%w: %d connects to %d", errUnknownRoom, r.Number, c)
+			}
+		}
+	}
+
+	items := map[string]bool{}
+	for _, item := range w.Items {
+		if items[item.Name] {
+			return fmt.Errorf("%w: %s", errDuplicateItem, item.Name)
+		}
+		items[item.Name] = true
+		if item.Slot != "" && item.Heal != 0 {
+			return fmt.Errorf("%w: %s", errBadItem, item.Name)
+		}
+	}
+
+	names := map[string]bool{}
+	for _, m := range w.Monsters {
+		if names[m.Name] {
+			return fmt.Errorf("%w: %s", errDuplicateActor, m.Name)
+		}
+		names[m.Name] = true
+		if !rooms[m.Room] {
+			return fmt.Errorf("%w: %s is in room %d", errUnknownRoom, m.Name, m.Room)
+		}
+		for _, drop := range m.Drops {
+			if !items[drop.Item] {
+				return fmt.Errorf("%w: %s drops %s", errUnknownItem, m.Name, drop.Item)
+			}
+		}
+	}
+
+	shops := map[uint16]bool{}
+	for _, v := range w.Vendors {
+		if names[v.Name] {
+			return fmt.Errorf("%w: %s", errDuplicateActor, v.Name)
+		}
+		names[v.Name] = true
+		if !rooms[v.Room] {
+			return fmt.Errorf("%w: %s is in room %d", errUnknownRoom, v.Name, v.Room)
+		}
+		if shops[v.Room] {
+			return fmt.Errorf("%w: %d", errDuplicateShop, v.Room)
+		}
+		shops[v.Room] = true
+		for _, stock := range v.Stock {
+			if !items[stock.Item] {
+				return fmt.Errorf("%w: %s sells %s", errUnknownItem, v.Name, stock.Item)
+			}
+		}
+	}
+	return w.validateQuests(rooms, items, names)
+}
+
+// validateQuests checks quests against the rooms, items and monster or vendor names that exist.
+// Quests can only require earlier quests, so every quest can be started.
+func (w *World) validateQuests(rooms map[uint16]bool, items, names map[string]bool) error {
+	quests := map[string]bool{}
+	for _, q := range w.Quests {
+		if quests[q.Name] {
+			return fmt.Errorf("%w: %s", errDuplicateQuest, q.Name)
+		}
+		for _, name := range q.Requires {
+			if !quests[name] {
+				return fmt.Errorf("%w: %s requires %s", errUnknownQuest, q.Name, name)
+			}
+		}
+		quests[q.Name] = true
+
+		if len(q.Objectives) == 0 {
+			return fmt.Errorf("%w: %s has none", errBadObjective, q.Name)
+		}
+		for _, o := range q.Objectives {
+			set := 0
+			for _, ok := range []bool{o.Kill != "", o.Reach != 0, o.Gold != 0, o.Talk != ""} {
+				if ok {
+					set++
+				}
+			}
+			switch {
+			case set != 1:
+				return fmt.Errorf("%w: %s", errBadObjective, q.Name)
+			case o.Kill != "" && !names[o.Kill]:
+				return fmt.Errorf("%w: %s kills %s", errUnknownActor, q.Name, o.Kill)
+			case o.Talk != "" && !names[o.Talk]:
+				return fmt.Errorf("%w: %s talks to %s", errUnknownActor, q.Name, o.Talk)
+			case o.Reach != 0 && !rooms[o.Reach]:
+				return fmt.Errorf("%w: %s reaches %d", errUnknownRoom, q.Name, o.Reach)
+			}
+		}
+		for _, number := range q.Reward.Rooms {
+			if !rooms[number] {
+				return fmt.Errorf("%w: %s unlocks %d", errUnknownRoom, q.Name, number)
+			}
+		}
+		for _, item := range q.Reward.Items {
+			if !items[item] {
+				return fmt.Errorf("%w: %s gives %s", errUnknownItem, q.Name, item)
+			}
+		}
+	}
+	return nil
+}
+
+// createRooms builds the LURK rooms and connections of the world.
+func (w *World) createRooms() map[uint16]*room {
+	defs := make(map[uint16]*RoomDef, len(w.Rooms))
+	for _, r := range w.Rooms {
+		defs[r.Number] = r
+	}
+
+	rooms := make(map[uint16]*room, len(w.Rooms))
+	for _, r := range w.Rooms {
+		rm := &room{
+			r: &lurk.Room{
+				Type:       lurk.TypeRoom,
+				RoomNumber: r.Number,
+				RoomName:   r.Name,
+				RoomDesc:   r.Description,
+			},
+		}
+		for _, number := range r.Connections {
+			to := defs[number]
+			rm.connections = append(rm.connections, &lurk.Connection{
+				Type:       lurk.TypeConnection,
+				RoomNumber: to.Number,
+				RoomName:   to.Name,
+				RoomDesc:   to.Description,
+			})
+		}
+		rooms[r.Number] = rm
+	}
+	return rooms
+}
+
+// createMonsters builds a fresh character for every monster in the world.
+func (w *World) createMonsters() map[string]*lurk.Character {
+	monsters := make(map[string]*lurk.Character, len(w.Monsters))
+	for _, m := range w.Monsters {
+		monsters[m.Name] = &lurk.Character{
+			Type: lurk.TypeCharacter,
+			Name: m.Name,
+			Flags: map[string]bool{
+				lurk.Alive:   true,
+				lurk.Monster: m.Monster,
+			},
+			Attack:     m.Attack,
+			Defense:    m.Defense,
+			Regen:      m.Regen,
+			Health:     m.Health,
+			RoomNum:    m.Room,
+			PlayerDesc: m.Description,
+		}
+	}
+	return monsters
+}
+
+// createVendors builds every vendor with full stock, keyed by room number.
+func (w *World) createVendors() map[uint16]*vendor {
+	vendors := make(map[uint16]*vendor, len(w.Vendors))
+	for _, v := range w.Vendors {
+		vendors[v.Room] = newVendor(v)
+	}
+	return vendors
+}
+
+func (m *MonsterDef) maxHealth() int16 {
+	if m.MaxHealth != 0 {
+		return m.MaxHealth
+	}
+	return m.Health
+}
+
+func (w *World) respawnRoom() uint16 {
+	if w.RespawnRoom != 0 {
+		return w.RespawnRoom
+	}
+	return w.StartRoom
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+)
+
+func TestWorld(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("TestDefaultWorld", func(_ *testing.T) {
+		w := defaultWorld()
+		a.True(w.Name == "Ender's Game")
+		rooms := w.createRooms()
+		a.True(len(rooms) == len(w.Rooms))
+		a.True(len(rooms[w.StartRoom].connections) != 0)
+		a.True(len(w.createMonsters()) == len(w.Monsters))
+		a.True(len(w.createVendors()) == len(w.Vendors))
+	})
+	t.Run("TestInvalidWorlds", func(_ *testing.T) {
+		tests := []struct {
+			json string
+			err  error
+		}{
+			{`{"startRoom": 1}`, errNoRooms},
+			{`{"startRoom": 1, "rooms": [{"number": 1}, {"number": 1}]}`, errDuplicateRoom},
+			{`{"startRoom": 2, "rooms": [{"number": 1}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "rooms": [{"number": 1, "connections": [3]}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "respawnRoom": 2, "rooms": [{"number": 1}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "upgradeRoom": 2, "rooms": [{"number": 1}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "monsters": [{"name": "A", "room": 1}, {"name": "A", "room": 1}]}`, errDuplicateActor},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "monsters": [{"name": "A", "room": 4}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "items": [{"name": "I"}, {"name": "I"}]}`, errDuplicateItem},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "items": [{"name": "I", "slot": "hat", "heal": 5}]}`, errBadItem},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "monsters": [{"name": "A", "room": 1, "drops": [{"item": "I"}]}]}`, errUnknownItem},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "vendors": [{"name": "V", "room": 1, "stock": [{"item": "I"}]}]}`, errUnknownItem},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "vendors": [{"name": "V", "room": 2}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "monsters": [{"name": "A", "room": 1}], "vendors": [{"name": "A", "room": 1}]}`, errDuplicateActor},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "vendors": [{"name": "V", "room": 1}, {"name": "W", "room": 1}]}`, errDuplicateShop},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q", "objectives": [{"reach": 1}]}, {"name": "Q", "objectives": [{"reach": 1}]}]}`, errDuplicateQuest},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q", "requires": ["Q"], "objectives": [{"reach": 1}]}]}`, errUnknownQuest},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q"}]}`, errBadObjective},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q", "objectives": [{"reach": 1, "gold": 5}]}]}`, errBadObjective},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q", "objectives": [{"kill": "A"}]}]}`, errUnknownActor},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q", "objectives": [{"reach": 2}]}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q", "objectives": [{"gold": 5}], "reward": {"rooms": [2]}}]}`, errUnknownRoom},
+			{`{"startRoom": 1, "rooms": [{"number": 1}], "quests": [{"name": "Q", "objectives": [{"gold": 5}], "reward": {"items": ["I"]}}]}`, errUnknownItem},
+		}
+		for _, test := range tests {
+			_, err := parseWorld([]byte(test.json))
+			a.True(errors.Is(err, test.err))
+		}
+
+		_, err := LoadWorld("missing.json")
+		a.Error(err)
+	})
+	t.Run("TestMaxHealth", func(_ *testing.T) {
+		a.True((&MonsterDef{Health: 10}).maxHealth() == 10)
+		a.True((&MonsterDef{Health: 10, MaxHealth: 20}).maxHealth() == 20)
+	})
+	t.Run("TestRespawnRoom", func(_ *testing.T) {
+		a.True((&World{StartRoom: 1}).respawnRoom() == 1)
+		a.True((&World{StartRoom: 1, RespawnRoom: 2}).respawnRoom() == 2)
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Clayal10/enders_game/cmd/tui/code/tui"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func main() {
+	address := flag.String("addr", "localhost:5069", "LURK server to connect to")
+	useTLS := flag.Bool("tls", false, "connect over TLS")
+	insecure := flag.Bool("tls-insecure", false, "accept any TLS certificate, such as a self signed one")
+	name := flag.String("name", "", "character name, asked for when empty")
+	attack := flag.Uint("attack", 40, "attack points")
+	defense := flag.Uint("defense", 30, "defense points")
+	regen := flag.Uint("regen", 30, "regen points")
+	desc := flag.String("desc", "A traveller from the terminal.", "character description")
+	width := flag.Int("width", envInt("COLUMNS", 80), "screen width, defaults to $COLUMNS")
+	height := flag.Int("height", envInt("LINES", 24), "screen height, defaults to $LINES")
+	flag.Parse()
+
+	in := bufio.NewReader(os.Stdin)
+	if *name == "" {
+		fmt.Print("Character name: ")
+		line, err := in.ReadString('\n')
+		fatalOnErr(err)
+		*name = strings.TrimSpace(line)
+	}
+
+	cfg := &tui.Config{
+		Address: *address,
+		Character: &lurk.Character{
+			Name:       *name,
+			Attack:     uint16(*attack),
+			Defense:    uint16(*defense),
+			Regen:      uint16(*regen),
+			PlayerDesc: *desc,
+		},
+		Width:  *width,
+		Height: *height,
+	}
+	if *useTLS {
+		cfg.TLS = &tls.Config{
+			InsecureSkipVerify: *insecure, //nolint:gosec // Opt in for development servers.
+			MinVersion:         tls.VersionTLS12,
+		}
+	}
+	fatalOnErr(tui.Run(cfg, in, os.Stdout))
+}
+
+func envInt(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+func fatalOnErr(err error) {
+	if err != nil {
+		log.Fatalf("%v: could not play", err.Error())
+	}
+}
@@ -0,0 +1,187 @@
+// Package tui is a terminal LURK client. It redraws the whole screen with ANSI escape codes
+// and reads commands a line at a time, so it needs nothing more than a plain terminal.
+package tui
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/client/code/client"
+	"github.com/Clayal10/enders_game/pkg/bot"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+const (
+	maxLog    = 500
+	minWidth  = 30
+	minHeight = 12
+	// Bursts of messages are drawn once.
+	redrawDelay = 30 * time.Millisecond
+)
+
+type Config struct {
+	Address   string
+	TLS       *tls.Config
+	Character *lurk.Character
+	Width     int
+	Height    int
+}
+
+type app struct {
+	cfg *Config
+	out io.Writer
+	b   *bot.Bot
+
+	mu     sync.Mutex
+	log    []string
+	status string
+	redraw *time.Timer
+
+	// drawMu keeps the input loop and the redraw timer from drawing over each other.
+	drawMu sync.Mutex
+}
+
+// Run plays the game until the player quits, input ends or the server goes away.
+func Run(cfg *Config, in io.Reader, out io.Writer) error {
+	cfg.Width, cfg.Height = max(cfg.Width, minWidth), max(cfg.Height, minHeight)
+	a := &app{cfg: cfg, out: out}
+	a.redraw = time.AfterFunc(time.Hour, a.draw)
+	a.redraw.Stop()
+	defer a.redraw.Stop()
+
+	var err error
+	a.b, err = bot.Connect(cfg.Address, &bot.Options{TLS: cfg.TLS, OnMessage: a.onMessage})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = a.b.Close() }()
+	if err = a.b.CreateCharacter(cfg.Character); err != nil {
+		return err
+	}
+	if err = a.b.Start(); err != nil {
+		return err
+	}
+	a.draw()
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			a.draw()
+			continue
+		}
+		cmd, err := client.Interpret(scanner.Text(), a.completions())
+		if err != nil {
+			a.addLog("! " + err.Error())
+			a.draw()
+			continue
+		}
+		if _, ok := cmd.Message.(*lurk.Leave); ok {
+			return a.b.Leave()
+		}
+		if err = a.perform(cmd); err != nil && !errors.Is(err, bot.ErrRejected) {
+			return err
+		}
+		a.draw()
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return a.b.Leave()
+}
+
+// perform runs a command. Rejections were already logged when the ERROR arrived.
+func (a *app) perform(cmd *client.Command) error {
+	if cmd.Message == nil {
+		a.addLog(cmd.Reply)
+		return nil
+	}
+	a.setStatus("Waiting for the server...")
+	defer a.setStatus("")
+	switch msg := cmd.Message.(type) {
+	case *lurk.ChangeRoom:
+		return a.b.Move(msg.RoomNumber)
+	case *lurk.Fight:
+		return a.b.Fight()
+	case *lurk.PVPFight:
+		return a.b.PVP(msg.TargetName)
+	case *lurk.Loot:
+		return a.b.Loot(msg.TargetName)
+	case *lurk.Message:
+		if err := a.b.Say(msg.Recipient, msg.Text); err != nil {
+			return err
+		}
+		a.addLog(fmt.Sprintf("you -> %s: %s", msg.Recipient, msg.Text))
+	}
+	return nil
+}
+
+func (a *app) completions() *client.Completions {
+	return &client.Completions{
+		Self:        a.b.Character(),
+		Connections: a.b.Connections(),
+		Characters:  a.b.Others(),
+	}
+}
+
+// onMessage runs on the bot's reader goroutine.
+func (a *app) onMessage(lm lurk.LurkMessage) {
+	switch msg := lm.(type) {
+	case *lurk.Game:
+		a.addLog(msg.GameDesc)
+	case *lurk.Room:
+		a.addLog("You enter " + msg.RoomName + ".")
+	case *lurk.Message:
+		if msg.Narration {
+			a.addLog("* " + msg.Text)
+		} else {
+			a.addLog(msg.Sender + ": " + msg.Text)
+		}
+	case *lurk.Error:
+		a.addLog("! " + msg.ErrMessage)
+	}
+	a.redraw.Reset(redrawDelay)
+}
+
+func (a *app) addLog(line string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.log) == maxLog {
+		a.log = a.log[1:]
+	}
+	a.log = append(a.log, line)
+}
+
+func (a *app) setStatus(status string) {
+	a.mu.Lock()
+	a.status = status
+	a.mu.Unlock()
+	a.draw()
+}
+
+func (a *app) screen() *Screen {
+	s := &Screen{
+		Title:       "LURK " + a.cfg.Address,
+		Room:        a.b.Room(),
+		Connections: a.b.Connections(),
+		Self:        a.b.Character(),
+		Others:      a.b.Others(),
+	}
+	a.mu.Lock()
+	s.Log = append([]string(nil), a.log...)
+	s.Status = a.status
+	a.mu.Unlock()
+	return s
+}
+
+func (a *app) draw() {
+	s := a.screen()
+	a.drawMu.Lock()
+	defer a.drawMu.Unlock()
+	_ = s.Draw(a.out, a.cfg.Width, a.cfg.Height)
+}
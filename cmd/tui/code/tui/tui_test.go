@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Clayal10/enders_game/cmd/client/code/client"
+	"github.com/Clayal10/enders_game/cmd/server/code/server"
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func testScreen() *Screen {
+	return &Screen{
+		Title: "LURK",
+		Room:  &lurk.Room{RoomNumber: 1, RoomName: "Battle School", RoomDesc: strings.Repeat("A place where children play. ", 20)},
+		Connections: []*lurk.Connection{
+			{RoomNumber: 2, RoomName: "The Barracks"},
+			{RoomNumber: 3, RoomName: "The Game Room"},
+			{RoomNumber: 4, RoomName: "The Battle Room"},
+		},
+		Self: &lurk.Character{Name: "Ender", Health: 100, Flags: map[string]bool{lurk.Alive: true}},
+		Others: []*lurk.Character{
+			{Name: "Petra Arkanian", Health: 80, Flags: map[string]bool{lurk.Alive: true, lurk.Monster: true}},
+			{Name: "Bean", Flags: map[string]bool{lurk.Monster: true}},
+			{Name: "Bernard", Health: 90, Flags: map[string]bool{lurk.Alive: true}},
+		},
+		Log: []string{"first", "Bean: " + strings.Repeat("very long message ", 10), "last"},
+	}
+}
+
+func TestDraw(t *testing.T) {
+	a := assert.New(t)
+
+	var out bytes.Buffer
+	a.NoError(testScreen().Draw(&out, 40, 20))
+	screen := strings.TrimPrefix(out.String(), clearScreen)
+	lines := strings.Split(screen, "\r\n")
+	// The prompt takes the last line.
+	a.True(len(lines) == 20)
+	for _, line := range lines[:19] {
+		for _, code := range []string{inverse, bold, reset} {
+			line = strings.ReplaceAll(line, code, "")
+		}
+		a.True(utf8.RuneCountInString(line) == 40)
+	}
+	a.True(strings.Contains(screen, "LURK - Battle School (1)"))
+	a.True(strings.Contains(screen, "[2] The Barracks"))
+	a.True(strings.Contains(screen, "Bernard HP 90"))
+	a.True(strings.Contains(screen, "Bean (dead)"))
+	a.True(strings.Contains(screen, "Ender  HP 100"))
+	// The log shows its newest lines.
+	a.True(strings.Contains(screen, "last"))
+	a.True(strings.HasSuffix(screen, "> "))
+}
+
+// syncBuffer is written by the redraw timer and read by the test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	a := assert.New(t)
+
+	port := cross.GetFreePort()
+	cfs, err := server.New(&server.Config{Port: port})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+
+	in, input := io.Pipe()
+	out := &syncBuffer{}
+	done := make(chan error)
+	go func() {
+		done <- Run(&Config{
+			Address:   fmt.Sprintf("localhost:%v", port),
+			Character: &lurk.Character{Name: "Ender", Attack: 50, Defense: 25, Regen: 25},
+			Width:     80,
+			Height:    24,
+		}, in, out)
+	}()
+
+	for _, line := range []string{"m game", "f", "m 14", "h", "s", "dance", "q"} {
+		_, err = io.WriteString(input, line+"\n")
+		a.NoError(err)
+	}
+	a.NoError(<-done)
+
+	screen := out.String()
+	a.True(strings.Contains(screen, "The Game Room (3)"))
+	a.True(strings.Contains(screen, "Petra Arkanian"))
+	a.True(strings.Contains(screen, "Attack: 50"))
+	a.True(strings.Contains(screen, "! "+cross.ErrRoomsNotConnected.Error()))
+	a.True(strings.Contains(screen, "go <room number or name>"))
+	a.True(strings.Contains(screen, client.ErrUnknownCommand.Error()))
+}
@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// ANSI escape codes. Everything else about the terminal is left alone so the client works
+// over plain SSH sessions.
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	inverse     = "\x1b[7m"
+	bold        = "\x1b[1m"
+	reset       = "\x1b[0m"
+)
+
+// Screen is everything the client shows, copied out of the bot so it can be drawn without
+// holding any locks.
+type Screen struct {
+	Title       string
+	Room        *lurk.Room
+	Connections []*lurk.Connection
+	Self        *lurk.Character
+	Others      []*lurk.Character
+	Log         []string
+	Status      string
+}
+
+// Draw writes the whole screen, sized width by height, ending with the input prompt.
+func (s *Screen) Draw(w io.Writer, width, height int) error {
+	var lines []string
+	add := func(style, text string) {
+		lines = append(lines, style+fit(text, width)+reset)
+	}
+
+	title := s.Title
+	if s.Room != nil {
+		title = fmt.Sprintf("%s - %s (%d)", title, s.Room.RoomName, s.Room.RoomNumber)
+	}
+	add(inverse, title)
+	if s.Room != nil {
+		for _, line := range limit(wrap(s.Room.RoomDesc, width), 3, width) {
+			add("", line)
+		}
+	}
+	exits := make([]string, 0, len(s.Connections))
+	for _, c := range s.Connections {
+		exits = append(exits, fmt.Sprintf("[%d] %s", c.RoomNumber, c.RoomName))
+	}
+	for _, line := range limit(wrap("Exits: "+strings.Join(exits, "  "), width), 2, width) {
+		add("", line)
+	}
+
+	players, monsters := s.split()
+	half := width / 2
+	add(bold, fit("Players", half)+fit("Monsters", width-half))
+	rows := min(max(len(players), len(monsters)), 6)
+	for i := range rows {
+		add("", fit(entry(players, i), half)+fit(entry(monsters, i), width-half))
+	}
+
+	add(bold, "Log")
+	// What's left, minus the status line and prompt.
+	logRows := max(height-len(lines)-2, 1)
+	var logLines []string
+	for _, msg := range s.Log {
+		logLines = append(logLines, wrap(msg, width)...)
+	}
+	if len(logLines) > logRows {
+		logLines = logLines[len(logLines)-logRows:]
+	}
+	for i := range logRows {
+		if i < len(logLines) {
+			add("", logLines[i])
+		} else {
+			add("", "")
+		}
+	}
+
+	add(inverse, s.statusLine())
+
+	_, err := io.WriteString(w, clearScreen+strings.Join(lines, "\r\n")+"\r\n> ")
+	return err
+}
+
+func (s *Screen) statusLine() string {
+	if s.Status != "" {
+		return s.Status
+	}
+	if s.Self == nil {
+		return ""
+	}
+	c := s.Self
+	state := "alive"
+	if !c.Flags[lurk.Alive] {
+		state = "dead"
+	}
+	return fmt.Sprintf("%s  HP %d  ATK %d  DEF %d  REG %d  Gold %d  (%s)  h for help",
+		c.Name, c.Health, c.Attack, c.Defense, c.Regen, c.Gold, state)
+}
+
+// split sorts everyone in the room into players and monsters, by name.
+func (s *Screen) split() (players, monsters []*lurk.Character) {
+	for _, c := range s.Others {
+		if c.Flags[lurk.Monster] {
+			monsters = append(monsters, c)
+		} else {
+			players = append(players, c)
+		}
+	}
+	byName := func(cs []*lurk.Character) {
+		sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+	}
+	byName(players)
+	byName(monsters)
+	return
+}
+
+func entry(cs []*lurk.Character, i int) string {
+	if i >= len(cs) {
+		return ""
+	}
+	c := cs[i]
+	if !c.Flags[lurk.Alive] {
+		return fmt.Sprintf("%s (dead)", c.Name)
+	}
+	return fmt.Sprintf("%s HP %d", c.Name, c.Health)
+}
+
+// fit pads or cuts text to exactly width runes.
+func fit(text string, width int) string {
+	n := utf8.RuneCountInString(text)
+	if n <= width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	runes := []rune(text)
+	if width <= 1 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-1]) + "~"
+}
+
+// wrap breaks text into lines no wider than width, on spaces where it can.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		// Short lines keep their spacing, which matters for ASCII art.
+		if utf8.RuneCountInString(paragraph) <= width {
+			lines = append(lines, paragraph)
+			continue
+		}
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				runes := []rune(word)
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// limit keeps the first n lines, marking the last one if any were dropped.
+func limit(lines []string, n, width int) []string {
+	if len(lines) <= n {
+		return lines
+	}
+	lines = lines[:n]
+	lines[n-1] = fit(lines[n-1]+" ...", width)
+	return lines
+}
@@ -0,0 +1,367 @@
+// Package bot plays LURK without a person at the keyboard. A Bot keeps track of what the
+// server has told it about its character and room, and every action waits for the server's
+// answer, so playtests, bug reproductions and helper players can be written as plain code.
+package bot
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	// Messages nobody has waited for are dropped past this point, oldest first.
+	maxPending = 1024
+)
+
+var (
+	// ErrRejected is wrapped by errors for actions the server answered with an ERROR.
+	ErrRejected = errors.New("server rejected the action")
+	ErrTimeout  = errors.New("timed out waiting for the server")
+	ErrClosed   = errors.New("connection to the server is closed")
+)
+
+// Options are all optional, a nil *Options uses the defaults.
+type Options struct {
+	// TLS dials the server over TLS when set.
+	TLS *tls.Config
+	// Timeout bounds how long an action waits for the server. Defaults to 5 seconds.
+	Timeout time.Duration
+	// OnMessage is called with every message from the server, on the bot's reader goroutine.
+	OnMessage func(lurk.LurkMessage)
+}
+
+// Bot is a single LURK player. Its methods are safe to call from any goroutine, but actions
+// are meant to be performed one at a time.
+type Bot struct {
+	conn      *lurk.Conn
+	timeout   time.Duration
+	onMessage func(lurk.LurkMessage)
+
+	mu sync.Mutex
+	// changed is closed, and replaced, whenever a message arrives.
+	changed chan struct{}
+	pending []lurk.LurkMessage
+	// err is why the reader stopped.
+	err  error
+	done chan struct{}
+
+	game        *lurk.Game
+	character   *lurk.Character
+	room        *lurk.Room
+	connections []*lurk.Connection
+	// others is every other character known to be in the bot's room, by name.
+	others map[string]*lurk.Character
+}
+
+// Connect dials a LURK server and waits for its GAME message.
+func Connect(address string, opts *Options) (*Bot, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var netConn net.Conn
+	var err error
+	if opts.TLS != nil {
+		netConn, err = tls.Dial("tcp", address, opts.TLS)
+	} else {
+		netConn, err = net.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bot{
+		conn:      lurk.NewConn(netConn),
+		timeout:   opts.Timeout,
+		onMessage: opts.OnMessage,
+		changed:   make(chan struct{}),
+		done:      make(chan struct{}),
+		others:    make(map[string]*lurk.Character),
+	}
+	if b.timeout <= 0 {
+		b.timeout = defaultTimeout
+	}
+	go b.read()
+
+	if _, err = b.WaitFor(IsType(lurk.TypeGame)); err != nil {
+		_ = b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// CreateCharacter sends c and waits for the server to accept it. Ready is set if c has no
+// flags.
+func (b *Bot) CreateCharacter(c *lurk.Character) error {
+	character := *c
+	character.Type = lurk.TypeCharacter
+	if character.Flags == nil {
+		character.Flags = map[string]bool{lurk.Ready: true}
+	}
+	b.mu.Lock()
+	b.character = &character
+	b.mu.Unlock()
+	_, err := b.do(&character, isAccept(lurk.TypeCharacter))
+	return err
+}
+
+// Start begins the game and waits for the first room.
+func (b *Bot) Start() error {
+	_, err := b.do(&lurk.Start{Type: lurk.TypeStart}, IsType(lurk.TypeRoom))
+	return err
+}
+
+// Move changes to a connected room and waits until the bot is in it.
+func (b *Bot) Move(room uint16) error {
+	_, err := b.do(&lurk.ChangeRoom{Type: lurk.TypeChangeRoom, RoomNumber: room}, InRoom(room))
+	return err
+}
+
+// Fight fights everything in the room and waits for the bot's updated character.
+func (b *Bot) Fight() error {
+	_, err := b.do(&lurk.Fight{Type: lurk.TypeFight}, b.isSelf)
+	return err
+}
+
+// PVP fights another player and waits for the bot's updated character.
+func (b *Bot) PVP(target string) error {
+	_, err := b.do(&lurk.PVPFight{Type: lurk.TypePVPFight, TargetName: target}, b.isSelf)
+	return err
+}
+
+// Loot takes gold from a dead character and waits for the bot's updated character.
+func (b *Bot) Loot(target string) error {
+	_, err := b.do(&lurk.Loot{Type: lurk.TypeLoot, TargetName: target}, b.isSelf)
+	return err
+}
+
+// Say sends text to another player.
+func (b *Bot) Say(recipient, text string) error {
+	_, err := b.do(&lurk.Message{
+		Type:      lurk.TypeMessage,
+		Recipient: recipient,
+		Sender:    b.name(),
+		Text:      text,
+	}, isAccept(lurk.TypeMessage))
+	return err
+}
+
+// Leave tells the server the bot is leaving and closes the connection.
+func (b *Bot) Leave() error {
+	if err := b.conn.Send(&lurk.Leave{Type: lurk.TypeLeave}); err != nil {
+		_ = b.Close()
+		return err
+	}
+	return b.Close()
+}
+
+// Close drops the connection without a LEAVE.
+func (b *Bot) Close() error {
+	err := b.conn.Close()
+	<-b.done
+	return err
+}
+
+// WaitFor returns the first message from the server that matches, skipping everything before
+// it. Messages that arrived since the last action are included.
+func (b *Bot) WaitFor(match func(lurk.LurkMessage) bool) (lurk.LurkMessage, error) {
+	timer := time.NewTimer(b.timeout)
+	defer timer.Stop()
+	for {
+		b.mu.Lock()
+		for i, lm := range b.pending {
+			if match(lm) {
+				b.pending = b.pending[i+1:]
+				b.mu.Unlock()
+				return lm, nil
+			}
+		}
+		b.pending = b.pending[:0]
+		changed, err := b.changed, b.err
+		b.mu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrClosed, err)
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// do sends lm and waits for done, or for an ERROR from the server.
+func (b *Bot) do(lm lurk.LurkMessage, done func(lurk.LurkMessage) bool) (lurk.LurkMessage, error) {
+	// Anything already received can't be the answer.
+	b.mu.Lock()
+	b.pending = b.pending[:0]
+	b.mu.Unlock()
+
+	if err := b.conn.Send(lm); err != nil {
+		return nil, err
+	}
+	answer, err := b.WaitFor(func(lm lurk.LurkMessage) bool {
+		return lm.GetType() == lurk.TypeError || done(lm)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := answer.(*lurk.Error); ok {
+		return nil, fmt.Errorf("%w: %s (%v)", ErrRejected, e.ErrMessage, e.ErrCode)
+	}
+	return answer, nil
+}
+
+func (b *Bot) read() {
+	defer close(b.done)
+	for {
+		frame, err := b.conn.ReceiveFrame()
+		if errors.Is(err, cross.ErrInvalidMessageType) {
+			continue
+		}
+		if err != nil {
+			b.mu.Lock()
+			b.err = err
+			close(b.changed)
+			b.mu.Unlock()
+			return
+		}
+		lm, err := lurk.Unmarshal(frame)
+		if err != nil {
+			// A broken message shouldn't end the game, the next one may be fine.
+			continue
+		}
+		if b.onMessage != nil {
+			b.onMessage(lm)
+		}
+
+		b.mu.Lock()
+		b.update(lm)
+		if len(b.pending) == maxPending {
+			b.pending = b.pending[1:]
+		}
+		b.pending = append(b.pending, lm)
+		close(b.changed)
+		b.changed = make(chan struct{})
+		b.mu.Unlock()
+	}
+}
+
+// update keeps the bot's view of the game current. Must hold b.mu.
+func (b *Bot) update(lm lurk.LurkMessage) {
+	switch msg := lm.(type) {
+	case *lurk.Game:
+		b.game = msg
+	case *lurk.Room:
+		b.room = msg
+		b.connections = nil
+		clear(b.others)
+	case *lurk.Connection:
+		b.connections = append(b.connections, msg)
+	case *lurk.Character:
+		if b.character != nil && msg.Name == b.character.Name {
+			b.character = msg
+			return
+		}
+		if b.room != nil && msg.RoomNum == b.room.RoomNumber {
+			b.others[msg.Name] = msg
+		} else {
+			delete(b.others, msg.Name)
+		}
+	}
+}
+
+func (b *Bot) name() string {
+	if c := b.Character(); c != nil {
+		return c.Name
+	}
+	return ""
+}
+
+// isSelf is only called by WaitFor, which holds b.mu.
+func (b *Bot) isSelf(lm lurk.LurkMessage) bool {
+	c, ok := lm.(*lurk.Character)
+	return ok && b.character != nil && c.Name == b.character.Name
+}
+
+// Game returns the server's GAME message.
+func (b *Bot) Game() *lurk.Game {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.game
+}
+
+// Character returns the latest version of the bot's character, or nil before CreateCharacter.
+func (b *Bot) Character() *lurk.Character {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.character
+}
+
+// Room returns the room the bot is in, or nil before the game starts.
+func (b *Bot) Room() *lurk.Room {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.room
+}
+
+// Connections returns the rooms the bot can move to.
+func (b *Bot) Connections() []*lurk.Connection {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*lurk.Connection(nil), b.connections...)
+}
+
+// Others returns every other character known to be in the bot's room.
+func (b *Bot) Others() []*lurk.Character {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	others := make([]*lurk.Character, 0, len(b.others))
+	for _, c := range b.others {
+		others = append(others, c)
+	}
+	return others
+}
+
+// Stats returns the traffic on the bot's connection.
+func (b *Bot) Stats() lurk.ConnStats {
+	return b.conn.Stats()
+}
+
+// IsType matches messages of type t.
+func IsType(t lurk.MessageType) func(lurk.LurkMessage) bool {
+	return func(lm lurk.LurkMessage) bool {
+		return lm.GetType() == t
+	}
+}
+
+// InRoom matches the ROOM message for room.
+func InRoom(room uint16) func(lurk.LurkMessage) bool {
+	return func(lm lurk.LurkMessage) bool {
+		r, ok := lm.(*lurk.Room)
+		return ok && r.RoomNumber == room
+	}
+}
+
+// MessageFrom matches a MESSAGE sent by sender.
+func MessageFrom(sender string) func(lurk.LurkMessage) bool {
+	return func(lm lurk.LurkMessage) bool {
+		m, ok := lm.(*lurk.Message)
+		return ok && m.Sender == sender
+	}
+}
+
+func isAccept(action lurk.MessageType) func(lurk.LurkMessage) bool {
+	return func(lm lurk.LurkMessage) bool {
+		a, ok := lm.(*lurk.Accept)
+		return ok && a.Action == action
+	}
+}
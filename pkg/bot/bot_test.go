@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/cmd/server/code/server"
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func TestBot(t *testing.T) {
+	a := assert.New(t)
+
+	port := cross.GetFreePort()
+	cfs, err := server.New(&server.Config{Port: port})
+	a.NoError(err)
+	defer func() {
+		for _, cf := range cfs {
+			cf()
+		}
+	}()
+	address := fmt.Sprintf("localhost:%v", port)
+
+	newBot := func(name string) *Bot {
+		b, err := Connect(address, &Options{Timeout: time.Second})
+		a.NoError(err)
+		a.NoError(b.CreateCharacter(&lurk.Character{Name: name, Attack: 50, Defense: 25, Regen: 25}))
+		a.NoError(b.Start())
+		return b
+	}
+
+	t.Run("TestPlay", func(_ *testing.T) {
+		b := newBot("Ender")
+		defer func() { a.NoError(b.Leave()) }()
+
+		a.True(b.Game() != nil)
+		a.True(b.Room().RoomNumber == 1)
+		a.True(len(b.Connections()) != 0)
+
+		a.NoError(b.Move(3))
+		a.True(b.Room().RoomName == "The Game Room")
+		a.Eventually(func() bool {
+			for _, c := range b.Others() {
+				if c.Name == "Petra Arkanian" {
+					return true
+				}
+			}
+			return false
+		}, time.Second, 5*time.Millisecond)
+
+		a.NoError(b.Fight())
+		a.True(b.Character().Health < 100)
+
+		err := b.Move(14)
+		a.True(errors.Is(err, ErrRejected))
+		a.True(b.Room().RoomNumber == 3)
+
+		err = b.Loot("Nobody")
+		a.True(errors.Is(err, ErrRejected))
+	})
+	t.Run("TestSay", func(_ *testing.T) {
+		alai := newBot("Alai")
+		defer func() { a.NoError(alai.Leave()) }()
+		dink := newBot("Dink")
+		defer func() { a.NoError(dink.Leave()) }()
+
+		a.NoError(alai.Say("Dink", "Salaam"))
+		lm, err := dink.WaitFor(MessageFrom("Alai"))
+		a.NoError(err)
+		a.True(lm.(*lurk.Message).Text == "Salaam")
+
+		err = alai.Say("Nobody", "Hello?")
+		a.True(errors.Is(err, ErrRejected))
+	})
+	t.Run("TestTimeoutAndClose", func(_ *testing.T) {
+		b, err := Connect(address, &Options{Timeout: 50 * time.Millisecond})
+		a.NoError(err)
+
+		_, err = b.WaitFor(IsType(lurk.TypeRoom))
+		a.True(errors.Is(err, ErrTimeout))
+
+		a.NoError(b.Close())
+		_, err = b.WaitFor(IsType(lurk.TypeRoom))
+		a.True(errors.Is(err, ErrClosed))
+
+		_, err = Connect(fmt.Sprintf("localhost:%v", cross.GetFreePort()), nil)
+		a.Error(err)
+	})
+}
@@ -2,6 +2,7 @@ package cross
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -13,6 +14,8 @@ var (
 	ErrInvalidRoomNumber  = errors.New("room number does not exist")
 	ErrRoomsNotConnected  = errors.New("rooms are not connected")
 	ErrQueueEmpty         = errors.New("queue empty")
+	ErrQueueFull          = errors.New("queue full")
+	ErrQueueClosed        = errors.New("queue closed")
 	ErrNotInitialized     = errors.New("not initialized")
 )
 
@@ -30,3 +33,23 @@ const (
 	NoPVP               ErrCode = 8
 	NoError             ErrCode = 255
 )
+
+var errCodeNames = map[ErrCode]string{
+	Other:               "Other",
+	BadRoom:             "Bad room",
+	PlayerAlreadyExists: "Player already exists",
+	BadMonster:          "Bad monster",
+	StatError:           "Stat error",
+	NotReady:            "Not ready",
+	NoTarget:            "No target",
+	NoFight:             "No fight",
+	NoPVP:               "No PVP",
+	NoError:             "No error",
+}
+
+func (e ErrCode) String() string {
+	if name, ok := errCodeNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown error %d", byte(e))
+}
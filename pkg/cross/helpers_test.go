@@ -34,3 +34,14 @@ func TestErrLogging(t *testing.T) {
 
 	a.True(strings.Contains(buf.String(), "error in deferred function"))
 }
+
+func TestSelfSignedCertificate(t *testing.T) {
+	a := assert.New(t)
+
+	cert, err := cross.SelfSignedCertificate("localhost", "127.0.0.1")
+	a.NoError(err)
+	a.NotNil(cert.Leaf)
+	a.NoError(cert.Leaf.VerifyHostname("localhost"))
+	a.NoError(cert.Leaf.VerifyHostname("127.0.0.1"))
+	a.Error(cert.Leaf.VerifyHostname("example.com"))
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+// Bus delivers events published on a topic to everyone subscribed to it. Every subscriber has
+// its own queue, so a slow one only holds up publishers when its overflow policy is Block.
+type Bus[T any] struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription[T]]struct{}
+}
+
+// Subscription receives the events of its topics in the order they were published.
+type Subscription[T any] struct {
+	bus   *Bus[T]
+	queue *Queue[T]
+	// topics is guarded by bus.mu.
+	topics map[string]struct{}
+}
+
+func NewBus[T any]() *Bus[T] {
+	return &Bus[T]{
+		topics: make(map[string]map[*Subscription[T]]struct{}),
+	}
+}
+
+// Subscribe starts receiving events on topics, buffering up to buffer of them. overflow says
+// what happens to events published while the buffer is full.
+func (b *Bus[T]) Subscribe(buffer int, overflow Overflow, topics ...string) *Subscription[T] {
+	s := &Subscription[T]{
+		bus:    b,
+		queue:  NewQueueWithOverflow[T](buffer, overflow),
+		topics: make(map[string]struct{}),
+	}
+	s.Add(topics...)
+	return s
+}
+
+// Publish hands event to every subscriber of topic. Subscribers that couldn't take it, such as
+// a full one with the Error policy, are reported together in the returned error. The others
+// still get the event.
+func (b *Bus[T]) Publish(ctx context.Context, topic string, event T) error {
+	b.mu.RLock()
+	subs := make([]*Subscription[T], 0, len(b.topics[topic]))
+	for s := range b.topics[topic] {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	// Not holding the lock, since a blocked subscriber may need to unsubscribe.
+	var errs []error
+	for _, s := range subs {
+		err := s.queue.EnqueueContext(ctx, event)
+		if err != nil && !errors.Is(err, cross.ErrQueueClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribers counts the subscriptions to topic.
+func (b *Bus[T]) Subscribers(topic string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.topics[topic])
+}
+
+// Add subscribes to more topics. Adding a topic twice does nothing.
+func (s *Subscription[T]) Add(topics ...string) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	for _, topic := range topics {
+		subs, ok := s.bus.topics[topic]
+		if !ok {
+			subs = make(map[*Subscription[T]]struct{})
+			s.bus.topics[topic] = subs
+		}
+		subs[s] = struct{}{}
+		s.topics[topic] = struct{}{}
+	}
+}
+
+// Remove stops receiving events on topics. Events already buffered are kept.
+func (s *Subscription[T]) Remove(topics ...string) {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	for _, topic := range topics {
+		s.bus.remove(s, topic)
+	}
+}
+
+// Next returns the oldest buffered event, waiting for one if there are none. After Unsubscribe
+// it returns what is left, then cross.ErrQueueClosed.
+func (s *Subscription[T]) Next(ctx context.Context) (T, error) {
+	return s.queue.Dequeue(ctx)
+}
+
+// Unsubscribe leaves every topic and wakes anyone waiting in Next. It is safe to call more
+// than once.
+func (s *Subscription[T]) Unsubscribe() {
+	s.bus.mu.Lock()
+	for topic := range s.topics {
+		s.bus.remove(s, topic)
+	}
+	s.bus.mu.Unlock()
+	s.queue.Close()
+}
+
+// Dropped counts the events thrown away because the buffer was full.
+func (s *Subscription[T]) Dropped() uint64 {
+	return s.queue.Dropped()
+}
+
+// remove must be called with b.mu held.
+func (b *Bus[T]) remove(s *Subscription[T], topic string) {
+	delete(s.topics, topic)
+	subs := b.topics[topic]
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(b.topics, topic)
+	}
+}
@@ -0,0 +1,107 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/data"
+)
+
+func TestBus(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	// drain returns what the subscription has buffered.
+	drain := func(s *data.Subscription[string]) (events []string) {
+		for {
+			tctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+			e, err := s.Next(tctx)
+			cancel()
+			if err != nil {
+				return
+			}
+			events = append(events, e)
+		}
+	}
+
+	t.Run("TestTopics", func(_ *testing.T) {
+		bus := data.NewBus[string]()
+		room := bus.Subscribe(8, data.Block, "room:1", "global")
+		player := bus.Subscribe(8, data.Block, "player:Bean", "global")
+		a.True(bus.Subscribers("global") == 2)
+
+		a.NoError(bus.Publish(ctx, "room:1", "arrived"))
+		a.NoError(bus.Publish(ctx, "player:Bean", "hello Bean"))
+		a.NoError(bus.Publish(ctx, "global", "everyone"))
+		a.NoError(bus.Publish(ctx, "room:2", "nobody"))
+
+		got := drain(room)
+		a.True(len(got) == 2 && got[0] == "arrived" && got[1] == "everyone")
+		got = drain(player)
+		a.True(len(got) == 2 && got[0] == "hello Bean" && got[1] == "everyone")
+
+		room.Remove("room:1")
+		room.Add("room:2", "room:2")
+		a.True(bus.Subscribers("room:1") == 0 && bus.Subscribers("room:2") == 1)
+		a.NoError(bus.Publish(ctx, "room:1", "left behind"))
+		a.NoError(bus.Publish(ctx, "room:2", "moved"))
+		got = drain(room)
+		a.True(len(got) == 1 && got[0] == "moved")
+	})
+	t.Run("TestUnsubscribe", func(_ *testing.T) {
+		bus := data.NewBus[string]()
+		s := bus.Subscribe(8, data.Block, "global")
+		a.NoError(bus.Publish(ctx, "global", "before"))
+
+		waiting := make(chan error)
+		other := bus.Subscribe(1, data.Block, "room:1")
+		go func() {
+			_, err := other.Next(ctx)
+			waiting <- err
+		}()
+
+		s.Unsubscribe()
+		s.Unsubscribe()
+		other.Unsubscribe()
+		a.True(errors.Is(<-waiting, cross.ErrQueueClosed))
+		a.True(bus.Subscribers("global") == 0)
+		a.NoError(bus.Publish(ctx, "global", "after"))
+
+		// Buffered events are still delivered.
+		e, err := s.Next(ctx)
+		a.NoError(err)
+		a.True(e == "before")
+		_, err = s.Next(ctx)
+		a.True(errors.Is(err, cross.ErrQueueClosed))
+	})
+	t.Run("TestBackpressure", func(_ *testing.T) {
+		bus := data.NewBus[string]()
+		lossy := bus.Subscribe(1, data.DropOldest, "global")
+		strict := bus.Subscribe(1, data.Error, "global")
+		blocking := bus.Subscribe(1, data.Block, "global")
+
+		a.NoError(bus.Publish(ctx, "global", "one"))
+		blocking.Unsubscribe()
+
+		err := bus.Publish(ctx, "global", "two")
+		a.True(errors.Is(err, cross.ErrQueueFull))
+		a.True(lossy.Dropped() == 1)
+		got := drain(lossy)
+		a.True(len(got) == 1 && got[0] == "two")
+		got = drain(strict)
+		a.True(len(got) == 1 && got[0] == "one")
+
+		// A full blocking subscriber holds up the publisher until it catches up.
+		slow := bus.Subscribe(1, data.Block, "room:1")
+		a.NoError(bus.Publish(ctx, "room:1", "first"))
+		tctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		a.True(errors.Is(bus.Publish(tctx, "room:1", "second"), context.DeadlineExceeded))
+		got = drain(slow)
+		a.True(len(got) == 1 && got[0] == "first")
+	})
+}
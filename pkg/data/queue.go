@@ -1,35 +1,187 @@
 package data
 
-import "github.com/Clayal10/enders_game/pkg/cross"
+import (
+	"context"
+	"sync"
 
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+// Overflow is what Enqueue does when the queue is full.
+type Overflow int
+
+const (
+	// Block waits for room.
+	Block Overflow = iota
+	// DropOldest makes room by throwing away the oldest item.
+	DropOldest
+	// DropNewest throws away the item being added.
+	DropNewest
+	// Error returns cross.ErrQueueFull.
+	Error
+)
+
+// Queue is a fixed size FIFO queue that is safe to use from many goroutines.
 type Queue[T any] struct {
-	q       []T
-	r, w, l int
+	overflow Overflow
+
+	mu      sync.Mutex
+	buf     []T
+	head, n int
+	dropped uint64
+	closed  bool
+	// changed is closed and replaced whenever an item is added or removed, or the queue is
+	// closed, waking anyone blocked. It is left alone while nobody waits.
+	changed chan struct{}
+	waiters int
 }
 
+// NewQueue returns a queue holding up to length items that blocks when full.
 func NewQueue[T any](length int) *Queue[T] {
+	return NewQueueWithOverflow[T](length, Block)
+}
+
+func NewQueueWithOverflow[T any](length int, overflow Overflow) *Queue[T] {
 	return &Queue[T]{
-		q: make([]T, length),
-		l: length,
+		overflow: overflow,
+		buf:      make([]T, max(length, 1)),
+		changed:  make(chan struct{}),
 	}
 }
 
-func (q *Queue[T]) Enqueue(obj ...T) {
+// Enqueue adds items in order, handling a full queue as the overflow policy says. With Error,
+// the items before the one that didn't fit are still added.
+func (q *Queue[T]) Enqueue(obj ...T) error {
+	return q.EnqueueContext(context.Background(), obj...)
+}
+
+// EnqueueContext is Enqueue, but gives up waiting for room when ctx is done.
+func (q *Queue[T]) EnqueueContext(ctx context.Context, obj ...T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	for _, o := range obj {
-		q.q[q.w%q.l] = o
-		q.w++
+		for !q.closed && q.n == len(q.buf) && q.overflow == Block {
+			if err := q.wait(ctx); err != nil {
+				return err
+			}
+		}
+		if q.closed {
+			return cross.ErrQueueClosed
+		}
+		if q.n == len(q.buf) {
+			switch q.overflow {
+			case DropNewest:
+				q.dropped++
+				continue
+			case Error:
+				return cross.ErrQueueFull
+			}
+			q.pop()
+			q.dropped++
+		}
+		q.buf[(q.head+q.n)%len(q.buf)] = o
+		q.n++
+		q.notify()
+	}
+	return nil
+}
+
+// Dequeue removes the oldest item, waiting for one if the queue is empty. Once the queue is
+// closed and empty it returns cross.ErrQueueClosed.
+func (q *Queue[T]) Dequeue(ctx context.Context) (result T, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n == 0 {
+		if q.closed {
+			return result, cross.ErrQueueClosed
+		}
+		if err = q.wait(ctx); err != nil {
+			return result, err
+		}
 	}
+	result = q.pop()
+	q.notify()
+	return result, nil
 }
 
-func (q *Queue[T]) Dequeue() (result T, err error) {
-	if q.r == q.w {
+// TryDequeue removes the oldest item without waiting, returning cross.ErrQueueEmpty if there
+// isn't one.
+func (q *Queue[T]) TryDequeue() (result T, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.n == 0 {
 		return result, cross.ErrQueueEmpty
 	}
-	result = q.q[q.r%q.l]
-	q.r++
-	return
+	result = q.pop()
+	q.notify()
+	return result, nil
+}
+
+// Close stops new items from being added and wakes everyone waiting. Items already in the
+// queue can still be dequeued.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.notify()
 }
 
 func (q *Queue[T]) IsEmpty() bool {
-	return q.r == q.w
+	return q.Len() == 0
+}
+
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+func (q *Queue[T]) Cap() int {
+	return len(q.buf)
+}
+
+// Dropped counts the items thrown away by DropOldest and DropNewest.
+func (q *Queue[T]) Dropped() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// pop must be called with q.mu held and the queue not empty.
+func (q *Queue[T]) pop() T {
+	var zero T
+	result := q.buf[q.head]
+	q.buf[q.head] = zero // Don't keep it alive.
+	q.head = (q.head + 1) % len(q.buf)
+	q.n--
+	return result
+}
+
+// notify must be called with q.mu held.
+func (q *Queue[T]) notify() {
+	if q.waiters == 0 {
+		return
+	}
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// wait releases q.mu until the queue changes or ctx is done.
+func (q *Queue[T]) wait(ctx context.Context) error {
+	changed := q.changed
+	q.waiters++
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		q.waiters--
+	}()
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -1,7 +1,11 @@
 package data_test
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Clayal10/enders_game/pkg/assert"
 	"github.com/Clayal10/enders_game/pkg/cross"
@@ -16,11 +20,216 @@ func TestBasicQueuing(t *testing.T) {
 	}
 
 	q := data.NewQueue[*myObj](10)
-	q.Enqueue(&myObj{num: 10})
+	a.NoError(q.Enqueue(&myObj{num: 10}))
 	a.False(q.IsEmpty())
-	obj, err := q.Dequeue()
+	obj, err := q.TryDequeue()
 	a.NoError(err)
 	a.True(obj.num == 10)
-	_, err = q.Dequeue()
+	_, err = q.TryDequeue()
 	a.ErrorIs(err, cross.ErrQueueEmpty)
 }
+
+func TestOverflow(t *testing.T) {
+	a := assert.New(t)
+
+	contents := func(q *data.Queue[int]) (items []int) {
+		for !q.IsEmpty() {
+			item, err := q.TryDequeue()
+			a.NoError(err)
+			items = append(items, item)
+		}
+		return
+	}
+	equal := func(got, want []int) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("TestDropOldest", func(_ *testing.T) {
+		q := data.NewQueueWithOverflow[int](3, data.DropOldest)
+		a.NoError(q.Enqueue(1, 2, 3, 4, 5))
+		a.True(q.Len() == 3 && q.Cap() == 3)
+		a.True(q.Dropped() == 2)
+		a.True(equal(contents(q), []int{3, 4, 5}))
+	})
+	t.Run("TestDropNewest", func(_ *testing.T) {
+		q := data.NewQueueWithOverflow[int](3, data.DropNewest)
+		a.NoError(q.Enqueue(1, 2, 3, 4, 5))
+		a.True(q.Dropped() == 2)
+		a.True(equal(contents(q), []int{1, 2, 3}))
+	})
+	t.Run("TestError", func(_ *testing.T) {
+		q := data.NewQueueWithOverflow[int](3, data.Error)
+		err := q.Enqueue(1, 2, 3, 4, 5)
+		a.True(errors.Is(err, cross.ErrQueueFull))
+		a.True(q.Dropped() == 0)
+		a.True(equal(contents(q), []int{1, 2, 3}))
+	})
+	t.Run("TestBlock", func(_ *testing.T) {
+		q := data.NewQueue[int](2)
+		a.NoError(q.Enqueue(1, 2))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := q.EnqueueContext(ctx, 3)
+		a.True(errors.Is(err, context.DeadlineExceeded))
+
+		done := make(chan error)
+		go func() { done <- q.Enqueue(3) }()
+		item, err := q.Dequeue(context.Background())
+		a.NoError(err)
+		a.True(item == 1)
+		a.NoError(<-done)
+		a.True(equal(contents(q), []int{2, 3}))
+	})
+	t.Run("TestWrapAround", func(_ *testing.T) {
+		q := data.NewQueue[int](3)
+		for i := range 10 {
+			a.NoError(q.Enqueue(i))
+			item, err := q.TryDequeue()
+			a.NoError(err)
+			a.True(item == i)
+		}
+	})
+}
+
+func TestBlockingDequeue(t *testing.T) {
+	a := assert.New(t)
+
+	q := data.NewQueue[string](4)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = q.Enqueue("late")
+	}()
+	item, err := q.Dequeue(context.Background())
+	a.NoError(err)
+	a.True(item == "late")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = q.Dequeue(ctx)
+	a.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClose(t *testing.T) {
+	a := assert.New(t)
+
+	q := data.NewQueue[int](1)
+	a.NoError(q.Enqueue(1))
+	blocked := make(chan error)
+	go func() { blocked <- q.Enqueue(2) }()
+	time.Sleep(5 * time.Millisecond)
+	q.Close()
+	q.Close()
+	a.True(errors.Is(<-blocked, cross.ErrQueueClosed))
+	a.True(errors.Is(q.Enqueue(3), cross.ErrQueueClosed))
+
+	// What was queued is still there.
+	item, err := q.Dequeue(context.Background())
+	a.NoError(err)
+	a.True(item == 1)
+	_, err = q.Dequeue(context.Background())
+	a.True(errors.Is(err, cross.ErrQueueClosed))
+
+	empty := data.NewQueue[int](1)
+	waiting := make(chan error)
+	go func() {
+		_, err := empty.Dequeue(context.Background())
+		waiting <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	empty.Close()
+	a.True(errors.Is(<-waiting, cross.ErrQueueClosed))
+}
+
+func TestConcurrentQueue(t *testing.T) {
+	a := assert.New(t)
+
+	const producers, each = 4, 1000
+	q := data.NewQueue[int](16)
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range each {
+				a.NoError(q.Enqueue(p*each + i))
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		q.Close()
+	}()
+
+	seen := make([]bool, producers*each)
+	last := make([]int, producers)
+	for p := range last {
+		last[p] = -1
+	}
+	for {
+		item, err := q.Dequeue(context.Background())
+		if errors.Is(err, cross.ErrQueueClosed) {
+			break
+		}
+		a.NoError(err)
+		a.False(seen[item])
+		seen[item] = true
+		// Each producer's items come out in order.
+		p, i := item/each, item%each
+		a.True(i > last[p])
+		last[p] = i
+	}
+	for _, ok := range seen {
+		a.True(ok)
+	}
+}
+
+const benchmarkCap = 128
+
+func BenchmarkQueue(b *testing.B) {
+	q := data.NewQueue[int](benchmarkCap)
+	go func() {
+		for i := range b.N {
+			_ = q.Enqueue(i)
+		}
+	}()
+	for range b.N {
+		_, _ = q.Dequeue(context.Background())
+	}
+}
+
+func BenchmarkChannel(b *testing.B) {
+	ch := make(chan int, benchmarkCap)
+	go func() {
+		for i := range b.N {
+			ch <- i
+		}
+	}()
+	for range b.N {
+		<-ch
+	}
+}
+
+func BenchmarkQueueUncontended(b *testing.B) {
+	q := data.NewQueue[int](benchmarkCap)
+	for i := range b.N {
+		_ = q.Enqueue(i)
+		_, _ = q.TryDequeue()
+	}
+}
+
+func BenchmarkChannelUncontended(b *testing.B) {
+	ch := make(chan int, benchmarkCap)
+	for i := range b.N {
+		ch <- i
+		<-ch
+	}
+}
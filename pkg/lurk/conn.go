@@ -0,0 +1,220 @@
+package lurk
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+// Conn wraps a net.Conn to send and receive whole LURK messages. Send may be called from any
+// number of goroutines, each message is written in one piece so frames are never interleaved.
+// Receive should only be called by a single reader at a time.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	writeMu sync.Mutex
+	// wbuf is reused to marshal messages. Only use it while holding writeMu.
+	wbuf []byte
+
+	readMu sync.Mutex
+	// header is reused to read the fixed part of a message. Only use it while holding readMu.
+	header [messageLength]byte
+
+	readTimeout  atomic.Int64
+	writeTimeout atomic.Int64
+
+	bytesSent        atomic.Uint64
+	bytesReceived    atomic.Uint64
+	messagesSent     atomic.Uint64
+	messagesReceived atomic.Uint64
+}
+
+// ConnStats is a snapshot of the traffic on a Conn.
+type ConnStats struct {
+	BytesSent        uint64 `json:"bytesSent"`
+	BytesReceived    uint64 `json:"bytesReceived"`
+	MessagesSent     uint64 `json:"messagesSent"`
+	MessagesReceived uint64 `json:"messagesReceived"`
+}
+
+// Once the type of a message has been read, the rest of it must arrive within frameTimeout.
+const frameTimeout = 1000 * time.Millisecond
+
+// NewConn returns a Conn with no timeouts set.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+	}
+}
+
+// SetReadTimeout sets how long each call to Receive will wait for a message to start. Zero
+// waits forever.
+func (c *Conn) SetReadTimeout(d time.Duration) {
+	c.readTimeout.Store(int64(d))
+}
+
+// SetWriteTimeout sets how long each call to Send may take. Zero waits forever.
+func (c *Conn) SetWriteTimeout(d time.Duration) {
+	c.writeTimeout.Store(int64(d))
+}
+
+// Send marshals lm and writes it to the connection.
+func (c *Conn) Send(lm LurkMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.wbuf = AppendMarshal(c.wbuf[:0], lm)
+	if len(c.wbuf) == 0 {
+		return cross.ErrInvalidMessageType
+	}
+	return c.write(c.wbuf, 1)
+}
+
+// SendEncoded writes one or more messages that have already been marshaled. This lets the
+// same bytes be sent to many connections.
+func (c *Conn) SendEncoded(frames []byte) error {
+	messages := 0
+	for rest := frames; len(rest) > 0; messages++ {
+		n, err := FrameLength(rest)
+		if err != nil {
+			return err
+		}
+		if n > len(rest) {
+			return cross.ErrFrameTooSmall
+		}
+		rest = rest[n:]
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.write(frames, messages)
+}
+
+// SendError is a shortcut for sending an ERROR message.
+func (c *Conn) SendError(code cross.ErrCode, msg string) error {
+	return c.Send(&Error{
+		Type:       TypeError,
+		ErrCode:    code,
+		ErrMessage: msg,
+	})
+}
+
+// SendAccept is a shortcut for sending an ACCEPT message.
+func (c *Conn) SendAccept(action MessageType) error {
+	return c.Send(&Accept{
+		Type:   TypeAccept,
+		Action: action,
+	})
+}
+
+// must hold writeMu.
+func (c *Conn) write(ba []byte, messages int) error {
+	var deadline time.Time
+	if d := time.Duration(c.writeTimeout.Load()); d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	n, err := c.conn.Write(ba)
+	c.bytesSent.Add(uint64(n))
+	if err != nil {
+		return err
+	}
+	c.messagesSent.Add(uint64(messages))
+	return nil
+}
+
+// Receive reads the next message from the connection. A message of an unknown type only
+// consumes its first byte, so the caller may choose to keep reading. If the read timeout
+// passes before a message starts, os.ErrDeadlineExceeded is returned and nothing is consumed.
+func (c *Conn) Receive() (LurkMessage, error) {
+	ba, err := c.ReceiveFrame()
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(ba)
+}
+
+// ReceiveFrame is the same as Receive without unmarshaling the message. The returned slice
+// belongs to the caller.
+func (c *Conn) ReceiveFrame() ([]byte, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	defer func() { _ = c.conn.SetReadDeadline(time.Time{}) }()
+
+	var deadline time.Time
+	if d := time.Duration(c.readTimeout.Load()); d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	first, err := c.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	c.bytesReceived.Add(1)
+	if err = validate([]byte{first}); err != nil {
+		return nil, err
+	}
+
+	if err = c.conn.SetReadDeadline(time.Now().Add(frameTimeout)); err != nil {
+		return nil, err
+	}
+
+	// Read the fixed part of the message, then whatever its length says follows.
+	header := c.header[:headerLength(MessageType(first))]
+	header[0] = first
+	if err = c.readFull(header[1:]); err != nil {
+		return nil, err
+	}
+
+	total, err := FrameLength(header)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, total)
+	copy(frame, header)
+	if err = c.readFull(frame[len(header):]); err != nil {
+		return nil, err
+	}
+	c.messagesReceived.Add(1)
+	return frame, nil
+}
+
+func (c *Conn) readFull(ba []byte) error {
+	n, err := io.ReadFull(c.r, ba)
+	c.bytesReceived.Add(uint64(n))
+	return err
+}
+
+// Stats returns the number of bytes and messages which have gone through the connection.
+func (c *Conn) Stats() ConnStats {
+	return ConnStats{
+		BytesSent:        c.bytesSent.Load(),
+		BytesReceived:    c.bytesReceived.Load(),
+		MessagesSent:     c.messagesSent.Load(),
+		MessagesReceived: c.messagesReceived.Load(),
+	}
+}
+
+// NetConn returns the underlying connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
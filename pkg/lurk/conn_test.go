@@ -0,0 +1,111 @@
+package lurk_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+func newConnPair(t *testing.T) (*lurk.Conn, *lurk.Conn) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return lurk.NewConn(client), lurk.NewConn(server)
+}
+
+func TestConn(t *testing.T) {
+	a := assert.New(t)
+	t.Run("TestConcurrentSends", func(_ *testing.T) {
+		client, server := newConnPair(t)
+		const senders = 8
+
+		var wg sync.WaitGroup
+		for range senders {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, lm := range everyMessage {
+					a.NoError(server.Send(lm))
+				}
+			}()
+		}
+
+		received := 0
+		for received < senders*len(everyMessage) {
+			lm, err := client.Receive()
+			a.NoError(err)
+			a.NotNil(lm)
+			received++
+		}
+		wg.Wait()
+
+		sent, got := server.Stats(), client.Stats()
+		a.True(sent.MessagesSent == uint64(received))
+		a.True(got.MessagesReceived == uint64(received))
+		a.True(sent.BytesSent == got.BytesReceived)
+	})
+	t.Run("TestSendEncoded", func(_ *testing.T) {
+		client, server := newConnPair(t)
+		var frames []byte
+		for _, lm := range everyMessage {
+			frames = lurk.AppendMarshal(frames, lm)
+		}
+		a.NoError(server.SendEncoded(frames))
+		a.True(server.Stats().MessagesSent == uint64(len(everyMessage)))
+
+		for _, expected := range everyMessage {
+			ba, err := client.ReceiveFrame()
+			a.NoError(err)
+			a.EqualSlice(ba, lurk.Marshal(expected))
+		}
+
+		// Partial frames are refused.
+		a.ErrorIs(cross.ErrFrameTooSmall, server.SendEncoded(frames[:len(frames)-1]))
+	})
+	t.Run("TestReadTimeout", func(_ *testing.T) {
+		client, server := newConnPair(t)
+		client.SetReadTimeout(20 * time.Millisecond)
+		_, err := client.Receive()
+		a.True(errors.Is(err, os.ErrDeadlineExceeded))
+
+		// The connection is still usable after a timeout.
+		a.NoError(server.SendAccept(lurk.TypeStart))
+		lm, err := client.Receive()
+		a.NoError(err)
+		a.True(lm.(*lurk.Accept).Action == lurk.TypeStart)
+	})
+	t.Run("TestInvalidType", func(_ *testing.T) {
+		client, server := newConnPair(t)
+		_, err := server.NetConn().Write([]byte{0xff})
+		a.NoError(err)
+		a.NoError(server.SendError(cross.NotReady, "not yet"))
+
+		_, err = client.Receive()
+		a.ErrorIs(cross.ErrInvalidMessageType, err)
+		lm, err := client.Receive()
+		a.NoError(err)
+		a.True(lm.(*lurk.Error).ErrCode == cross.NotReady)
+	})
+}
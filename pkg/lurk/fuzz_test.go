@@ -0,0 +1,187 @@
+package lurk_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"net"
+	"testing"
+	"testing/quick"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// The seed corpus lives in testdata/fuzz. These add one valid frame of every type on top of it.
+func addSeeds(f *testing.F) {
+	for _, lm := range everyMessage {
+		f.Add(lurk.Marshal(lm))
+	}
+	f.Add(sampleMessage)
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		lm, err := lurk.Unmarshal(data)
+		if err != nil {
+			return
+		}
+		checkStable(t, lm)
+	})
+}
+
+func FuzzGetVariableLength(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		varLen, err := lurk.GetVariableLength(data)
+		if err != nil {
+			return
+		}
+		if varLen < -1 || varLen > 0xffff {
+			t.Fatalf("variable length %d out of range", varLen)
+		}
+
+		n, err := lurk.FrameLength(data)
+		if err != nil {
+			if !errors.Is(err, cross.ErrFrameTooSmall) {
+				t.Fatalf("%v: unexpected error for a valid type", err)
+			}
+			return
+		}
+		if n < 1 {
+			t.Fatalf("frame length %d", n)
+		}
+	})
+}
+
+func FuzzReadSingleMessage(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		server, client := net.Pipe()
+		defer func() { _ = server.Close() }()
+		go func() {
+			_, _ = client.Write(data)
+			_ = client.Close()
+		}()
+
+		read := 0
+		for read < len(data) {
+			buffer, n, err := lurk.ReadSingleMessage(server)
+			if err != nil {
+				return
+			}
+			if n != len(buffer) {
+				t.Fatalf("reported %d bytes, returned %d", n, len(buffer))
+			}
+			if !bytes.Equal(buffer, data[read:read+n]) {
+				t.Fatalf("message at %d does not match the bytes sent", read)
+			}
+			read += n
+		}
+	})
+}
+
+// checkStable makes sure a message survives Marshal -> Unmarshal -> Marshal unchanged.
+func checkStable(t *testing.T, lm lurk.LurkMessage) {
+	t.Helper()
+	first := lurk.Marshal(lm)
+	if first == nil {
+		t.Fatalf("%v could not be marshaled", lm.GetType())
+	}
+	again, err := lurk.Unmarshal(first)
+	if err != nil {
+		t.Fatalf("%v: could not unmarshal marshaled %v", err, lm.GetType())
+	}
+	if second := lurk.Marshal(again); !bytes.Equal(first, second) {
+		t.Fatalf("%v is not stable:\n% x\n% x", lm.GetType(), first, second)
+	}
+}
+
+// TestMarshalStability generates random messages of every type and checks that
+// Marshal(Unmarshal(Marshal(m))) is the same as Marshal(m).
+func TestMarshalStability(t *testing.T) {
+	cfg := &quick.Config{
+		MaxCount: 500,
+		Rand:     rand.New(rand.NewSource(435)),
+	}
+	generators := map[lurk.MessageType]any{
+		lurk.TypeMessage: func(recipient, sender, text string, narration bool) bool {
+			return stable(t, &lurk.Message{Recipient: recipient, Sender: sender, Text: text, Narration: narration})
+		},
+		lurk.TypeChangeRoom: func(room uint16) bool {
+			return stable(t, &lurk.ChangeRoom{RoomNumber: room})
+		},
+		lurk.TypeFight: func() bool {
+			return stable(t, &lurk.Fight{})
+		},
+		lurk.TypePVPFight: func(target string) bool {
+			return stable(t, &lurk.PVPFight{TargetName: target})
+		},
+		lurk.TypeLoot: func(target string) bool {
+			return stable(t, &lurk.Loot{TargetName: target})
+		},
+		lurk.TypeStart: func() bool {
+			return stable(t, &lurk.Start{})
+		},
+		lurk.TypeError: func(code uint8, msg string) bool {
+			return stable(t, &lurk.Error{ErrCode: cross.ErrCode(code % uint8(cross.NoPVP+1)), ErrMessage: msg})
+		},
+		lurk.TypeAccept: func(action uint8) bool {
+			return stable(t, &lurk.Accept{Action: lurk.MessageType(action)})
+		},
+		lurk.TypeRoom: func(room uint16, name, desc string) bool {
+			return stable(t, &lurk.Room{RoomNumber: room, RoomName: name, RoomDesc: desc})
+		},
+		lurk.TypeCharacter: func(name string, flags uint8, attack, defense, regen uint16, health int16, gold, room uint16, desc string) bool {
+			return stable(t, &lurk.Character{
+				Name: name,
+				Flags: map[string]bool{
+					lurk.Alive:      flags&1 != 0,
+					lurk.JoinBattle: flags&2 != 0,
+					lurk.Monster:    flags&4 != 0,
+					lurk.Started:    flags&8 != 0,
+					lurk.Ready:      flags&16 != 0,
+				},
+				Attack:     attack,
+				Defense:    defense,
+				Regen:      regen,
+				Health:     health,
+				Gold:       gold,
+				RoomNum:    room,
+				PlayerDesc: desc,
+			})
+		},
+		lurk.TypeGame: func(initial, limit uint16, desc string) bool {
+			return stable(t, &lurk.Game{InitialPoints: initial, StatLimit: limit, GameDesc: desc})
+		},
+		lurk.TypeLeave: func() bool {
+			return stable(t, &lurk.Leave{})
+		},
+		lurk.TypeConnection: func(room uint16, name, desc string) bool {
+			return stable(t, &lurk.Connection{RoomNumber: room, RoomName: name, RoomDesc: desc})
+		},
+		lurk.TypeVersion: func(major, minor uint8, extensions [][]byte) bool {
+			return stable(t, &lurk.Version{Major: major, Minor: minor, Extensions: extensions})
+		},
+	}
+
+	for mt := lurk.TypeMessage; mt <= lurk.TypeVersion; mt++ {
+		t.Run(mt.String(), func(t *testing.T) {
+			if err := quick.Check(generators[mt], cfg); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func stable(t *testing.T, lm lurk.LurkMessage) bool {
+	t.Helper()
+	first := lurk.Marshal(lm)
+	again, err := lurk.Unmarshal(first)
+	if err != nil {
+		t.Logf("%v: could not unmarshal %v", err, lm.GetType())
+		return false
+	}
+	return bytes.Equal(first, lurk.Marshal(again))
+}
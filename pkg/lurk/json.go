@@ -0,0 +1,344 @@
+package lurk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/Clayal10/enders_game/pkg/cross"
+)
+
+// Every message is represented in JSON as an object with a "type" field holding the spec
+// name of the message, such as {"type": "CHANGEROOM", "roomNumber": 2}. Use DecodeJSON when
+// the type of the message isn't known ahead of time.
+
+// MarshalText allows the message type to be used as the JSON "type" discriminator. Types
+// without a spec name, which an ACCEPT can still carry, are written as their number.
+func (mt MessageType) MarshalText() ([]byte, error) {
+	if _, ok := typeNames[mt]; !ok {
+		return []byte(strconv.Itoa(int(mt))), nil
+	}
+	return []byte(mt.String()), nil
+}
+
+// UnmarshalText accepts either the spec name of the type or its number. DecodeJSON still
+// refuses numbers that aren't a message.
+func (mt *MessageType) UnmarshalText(text []byte) error {
+	for t, name := range typeNames {
+		if name == string(text) {
+			*mt = t
+			return nil
+		}
+	}
+	n, err := strconv.ParseUint(string(text), 10, 8)
+	if err != nil {
+		return fmt.Errorf("%w: %q", cross.ErrInvalidMessageType, text)
+	}
+	*mt = MessageType(n)
+	return nil
+}
+
+// DecodeJSON will return the concrete LurkMessage described by the JSON object in data.
+func DecodeJSON(data []byte) (LurkMessage, error) {
+	head := struct {
+		Type MessageType `json:"type"`
+	}{}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	var lm LurkMessage
+	switch head.Type {
+	case TypeMessage:
+		lm = &Message{}
+	case TypeChangeRoom:
+		lm = &ChangeRoom{}
+	case TypeFight:
+		lm = &Fight{}
+	case TypePVPFight:
+		lm = &PVPFight{}
+	case TypeLoot:
+		lm = &Loot{}
+	case TypeStart:
+		lm = &Start{}
+	case TypeError:
+		lm = &Error{}
+	case TypeAccept:
+		lm = &Accept{}
+	case TypeRoom:
+		lm = &Room{}
+	case TypeCharacter:
+		lm = &Character{}
+	case TypeGame:
+		lm = &Game{}
+	case TypeLeave:
+		lm = &Leave{}
+	case TypeConnection:
+		lm = &Connection{}
+	case TypeVersion:
+		lm = &Version{}
+	default:
+		return nil, cross.ErrInvalidMessageType
+	}
+
+	if err := json.Unmarshal(data, lm); err != nil {
+		return nil, err
+	}
+	return lm, nil
+}
+
+// checkJSONType makes sure data is either missing a "type" or has the one expected.
+func checkJSONType(data []byte, expected MessageType) error {
+	head := struct {
+		Type *MessageType `json:"type"`
+	}{}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	if head.Type != nil && *head.Type != expected {
+		return fmt.Errorf("%w: got %v, expected %v", cross.ErrInvalidMessageType, *head.Type, expected)
+	}
+	return nil
+}
+
+func (m *Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	v := message(*m)
+	v.Type = TypeMessage
+	return json.Marshal(&v)
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	if err := checkJSONType(data, TypeMessage); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*message)(m)); err != nil {
+		return err
+	}
+	m.Type = TypeMessage
+	return nil
+}
+
+func (cr *ChangeRoom) MarshalJSON() ([]byte, error) {
+	type changeRoom ChangeRoom
+	v := changeRoom(*cr)
+	v.Type = TypeChangeRoom
+	return json.Marshal(&v)
+}
+
+func (cr *ChangeRoom) UnmarshalJSON(data []byte) error {
+	type changeRoom ChangeRoom
+	if err := checkJSONType(data, TypeChangeRoom); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*changeRoom)(cr)); err != nil {
+		return err
+	}
+	cr.Type = TypeChangeRoom
+	return nil
+}
+
+func (f *Fight) MarshalJSON() ([]byte, error) {
+	type fight Fight
+	return json.Marshal(&fight{Type: TypeFight})
+}
+
+func (f *Fight) UnmarshalJSON(data []byte) error {
+	f.Type = TypeFight
+	return checkJSONType(data, TypeFight)
+}
+
+func (pvp *PVPFight) MarshalJSON() ([]byte, error) {
+	type pvpFight PVPFight
+	v := pvpFight(*pvp)
+	v.Type = TypePVPFight
+	return json.Marshal(&v)
+}
+
+func (pvp *PVPFight) UnmarshalJSON(data []byte) error {
+	type pvpFight PVPFight
+	if err := checkJSONType(data, TypePVPFight); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*pvpFight)(pvp)); err != nil {
+		return err
+	}
+	pvp.Type = TypePVPFight
+	return nil
+}
+
+func (l *Loot) MarshalJSON() ([]byte, error) {
+	type loot Loot
+	v := loot(*l)
+	v.Type = TypeLoot
+	return json.Marshal(&v)
+}
+
+func (l *Loot) UnmarshalJSON(data []byte) error {
+	type loot Loot
+	if err := checkJSONType(data, TypeLoot); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*loot)(l)); err != nil {
+		return err
+	}
+	l.Type = TypeLoot
+	return nil
+}
+
+func (s *Start) MarshalJSON() ([]byte, error) {
+	type start Start
+	return json.Marshal(&start{Type: TypeStart})
+}
+
+func (s *Start) UnmarshalJSON(data []byte) error {
+	s.Type = TypeStart
+	return checkJSONType(data, TypeStart)
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	type lurkError Error
+	v := lurkError(*e)
+	v.Type = TypeError
+	return json.Marshal(&v)
+}
+
+func (e *Error) UnmarshalJSON(data []byte) error {
+	type lurkError Error
+	if err := checkJSONType(data, TypeError); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*lurkError)(e)); err != nil {
+		return err
+	}
+	e.Type = TypeError
+	return nil
+}
+
+func (a *Accept) MarshalJSON() ([]byte, error) {
+	type accept Accept
+	v := accept(*a)
+	v.Type = TypeAccept
+	return json.Marshal(&v)
+}
+
+func (a *Accept) UnmarshalJSON(data []byte) error {
+	type accept Accept
+	if err := checkJSONType(data, TypeAccept); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*accept)(a)); err != nil {
+		return err
+	}
+	a.Type = TypeAccept
+	return nil
+}
+
+func (r *Room) MarshalJSON() ([]byte, error) {
+	type room Room
+	v := room(*r)
+	v.Type = TypeRoom
+	return json.Marshal(&v)
+}
+
+func (r *Room) UnmarshalJSON(data []byte) error {
+	type room Room
+	if err := checkJSONType(data, TypeRoom); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*room)(r)); err != nil {
+		return err
+	}
+	r.Type = TypeRoom
+	return nil
+}
+
+func (c *Character) MarshalJSON() ([]byte, error) {
+	type character Character
+	v := character(*c)
+	v.Type = TypeCharacter
+	return json.Marshal(&v)
+}
+
+func (c *Character) UnmarshalJSON(data []byte) error {
+	type character Character
+	if err := checkJSONType(data, TypeCharacter); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*character)(c)); err != nil {
+		return err
+	}
+	c.Type = TypeCharacter
+	if c.Flags == nil {
+		c.Flags = map[string]bool{}
+	}
+	return nil
+}
+
+func (g *Game) MarshalJSON() ([]byte, error) {
+	type game Game
+	v := game(*g)
+	v.Type = TypeGame
+	return json.Marshal(&v)
+}
+
+func (g *Game) UnmarshalJSON(data []byte) error {
+	type game Game
+	if err := checkJSONType(data, TypeGame); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*game)(g)); err != nil {
+		return err
+	}
+	g.Type = TypeGame
+	return nil
+}
+
+func (l *Leave) MarshalJSON() ([]byte, error) {
+	type leave Leave
+	return json.Marshal(&leave{Type: TypeLeave})
+}
+
+func (l *Leave) UnmarshalJSON(data []byte) error {
+	l.Type = TypeLeave
+	return checkJSONType(data, TypeLeave)
+}
+
+func (c *Connection) MarshalJSON() ([]byte, error) {
+	type connection Connection
+	v := connection(*c)
+	v.Type = TypeConnection
+	return json.Marshal(&v)
+}
+
+func (c *Connection) UnmarshalJSON(data []byte) error {
+	type connection Connection
+	if err := checkJSONType(data, TypeConnection); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*connection)(c)); err != nil {
+		return err
+	}
+	c.Type = TypeConnection
+	return nil
+}
+
+func (v *Version) MarshalJSON() ([]byte, error) {
+	type version Version
+	cp := version(*v)
+	cp.Type = TypeVersion
+	return json.Marshal(&cp)
+}
+
+func (v *Version) UnmarshalJSON(data []byte) error {
+	type version Version
+	if err := checkJSONType(data, TypeVersion); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*version)(v)); err != nil {
+		return err
+	}
+	v.Type = TypeVersion
+	return nil
+}
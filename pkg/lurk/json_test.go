@@ -0,0 +1,109 @@
+package lurk_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+	"github.com/Clayal10/enders_game/pkg/cross"
+	"github.com/Clayal10/enders_game/pkg/lurk"
+)
+
+// One of every message type, without the Type field set.
+var everyMessage = []lurk.LurkMessage{
+	&lurk.Message{Recipient: "Raymond", Sender: "Clay", Text: "Hello", Narration: true},
+	&lurk.ChangeRoom{RoomNumber: 2},
+	&lurk.Fight{},
+	&lurk.PVPFight{TargetName: "Bean"},
+	&lurk.Loot{TargetName: "Petra"},
+	&lurk.Start{},
+	&lurk.Error{ErrCode: cross.StatError, ErrMessage: "Too strong"},
+	&lurk.Accept{Action: lurk.TypeCharacter},
+	&lurk.Room{RoomNumber: 1, RoomName: "Battle School", RoomDesc: "A school"},
+	&lurk.Character{
+		Name:       "Clay",
+		Flags:      map[string]bool{lurk.Alive: true, lurk.Ready: true},
+		Attack:     1,
+		Defense:    2,
+		Regen:      3,
+		Health:     -4,
+		Gold:       5,
+		RoomNum:    6,
+		PlayerDesc: "This is Clay",
+	},
+	&lurk.Game{InitialPoints: 100, StatLimit: 65535, GameDesc: "A game"},
+	&lurk.Leave{},
+	&lurk.Connection{RoomNumber: 3, RoomName: "The Game Room", RoomDesc: "Games"},
+	&lurk.Version{Major: 2, Minor: 3, Extensions: [][]byte{{1, 2}}},
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	a := assert.New(t)
+	for _, lm := range everyMessage {
+		t.Run(lm.GetType().String(), func(_ *testing.T) {
+			ba, err := json.Marshal(lm)
+			a.NoError(err)
+			a.True(strings.Contains(string(ba), `"type":"`+lm.GetType().String()+`"`))
+
+			decoded, err := lurk.DecodeJSON(ba)
+			a.NoError(err)
+			a.True(decoded.GetType() == lm.GetType())
+			// The binary form is the easiest way to compare every field.
+			a.EqualSlice(lurk.Marshal(decoded), lurk.Marshal(lm))
+		})
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	a := assert.New(t)
+	t.Run("TestUnknownType", func(_ *testing.T) {
+		_, err := lurk.DecodeJSON([]byte(`{"type": "DANCE"}`))
+		a.True(errors.Is(err, cross.ErrInvalidMessageType))
+		_, err = lurk.DecodeJSON([]byte(`{"roomNumber": 2}`))
+		a.True(errors.Is(err, cross.ErrInvalidMessageType))
+	})
+	t.Run("TestNumericType", func(_ *testing.T) {
+		lm, err := lurk.DecodeJSON([]byte(`{"type": "2", "roomNumber": 4}`))
+		a.NoError(err)
+		cr, ok := lm.(*lurk.ChangeRoom)
+		a.True(ok)
+		a.True(cr.RoomNumber == 4)
+	})
+	t.Run("TestUnnamedType", func(_ *testing.T) {
+		// An ACCEPT can carry any byte, which has to survive JSON too.
+		ba, err := json.Marshal(&lurk.Accept{Action: 0})
+		a.NoError(err)
+		a.True(strings.Contains(string(ba), `"action":"0"`))
+		accept := &lurk.Accept{}
+		a.NoError(json.Unmarshal(ba, accept))
+		a.True(accept.Action == 0 && accept.Type == lurk.TypeAccept)
+		_, err = lurk.DecodeJSON([]byte(`{"type": "0"}`))
+		a.True(errors.Is(err, cross.ErrInvalidMessageType))
+	})
+	t.Run("TestMismatchedType", func(_ *testing.T) {
+		loot := &lurk.Loot{}
+		err := json.Unmarshal([]byte(`{"type": "PVPFIGHT", "target": "Bean"}`), loot)
+		a.True(errors.Is(err, cross.ErrInvalidMessageType))
+	})
+	t.Run("TestMissingType", func(_ *testing.T) {
+		loot := &lurk.Loot{}
+		a.NoError(json.Unmarshal([]byte(`{"target": "Bean"}`), loot))
+		a.True(loot.Type == lurk.TypeLoot)
+		a.True(loot.TargetName == "Bean")
+	})
+}
+
+func TestStringForms(t *testing.T) {
+	a := assert.New(t)
+	for _, lm := range everyMessage {
+		s, ok := lm.(interface{ String() string })
+		a.True(ok)
+		a.True(strings.HasPrefix(s.String(), lm.GetType().String()))
+		a.False(strings.Contains(s.String(), "\n"))
+	}
+	a.True(everyMessage[9].(*lurk.Character).String() ==
+		`CHARACTER "Clay" [Alive, Ready] attack 1 defense 2 regen 3 health -4 gold 5 room 6: "This is Clay"`)
+	a.True(lurk.MessageType(20).String() == "UNKNOWN(20)")
+}
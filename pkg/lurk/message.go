@@ -2,6 +2,7 @@ package lurk
 
 import (
 	"encoding/binary"
+	"fmt"
 	"net"
 	"time"
 
@@ -28,6 +29,31 @@ const (
 	TypeVersion    MessageType = 14
 )
 
+var typeNames = map[MessageType]string{
+	TypeMessage:    "MESSAGE",
+	TypeChangeRoom: "CHANGEROOM",
+	TypeFight:      "FIGHT",
+	TypePVPFight:   "PVPFIGHT",
+	TypeLoot:       "LOOT",
+	TypeStart:      "START",
+	TypeError:      "ERROR",
+	TypeAccept:     "ACCEPT",
+	TypeRoom:       "ROOM",
+	TypeCharacter:  "CHARACTER",
+	TypeGame:       "GAME",
+	TypeLeave:      "LEAVE",
+	TypeConnection: "CONNECTION",
+	TypeVersion:    "VERSION",
+}
+
+// String returns the name the LURK spec uses for the message type.
+func (mt MessageType) String() string {
+	if name, ok := typeNames[mt]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", byte(mt))
+}
+
 // LengthOffset is a key that will tell you how many bytes you will need to read per message
 // type to have a full enough message. Fields not denoted with '// X' have fixed length messages
 // and the returned value is good. Otherwise, send it through the 'GetVariableRate' function
@@ -66,6 +92,13 @@ const (
 	Ready      = "Ready"
 )
 
+// Chat channels. A MESSAGE addressed to one of these goes to everyone in the sender's room or
+// on the server, on servers that support them.
+const (
+	RoomChannel   = "Room"
+	GlobalChannel = "Everyone"
+)
+
 // Those using this library will need to use this function on the returned interface
 // to know which type will need to be used for assertion.
 type LurkMessage interface {
@@ -117,6 +150,37 @@ func GetVariableLength(data []byte) (int, error) {
 	}
 }
 
+// headerLength returns the length of the fixed part of a message of type mt.
+func headerLength(mt MessageType) int {
+	if mt == TypeMessage {
+		return messageLength
+	}
+	return LengthOffset[mt]
+}
+
+// FrameLength will return the total byte length of the first message in data, including
+// any variable length text. If data does not yet hold enough of the message to know its
+// length, cross.ErrFrameTooSmall is returned.
+func FrameLength(data []byte) (int, error) {
+	if err := validate(data); err != nil {
+		return 0, err
+	}
+
+	header := headerLength(MessageType(data[0]))
+	if len(data) < header {
+		return 0, cross.ErrFrameTooSmall
+	}
+
+	varLen, err := GetVariableLength(data)
+	if err != nil {
+		return 0, err
+	}
+	if varLen == -1 {
+		return header, nil
+	}
+	return header + varLen, nil
+}
+
 // Unmarshal takes a slice of bytes and returns a LurkMessage interface object. The
 // LurkMessage then needs to be type asserted based on the type returned from the
 // 'GetType()' function.
@@ -161,59 +225,96 @@ func Unmarshal(data []byte) (LurkMessage, error) {
 // Marshal Will take any LurkMessage object and return a byte array
 // ready for messaging.
 func Marshal(lm LurkMessage) []byte {
+	ba := AppendMarshal(make([]byte, 0, encodedLen(lm)), lm)
+	if len(ba) == 0 {
+		return nil
+	}
+	return ba
+}
+
+// AppendMarshal will append the encoded LurkMessage to dst and return the extended slice.
+// Reusing dst between calls allows messages to be encoded without allocating. If lm is not
+// one of the types in this package dst is returned unchanged.
+func AppendMarshal(dst []byte, lm LurkMessage) []byte {
 	switch lm.GetType() {
 	case TypeMessage:
 		if msg, ok := lm.(*Message); ok {
-			return marshalMessage(msg)
+			return appendMessage(dst, msg)
 		}
 	case TypeChangeRoom:
 		if cr, ok := lm.(*ChangeRoom); ok {
-			return marshalChangeRoom(cr)
+			return appendChangeRoom(dst, cr)
 		}
 	case TypeFight:
-		return []byte{0x03}
+		return append(dst, byte(TypeFight))
 	case TypePVPFight:
 		if pvp, ok := lm.(*PVPFight); ok {
-			return marshalPVP(pvp)
+			return appendPVP(dst, pvp)
 		}
 	case TypeLoot:
 		if l, ok := lm.(*Loot); ok {
-			return marshalLoot(l)
+			return appendLoot(dst, l)
 		}
 	case TypeStart:
-		return []byte{0x06}
+		return append(dst, byte(TypeStart))
 	case TypeError:
 		if e, ok := lm.(*Error); ok {
-			return marshalError(e)
+			return appendError(dst, e)
 		}
 	case TypeAccept:
 		if a, ok := lm.(*Accept); ok {
-			return marshalAccept(a)
+			return appendAccept(dst, a)
 		}
 	case TypeRoom:
 		if room, ok := lm.(*Room); ok {
-			return marshalRoom(room)
+			return appendRoom(dst, room)
 		}
 	case TypeCharacter:
 		if char, ok := lm.(*Character); ok {
-			return marshalCharacter(char)
+			return appendCharacter(dst, char)
 		}
 	case TypeGame:
 		if game, ok := lm.(*Game); ok {
-			return marshalGame(game)
+			return appendGame(dst, game)
 		}
 	case TypeLeave:
-		return []byte{0xc}
+		return append(dst, byte(TypeLeave))
 	case TypeConnection:
 		if conn, ok := lm.(*Connection); ok {
-			return marshalConnection(conn)
+			return appendConnection(dst, conn)
 		}
 	case TypeVersion:
 		if e, ok := lm.(*Version); ok {
-			return marshalVersion(e)
+			return appendVersion(dst, e)
 		}
 	}
-	return nil
+	return dst
+}
+
+// encodedLen returns the number of bytes lm will take once marshaled, so Marshal only
+// needs a single allocation.
+func encodedLen(lm LurkMessage) int {
+	switch m := lm.(type) {
+	case *Message:
+		return messageLength + min(len(m.Text), maxTextLen)
+	case *Error:
+		return LengthOffset[TypeError] + min(len(m.ErrMessage), maxTextLen)
+	case *Room:
+		return LengthOffset[TypeRoom] + min(len(m.RoomDesc), maxTextLen)
+	case *Character:
+		return LengthOffset[TypeCharacter] + min(len(m.PlayerDesc), maxTextLen)
+	case *Game:
+		return LengthOffset[TypeGame] + min(len(m.GameDesc), maxTextLen)
+	case *Connection:
+		return LengthOffset[TypeConnection] + min(len(m.RoomDesc), maxTextLen)
+	case *Version:
+		n := LengthOffset[TypeVersion]
+		for _, ext := range m.Extensions {
+			n += len(ext) + 2
+		}
+		return n
+	}
+	return LengthOffset[lm.GetType()]
 }
 
 // We want to read exactly the length of the message. This function will do up to 3
@@ -285,11 +386,11 @@ func validate(data []byte) error {
 }
 
 type Message struct {
-	Type      MessageType
-	Recipient string // max 32 bytes. All fields noted with bytes are null terminated '\x00'.
-	Sender    string // max 30 bytes
-	Text      string
-	Narration bool
+	Type      MessageType `json:"type"`
+	Recipient string      `json:"recipient"` // max 32 bytes. All fields noted with bytes are null terminated '\x00'.
+	Sender    string      `json:"sender"`    // max 30 bytes
+	Text      string      `json:"text"`
+	Narration bool        `json:"narration"`
 }
 
 func (*Message) GetType() MessageType {
@@ -328,28 +429,19 @@ func unmarshalMessage(data []byte) (*Message, error) {
 	return m, nil
 }
 
-func marshalMessage(msg *Message) []byte {
-	msgLength := uint16(len(msg.Text))
-	ba := make([]byte, msgLength+messageLength)
-
-	offset := 0
-	ba[offset] = byte(TypeMessage)
-	offset++
-	binary.LittleEndian.PutUint16(ba[offset:], msgLength)
-	offset += 2
-	copy(ba[offset:offset+maxStringLen], getNullTermedString(msg.Recipient))
-	offset += maxStringLen
-	copy(ba[offset:offset+maxStringLen], getNullTermedString(msg.Sender))
-	offset += maxStringLen - 1
-	ba[offset] = boolToByte(msg.Narration)
-	offset++
-	copy(ba[offset:], []byte(msg.Text))
-	return ba
+func appendMessage(dst []byte, msg *Message) []byte {
+	dst = append(dst, byte(TypeMessage))
+	dst = appendLength(dst, msg.Text)
+	dst = appendNullTermed(dst, msg.Recipient, maxStringLen)
+	// The last byte of the sender field holds the narration flag.
+	dst = appendNullTermed(dst, msg.Sender, maxStringLen-1)
+	dst = append(dst, boolToByte(msg.Narration))
+	return appendText(dst, msg.Text)
 }
 
 type ChangeRoom struct {
-	Type       MessageType
-	RoomNumber uint16
+	Type       MessageType `json:"type"`
+	RoomNumber uint16      `json:"roomNumber"`
 }
 
 func (*ChangeRoom) GetType() MessageType {
@@ -366,15 +458,13 @@ func unmarshalChangeRoom(data []byte) (*ChangeRoom, error) {
 	}, nil
 }
 
-func marshalChangeRoom(cr *ChangeRoom) []byte {
-	ba := make([]byte, 3)
-	ba[0] = byte(TypeChangeRoom)
-	binary.LittleEndian.PutUint16(ba[1:], cr.RoomNumber)
-	return ba
+func appendChangeRoom(dst []byte, cr *ChangeRoom) []byte {
+	dst = append(dst, byte(TypeChangeRoom))
+	return binary.LittleEndian.AppendUint16(dst, cr.RoomNumber)
 }
 
 type Fight struct {
-	Type MessageType
+	Type MessageType `json:"type"`
 }
 
 func (*Fight) GetType() MessageType {
@@ -382,8 +472,8 @@ func (*Fight) GetType() MessageType {
 }
 
 type PVPFight struct {
-	Type       MessageType
-	TargetName string // 32 bytes
+	Type       MessageType `json:"type"`
+	TargetName string      `json:"target"` // 32 bytes
 }
 
 func (*PVPFight) GetType() MessageType {
@@ -403,16 +493,14 @@ func unmarshalPVP(data []byte) (*PVPFight, error) {
 	}, nil
 }
 
-func marshalPVP(pvp *PVPFight) []byte {
-	ba := make([]byte, maxStringLen+1)
-	ba[0] = byte(TypePVPFight)
-	copy(ba[1:], getNullTermedString(pvp.TargetName))
-	return ba
+func appendPVP(dst []byte, pvp *PVPFight) []byte {
+	dst = append(dst, byte(TypePVPFight))
+	return appendNullTermed(dst, pvp.TargetName, maxStringLen)
 }
 
 type Loot struct {
-	Type       MessageType
-	TargetName string // 32 bytes
+	Type       MessageType `json:"type"`
+	TargetName string      `json:"target"` // 32 bytes
 }
 
 func (l *Loot) GetType() MessageType {
@@ -432,15 +520,13 @@ func unmarshalLoot(data []byte) (*Loot, error) {
 	}, nil
 }
 
-func marshalLoot(loot *Loot) []byte {
-	ba := make([]byte, maxStringLen+1)
-	ba[0] = byte(TypeLoot)
-	copy(ba[1:], getNullTermedString(loot.TargetName))
-	return ba
+func appendLoot(dst []byte, loot *Loot) []byte {
+	dst = append(dst, byte(TypeLoot))
+	return appendNullTermed(dst, loot.TargetName, maxStringLen)
 }
 
 type Start struct {
-	Type MessageType
+	Type MessageType `json:"type"`
 }
 
 func (s *Start) GetType() MessageType {
@@ -448,9 +534,9 @@ func (s *Start) GetType() MessageType {
 }
 
 type Error struct {
-	Type       MessageType
-	ErrCode    cross.ErrCode
-	ErrMessage string
+	Type       MessageType   `json:"type"`
+	ErrCode    cross.ErrCode `json:"code"`
+	ErrMessage string        `json:"message"`
 }
 
 func (e *Error) GetType() MessageType {
@@ -464,7 +550,7 @@ func unmarshalError(data []byte) (*Error, error) {
 
 	msgLen := binary.LittleEndian.Uint16(data[2:])
 
-	if len(data) < int(4+msgLen) {
+	if len(data) < 4+int(msgLen) {
 		return nil, cross.ErrFrameTooSmall
 	}
 
@@ -480,18 +566,15 @@ func unmarshalError(data []byte) (*Error, error) {
 	}, nil
 }
 
-func marshalError(e *Error) []byte {
-	ba := make([]byte, 4+len(e.ErrMessage))
-	ba[0] = byte(TypeError)
-	ba[1] = byte(e.ErrCode)
-	binary.LittleEndian.PutUint16(ba[2:], uint16(len(e.ErrMessage)))
-	copy(ba[4:], []byte(e.ErrMessage))
-	return ba
+func appendError(dst []byte, e *Error) []byte {
+	dst = append(dst, byte(TypeError), byte(e.ErrCode))
+	dst = appendLength(dst, e.ErrMessage)
+	return appendText(dst, e.ErrMessage)
 }
 
 type Accept struct {
-	Type   MessageType
-	Action MessageType
+	Type   MessageType `json:"type"`
+	Action MessageType `json:"action"`
 }
 
 func (a *Accept) GetType() MessageType {
@@ -508,18 +591,15 @@ func unmarshalAccept(data []byte) (*Accept, error) {
 	}, nil
 }
 
-func marshalAccept(a *Accept) []byte {
-	ba := make([]byte, 2)
-	ba[0] = byte(TypeAccept)
-	ba[1] = byte(a.Action)
-	return ba
+func appendAccept(dst []byte, a *Accept) []byte {
+	return append(dst, byte(TypeAccept), byte(a.Action))
 }
 
 type Room struct {
-	Type       MessageType
-	RoomNumber uint16
-	RoomName   string // 32 bytes
-	RoomDesc   string
+	Type       MessageType `json:"type"`
+	RoomNumber uint16      `json:"roomNumber"`
+	RoomName   string      `json:"name"` // 32 bytes
+	RoomDesc   string      `json:"description"`
 }
 
 func (r *Room) GetType() MessageType {
@@ -541,7 +621,7 @@ func unmarshalRoom(data []byte) (*Room, error) {
 
 	offset := 3 + maxStringLen
 	descLen := binary.LittleEndian.Uint16(data[offset:])
-	if len(data) < int(37+descLen) {
+	if len(data) < 37+int(descLen) {
 		return nil, cross.ErrFrameTooSmall
 	}
 
@@ -550,32 +630,25 @@ func unmarshalRoom(data []byte) (*Room, error) {
 	return room, nil
 }
 
-func marshalRoom(room *Room) []byte {
-	ba := make([]byte, 37+len(room.RoomDesc))
-	offset := 0
-	ba[offset] = byte(TypeRoom)
-	offset++
-	binary.LittleEndian.PutUint16(ba[offset:], room.RoomNumber)
-	offset += 2
-	copy(ba[offset:], getNullTermedString(room.RoomName))
-	offset += maxStringLen
-	binary.LittleEndian.PutUint16(ba[offset:], uint16(len(room.RoomDesc)))
-	offset += 2
-	copy(ba[offset:], []byte(room.RoomDesc))
-	return ba
+func appendRoom(dst []byte, room *Room) []byte {
+	dst = append(dst, byte(TypeRoom))
+	dst = binary.LittleEndian.AppendUint16(dst, room.RoomNumber)
+	dst = appendNullTermed(dst, room.RoomName, maxStringLen)
+	dst = appendLength(dst, room.RoomDesc)
+	return appendText(dst, room.RoomDesc)
 }
 
 type Character struct {
-	Type       MessageType
-	Name       string          // 32 bytes
-	Flags      map[string]bool // Alive, Join, Monster, Started, Ready
-	Attack     uint16
-	Defense    uint16
-	Regen      uint16
-	Health     int16
-	Gold       uint16
-	RoomNum    uint16
-	PlayerDesc string
+	Type       MessageType     `json:"type"`
+	Name       string          `json:"name"`  // 32 bytes
+	Flags      map[string]bool `json:"flags"` // Alive, Join, Monster, Started, Ready
+	Attack     uint16          `json:"attack"`
+	Defense    uint16          `json:"defense"`
+	Regen      uint16          `json:"regen"`
+	Health     int16           `json:"health"`
+	Gold       uint16          `json:"gold"`
+	RoomNum    uint16          `json:"roomNumber"`
+	PlayerDesc string          `json:"description"`
 }
 
 func (c *Character) GetType() MessageType {
@@ -611,7 +684,7 @@ func unmarshalCharacter(data []byte) (*Character, error) {
 	offset += 2
 	descLen := binary.LittleEndian.Uint16(data[offset:])
 	offset += 2
-	if len(data) < int(48+descLen) {
+	if len(data) < 48+int(descLen) {
 		return nil, cross.ErrFrameTooSmall
 	}
 	c.PlayerDesc = string(data[offset : offset+int(descLen)])
@@ -628,31 +701,18 @@ func unmarshalCharacterFlags(data byte) map[string]bool {
 	return flags
 }
 
-func marshalCharacter(c *Character) []byte {
-	ba := make([]byte, 48+len(c.PlayerDesc))
-	offset := 0
-	ba[offset] = byte(TypeCharacter)
-	offset++
-	copy(ba[offset:], getNullTermedString(c.Name))
-	offset += maxStringLen
-	ba[offset] = marshalCharacterFlags(c.Flags)
-	offset++
-	binary.LittleEndian.PutUint16(ba[offset:], c.Attack)
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], c.Defense)
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], c.Regen)
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], uint16(c.Health))
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], c.Gold)
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], c.RoomNum)
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], uint16(len(c.PlayerDesc)))
-	offset += 2
-	copy(ba[offset:], []byte(c.PlayerDesc))
-	return ba
+func appendCharacter(dst []byte, c *Character) []byte {
+	dst = append(dst, byte(TypeCharacter))
+	dst = appendNullTermed(dst, c.Name, maxStringLen)
+	dst = append(dst, marshalCharacterFlags(c.Flags))
+	dst = binary.LittleEndian.AppendUint16(dst, c.Attack)
+	dst = binary.LittleEndian.AppendUint16(dst, c.Defense)
+	dst = binary.LittleEndian.AppendUint16(dst, c.Regen)
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(c.Health))
+	dst = binary.LittleEndian.AppendUint16(dst, c.Gold)
+	dst = binary.LittleEndian.AppendUint16(dst, c.RoomNum)
+	dst = appendLength(dst, c.PlayerDesc)
+	return appendText(dst, c.PlayerDesc)
 }
 
 func marshalCharacterFlags(flags map[string]bool) (word byte) {
@@ -683,10 +743,10 @@ const (
 )
 
 type Game struct {
-	Type          MessageType
-	InitialPoints uint16
-	StatLimit     uint16
-	GameDesc      string
+	Type          MessageType `json:"type"`
+	InitialPoints uint16      `json:"initialPoints"`
+	StatLimit     uint16      `json:"statLimit"`
+	GameDesc      string      `json:"description"`
 }
 
 func (g *Game) GetType() MessageType {
@@ -708,7 +768,7 @@ func unmarshalGame(data []byte) (*Game, error) {
 	descLen := binary.LittleEndian.Uint16(data[offset:])
 	offset += 2
 
-	if len(data) < int(7+descLen) {
+	if len(data) < 7+int(descLen) {
 		return nil, cross.ErrFrameTooSmall
 	}
 
@@ -716,23 +776,16 @@ func unmarshalGame(data []byte) (*Game, error) {
 	return g, nil
 }
 
-func marshalGame(g *Game) []byte {
-	ba := make([]byte, 7+len(g.GameDesc))
-	offset := 0
-	ba[offset] = byte(TypeGame)
-	offset++
-	binary.LittleEndian.PutUint16(ba[offset:], g.InitialPoints)
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], g.StatLimit)
-	offset += 2
-	binary.LittleEndian.PutUint16(ba[offset:], uint16(len(g.GameDesc)))
-	offset += 2
-	copy(ba[offset:], []byte(g.GameDesc))
-	return ba
+func appendGame(dst []byte, g *Game) []byte {
+	dst = append(dst, byte(TypeGame))
+	dst = binary.LittleEndian.AppendUint16(dst, g.InitialPoints)
+	dst = binary.LittleEndian.AppendUint16(dst, g.StatLimit)
+	dst = appendLength(dst, g.GameDesc)
+	return appendText(dst, g.GameDesc)
 }
 
 type Leave struct {
-	Type MessageType
+	Type MessageType `json:"type"`
 }
 
 func (l *Leave) GetType() MessageType {
@@ -740,10 +793,10 @@ func (l *Leave) GetType() MessageType {
 }
 
 type Connection struct {
-	Type       MessageType
-	RoomNumber uint16
-	RoomName   string //32 bytes
-	RoomDesc   string
+	Type       MessageType `json:"type"`
+	RoomNumber uint16      `json:"roomNumber"`
+	RoomName   string      `json:"name"` //32 bytes
+	RoomDesc   string      `json:"description"`
 }
 
 func (c *Connection) GetType() MessageType {
@@ -763,7 +816,7 @@ func unmarshalConnection(data []byte) (*Connection, error) {
 	c.RoomName = string(data[offset : offset+nameLen])
 	offset += maxStringLen
 	descLen := binary.LittleEndian.Uint16(data[offset:])
-	if len(data) < int(37+descLen) {
+	if len(data) < 37+int(descLen) {
 		return nil, cross.ErrFrameTooSmall
 	}
 	offset += 2
@@ -771,26 +824,19 @@ func unmarshalConnection(data []byte) (*Connection, error) {
 	return c, nil
 }
 
-func marshalConnection(c *Connection) []byte {
-	ba := make([]byte, 37+len(c.RoomDesc))
-	offset := 0
-	ba[offset] = byte(TypeConnection)
-	offset++
-	binary.LittleEndian.PutUint16(ba[offset:], c.RoomNumber)
-	offset += 2
-	copy(ba[offset:], getNullTermedString(c.RoomName))
-	offset += maxStringLen
-	binary.LittleEndian.PutUint16(ba[offset:], uint16(len(c.RoomDesc)))
-	offset += 2
-	copy(ba[offset:], []byte(c.RoomDesc))
-	return ba
+func appendConnection(dst []byte, c *Connection) []byte {
+	dst = append(dst, byte(TypeConnection))
+	dst = binary.LittleEndian.AppendUint16(dst, c.RoomNumber)
+	dst = appendNullTermed(dst, c.RoomName, maxStringLen)
+	dst = appendLength(dst, c.RoomDesc)
+	return appendText(dst, c.RoomDesc)
 }
 
 type Version struct {
-	Type       MessageType
-	Major      byte
-	Minor      byte
-	Extensions [][]byte // For now. Turn into object when we know what it is.
+	Type       MessageType `json:"type"`
+	Major      byte        `json:"major"`
+	Minor      byte        `json:"minor"`
+	Extensions [][]byte    `json:"extensions"` // For now. Turn into object when we know what it is.
 }
 
 func (v *Version) GetType() MessageType {
@@ -829,28 +875,21 @@ func unmarshalVersion(data []byte) (*Version, error) {
 	}
 }
 
-func marshalVersion(v *Version) []byte {
-	ba := make([]byte, 5) // Only big enough for the size of the list of extensions
-	offset := 0
-	ba[offset] = byte(TypeVersion)
-	offset++
-	ba[offset] = v.Major
-	offset++
-	ba[offset] = v.Minor
-	offset++
+func appendVersion(dst []byte, v *Version) []byte {
+	dst = append(dst, byte(TypeVersion), v.Major, v.Minor)
 
 	remainingLen := 0
 	for _, ext := range v.Extensions {
 		remainingLen += len(ext) + 2 // for the size
 	}
 
-	binary.LittleEndian.PutUint16(ba[offset:], uint16(remainingLen))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(remainingLen))
 
 	for _, ext := range v.Extensions {
-		ba = binary.LittleEndian.AppendUint16(ba, uint16(len(ext)))
-		ba = append(ba, ext...)
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(len(ext)))
+		dst = append(dst, ext...)
 	}
-	return ba
+	return dst
 }
 
 // data should be a slice starting at the start of a null terminated string.
@@ -864,17 +903,28 @@ func getNullTermLen(data []byte) (length int) {
 	return
 }
 
-func getNullTermedString(value string) []byte {
-	length := len(value)
-	if length >= maxStringLen {
-		value = value[:maxStringLen]
-	}
+// nulls is used to pad fixed length strings without allocating.
+var nulls [maxStringLen]byte
 
-	nulls := make([]byte, maxStringLen-len(value))
-	for i := range nulls {
-		nulls[i] = 0
+// appendNullTermed appends value as a fixed width field of width bytes. Values that are too
+// long are cut off, shorter values are padded with null bytes.
+func appendNullTermed(dst []byte, value string, width int) []byte {
+	if len(value) >= width {
+		return append(dst, value[:width]...)
 	}
-	return append([]byte(value), nulls...)
+	dst = append(dst, value...)
+	return append(dst, nulls[:width-len(value)]...)
+}
+
+// The protocol only has 16 bits for the length of text, anything longer is cut off.
+const maxTextLen = 0xffff
+
+func appendLength(dst []byte, text string) []byte {
+	return binary.LittleEndian.AppendUint16(dst, uint16(min(len(text), maxTextLen)))
+}
+
+func appendText(dst []byte, text string) []byte {
+	return append(dst, text[:min(len(text), maxTextLen)]...)
 }
 
 func boolToByte(cond bool) byte {
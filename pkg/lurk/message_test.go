@@ -1,6 +1,7 @@
 package lurk_test
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"testing"
@@ -253,6 +254,33 @@ func TestVariableLengthQuery(t *testing.T) {
 	}
 }
 
+func TestFrameLength(t *testing.T) {
+	a := assert.New(t)
+	t.Run("TestFullFrames", func(_ *testing.T) {
+		for _, lm := range []lurk.LurkMessage{
+			&lurk.Message{Text: "test"},
+			&lurk.Fight{},
+			&lurk.Loot{TargetName: "Bean"},
+			&lurk.Character{PlayerDesc: "test"},
+			&lurk.Version{Extensions: [][]byte{{1, 2}}},
+		} {
+			ba := lurk.Marshal(lm)
+			n, err := lurk.FrameLength(append(ba, 0x03, 0x03)) // trailing messages are not counted
+			a.NoError(err)
+			a.True(n == len(ba))
+		}
+	})
+	t.Run("TestPartialHeader", func(_ *testing.T) {
+		ba := lurk.Marshal(&lurk.Message{Text: "test"})
+		_, err := lurk.FrameLength(ba[:40])
+		a.ErrorIs(err, cross.ErrFrameTooSmall)
+	})
+	t.Run("TestInvalidType", func(_ *testing.T) {
+		_, err := lurk.FrameLength([]byte{0})
+		a.ErrorIs(err, cross.ErrInvalidMessageType)
+	})
+}
+
 var variableLengthTests = []struct {
 	name     string
 	ba       []byte
@@ -328,3 +356,49 @@ var sampleMessage = []byte{0x1, 0x5, 0x00,
 	0x52, 0x61, 0x79, 0x6d, 0x6f, 0x6e, 0x64, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 	0x43, 0x6c, 0x61, 0x79, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
 	0x48, 0x65, 0x6c, 0x6c, 0x6f}
+
+func TestAppendMarshal(t *testing.T) {
+	a := assert.New(t)
+	prefix := []byte{0xde, 0xad}
+	for _, lm := range everyMessage {
+		ba := lurk.AppendMarshal(prefix[:2:2], lm)
+		a.True(bytes.Equal(ba[:2], prefix))
+		a.True(bytes.Equal(ba[2:], lurk.Marshal(lm)))
+	}
+
+	// Unknown implementations of LurkMessage are left out.
+	a.True(len(lurk.AppendMarshal(prefix, foreignMessage{})) == len(prefix))
+	a.True(lurk.Marshal(foreignMessage{}) == nil)
+
+	// A reused buffer with enough capacity does not allocate.
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, lm := range everyMessage {
+			buf = lurk.AppendMarshal(buf[:0], lm)
+		}
+	})
+	a.True(allocs == 0)
+}
+
+type foreignMessage struct{}
+
+func (foreignMessage) GetType() lurk.MessageType { return lurk.TypeRoom }
+
+func BenchmarkMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		for _, lm := range everyMessage {
+			_ = lurk.Marshal(lm)
+		}
+	}
+}
+
+func BenchmarkAppendMarshal(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 1024)
+	for range b.N {
+		for _, lm := range everyMessage {
+			buf = lurk.AppendMarshal(buf[:0], lm)
+		}
+	}
+}
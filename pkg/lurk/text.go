@@ -0,0 +1,81 @@
+package lurk
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The String forms below are meant for logs and debugging tools. They are a single line as
+// long as the text fields of the message are.
+
+func (m *Message) String() string {
+	narration := ""
+	if m.Narration {
+		narration = " (narration)"
+	}
+	return fmt.Sprintf("%v %s -> %s%s: %q", TypeMessage, m.Sender, m.Recipient, narration, m.Text)
+}
+
+func (cr *ChangeRoom) String() string {
+	return fmt.Sprintf("%v %d", TypeChangeRoom, cr.RoomNumber)
+}
+
+func (*Fight) String() string {
+	return TypeFight.String()
+}
+
+func (pvp *PVPFight) String() string {
+	return fmt.Sprintf("%v %s", TypePVPFight, pvp.TargetName)
+}
+
+func (l *Loot) String() string {
+	return fmt.Sprintf("%v %s", TypeLoot, l.TargetName)
+}
+
+func (*Start) String() string {
+	return TypeStart.String()
+}
+
+func (e *Error) String() string {
+	return fmt.Sprintf("%v %d (%v): %q", TypeError, e.ErrCode, e.ErrCode, e.ErrMessage)
+}
+
+func (a *Accept) String() string {
+	return fmt.Sprintf("%v %v", TypeAccept, a.Action)
+}
+
+func (r *Room) String() string {
+	return fmt.Sprintf("%v %d %q: %q", TypeRoom, r.RoomNumber, r.RoomName, r.RoomDesc)
+}
+
+func (c *Character) String() string {
+	return fmt.Sprintf("%v %q [%s] attack %d defense %d regen %d health %d gold %d room %d: %q",
+		TypeCharacter, c.Name, strings.Join(c.SetFlags(), ", "),
+		c.Attack, c.Defense, c.Regen, c.Health, c.Gold, c.RoomNum, c.PlayerDesc)
+}
+
+// SetFlags returns the names of the flags which are set, in the order of the flag bits.
+func (c *Character) SetFlags() (flags []string) {
+	for _, flag := range []string{Alive, JoinBattle, Monster, Started, Ready} {
+		if c.Flags[flag] {
+			flags = append(flags, flag)
+		}
+	}
+	return
+}
+
+func (g *Game) String() string {
+	return fmt.Sprintf("%v initial points %d stat limit %d: %q", TypeGame, g.InitialPoints, g.StatLimit, g.GameDesc)
+}
+
+func (*Leave) String() string {
+	return TypeLeave.String()
+}
+
+func (c *Connection) String() string {
+	return fmt.Sprintf("%v %d %q: %q", TypeConnection, c.RoomNumber, c.RoomName, c.RoomDesc)
+}
+
+func (v *Version) String() string {
+	return fmt.Sprintf("%v %d.%d (%d extensions)", TypeVersion, v.Major, v.Minor, len(v.Extensions))
+}
@@ -0,0 +1,112 @@
+package ws
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Clayal10/enders_game/pkg/assert"
+)
+
+// echoServer sends every message it receives straight back.
+func echoServer(a *assert.Assert) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			a.NoError(conn.WriteMessage(mt, data))
+		}
+	}))
+}
+
+func TestWebSocket(t *testing.T) {
+	a := assert.New(t)
+	srv := echoServer(a)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	t.Run("TestEcho", func(_ *testing.T) {
+		conn, err := Dial(url, nil)
+		a.NoError(err)
+		defer func() { _ = conn.Close() }()
+
+		// One size for each length encoding.
+		for _, size := range []int{0, 125, 126, 0xffff, 0x10000} {
+			sent := bytes.Repeat([]byte{'x'}, size)
+			a.NoError(conn.WriteMessage(BinaryMessage, sent))
+			mt, got, err := conn.ReadMessage()
+			a.NoError(err)
+			a.True(mt == BinaryMessage)
+			a.EqualSlice(sent, got)
+		}
+
+		a.NoError(conn.WriteMessage(TextMessage, []byte(`{"type":"FIGHT"}`)))
+		mt, got, err := conn.ReadMessage()
+		a.NoError(err)
+		a.True(mt == TextMessage)
+		a.True(string(got) == `{"type":"FIGHT"}`)
+	})
+	t.Run("TestPingAndFragments", func(_ *testing.T) {
+		conn, err := Dial(url, nil)
+		a.NoError(err)
+		defer func() { _ = conn.Close() }()
+
+		// A fragmented message with a ping in the middle of it.
+		frames := [][]byte{
+			clientFrame(false, byte(TextMessage), []byte("hel")),
+			clientFrame(true, opPing, []byte("ping")),
+			clientFrame(true, opContinuation, []byte("lo")),
+		}
+		for _, frame := range frames {
+			_, err = conn.NetConn().Write(frame)
+			a.NoError(err)
+		}
+
+		// The pong is skipped while reading.
+		mt, got, err := conn.ReadMessage()
+		a.NoError(err)
+		a.True(mt == TextMessage)
+		a.True(string(got) == "hello")
+	})
+	t.Run("TestClose", func(_ *testing.T) {
+		conn, err := Dial(url, nil)
+		a.NoError(err)
+		a.NoError(conn.writeFrame(opClose, nil))
+		_, _, err = conn.ReadMessage()
+		a.True(errors.Is(err, io.EOF))
+		a.NoError(conn.NetConn().Close())
+	})
+	t.Run("TestBadHandshake", func(_ *testing.T) {
+		resp, err := http.Get(srv.URL)
+		a.NoError(err)
+		defer func() { _ = resp.Body.Close() }()
+		a.True(resp.StatusCode == http.StatusBadRequest)
+
+		_, err = Dial(strings.Replace(url, "ws", "ftp", 1), nil)
+		a.True(errors.Is(err, ErrBadHandshake))
+	})
+}
+
+// clientFrame builds a masked frame by hand so tests can send fragments and control frames.
+func clientFrame(fin bool, op byte, payload []byte) []byte {
+	head := op
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head, 0x80 | byte(len(payload)), 1, 2, 3, 4}
+	for i, b := range payload {
+		frame = append(frame, b^frame[2+i%4])
+	}
+	return frame
+}